@@ -0,0 +1,45 @@
+// Command waifuvault-webdav serves a bucket over WebDAV so it can be mounted as a network drive
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+
+	waifuVault "github.com/waifuvault/waifuVault-go-api/pkg"
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+	"github.com/waifuvault/waifuVault-go-api/pkg/webdav"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to listen on")
+	bucket := flag.String("bucket", os.Getenv("WAIFUVAULT_BUCKET"), "bucket token, defaults to $WAIFUVAULT_BUCKET")
+	expires := flag.String("expires", "", "expiry of uploaded files, for example 10d")
+	flag.Parse()
+	if *bucket == "" {
+		log.Fatal("a bucket token is required")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	handler := webdav.NewHandler(ctx, waifuVault.NewWaifuvaltApi(http.Client{}), *bucket, mod.WebDAVOpts{Expires: *expires})
+	handler.Logger = func(r *http.Request, err error) {
+		if err != nil {
+			log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+		}
+	}
+	server := &http.Server{Addr: *addr, Handler: handler}
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+
+	log.Printf("serving bucket on http://%s", *addr)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,588 @@
+package waifuVault
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+// fakeVaultRetention is the retention period given to files uploaded without an expiry
+const fakeVaultRetention = 30 * 24 * time.Hour
+
+// fakeVault is an in memory WaifuVault used to test flows spanning several API calls
+type fakeVault struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	nextID   int
+	epoch    int64
+	buckets  map[string]bool
+	files    map[string]*fakeFile
+	albums   map[string]*fakeAlbum
+	requests []string
+}
+
+type fakeFile struct {
+	response mod.WaifuResponse[int]
+	name     string
+	content  []byte
+	password string
+	album    string
+}
+
+type fakeAlbum struct {
+	token       string
+	bucket      string
+	name        string
+	publicToken *string
+	files       []string
+	dateCreated int64
+}
+
+// newFakeVault starts a fake vault and points baseUrl at it for the duration of the test
+func newFakeVault(t *testing.T) *fakeVault {
+	fv := &fakeVault{
+		epoch:   1710111505084,
+		buckets: map[string]bool{},
+		files:   map[string]*fakeFile{},
+		albums:  map[string]*fakeAlbum{},
+	}
+	fv.server = httptest.NewServer(http.HandlerFunc(fv.handle))
+	origBaseUrl := baseUrl
+	baseUrl = fv.server.URL
+	t.Cleanup(func() {
+		baseUrl = origBaseUrl
+		fv.server.Close()
+	})
+	return fv
+}
+
+// addBucket creates a bucket directly in the store
+func (fv *fakeVault) addBucket() string {
+	fv.mu.Lock()
+	defer fv.mu.Unlock()
+	return fv.createBucket()
+}
+
+// addFile uploads a file directly into the store and returns its token
+func (fv *fakeVault) addFile(bucket, name string, content []byte, options mod.WaifuResponseOptions, password string) string {
+	fv.mu.Lock()
+	defer fv.mu.Unlock()
+	options.Protected = password != ""
+	return fv.storeFile(bucket, name, content, options, password, fakeVaultRetention)
+}
+
+// addAlbum creates an album containing the given files directly in the store
+func (fv *fakeVault) addAlbum(bucket, name string, files ...string) string {
+	fv.mu.Lock()
+	defer fv.mu.Unlock()
+	album := fv.createAlbum(bucket, name)
+	fv.associate(album, files)
+	return album.token
+}
+
+func (fv *fakeVault) file(token string) *fakeFile {
+	fv.mu.Lock()
+	defer fv.mu.Unlock()
+	return fv.files[token]
+}
+
+func (fv *fakeVault) album(token string) *fakeAlbum {
+	fv.mu.Lock()
+	defer fv.mu.Unlock()
+	return fv.albums[token]
+}
+
+// bucketFiles returns the files in a bucket
+func (fv *fakeVault) bucketFiles(bucket string) []*fakeFile {
+	fv.mu.Lock()
+	defer fv.mu.Unlock()
+	var files []*fakeFile
+	for _, file := range fv.sortedFiles() {
+		if file.response.Bucket == bucket {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// requestCount counts the requests made with the given method whose path starts with prefix
+func (fv *fakeVault) requestCount(method, prefix string) int {
+	fv.mu.Lock()
+	defer fv.mu.Unlock()
+	count := 0
+	for _, request := range fv.requests {
+		if strings.HasPrefix(request, method+" "+prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+func (fv *fakeVault) token(prefix string) string {
+	fv.nextID++
+	return fmt.Sprintf("%s-%d", prefix, fv.nextID)
+}
+
+func (fv *fakeVault) createBucket() string {
+	token := fv.token("bucket")
+	fv.buckets[token] = true
+	return token
+}
+
+func (fv *fakeVault) storeFile(bucket, name string, content []byte, options mod.WaifuResponseOptions, password string, retention time.Duration) string {
+	token := fv.token("file")
+	fv.epoch++
+	fileUrl := mod.FileURL{
+		Host:      fv.server.URL,
+		Epoch:     time.UnixMilli(fv.epoch),
+		Filename:  name,
+		Extension: path.Ext(name),
+		Hidden:    options.HideFilename,
+	}
+	if options.HideFilename {
+		fileUrl.Filename = ""
+	}
+	fv.files[token] = &fakeFile{
+		response: mod.WaifuResponse[int]{
+			Token:           token,
+			URL:             fileUrl.String(),
+			Options:         options,
+			RetentionPeriod: int(retention / time.Millisecond),
+			Bucket:          bucket,
+			ID:              fv.nextID,
+		},
+		name:     name,
+		content:  content,
+		password: password,
+	}
+	return token
+}
+
+func (fv *fakeVault) createAlbum(bucket, name string) *fakeAlbum {
+	album := &fakeAlbum{
+		token:       fv.token("album"),
+		bucket:      bucket,
+		name:        name,
+		dateCreated: fv.epoch,
+	}
+	fv.albums[album.token] = album
+	return album
+}
+
+func (fv *fakeVault) associate(album *fakeAlbum, files []string) {
+	for _, token := range files {
+		file, found := fv.files[token]
+		if !found || file.album == album.token {
+			continue
+		}
+		file.album = album.token
+		album.files = append(album.files, token)
+	}
+}
+
+func (fv *fakeVault) disassociate(album *fakeAlbum, files []string) {
+	for _, token := range files {
+		if file, found := fv.files[token]; found && file.album == album.token {
+			file.album = ""
+		}
+		for i, albumFile := range album.files {
+			if albumFile == token {
+				album.files = append(album.files[:i], album.files[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+func (fv *fakeVault) deleteFile(token string) {
+	file, found := fv.files[token]
+	if !found {
+		return
+	}
+	if album, found := fv.albums[file.album]; found {
+		fv.disassociate(album, []string{token})
+	}
+	delete(fv.files, token)
+}
+
+// sortedFiles returns all files ordered by ID, callers must hold the lock
+func (fv *fakeVault) sortedFiles() []*fakeFile {
+	files := make([]*fakeFile, 0, len(fv.files))
+	for _, file := range fv.files {
+		files = append(files, file)
+	}
+	slices.SortFunc(files, func(a, b *fakeFile) int {
+		return a.response.ID - b.response.ID
+	})
+	return files
+}
+
+func (fv *fakeVault) albumResponse(album *fakeAlbum) mod.WaifuAlbum {
+	files := make([]mod.WaifuResponse[int], 0, len(album.files))
+	for _, token := range album.files {
+		files = append(files, fv.files[token].response)
+	}
+	return mod.WaifuAlbum{
+		Token:       album.token,
+		BucketToken: album.bucket,
+		PublicToken: album.publicToken,
+		Name:        album.name,
+		Files:       files,
+		DateCreated: album.dateCreated,
+	}
+}
+
+func (fv *fakeVault) writeError(w http.ResponseWriter, status int, message string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(mod.WaifuError{Name: http.StatusText(status), Message: message, Status: status})
+}
+
+func (fv *fakeVault) handle(w http.ResponseWriter, r *http.Request) {
+	fv.mu.Lock()
+	defer fv.mu.Unlock()
+	fv.requests = append(fv.requests, r.Method+" "+r.URL.Path)
+
+	if identifier, found := strings.CutPrefix(r.URL.EscapedPath(), "/f/"); found {
+		fv.handleDownload(w, r, identifier)
+		return
+	}
+	restPath, found := strings.CutPrefix(r.URL.Path, "/rest")
+	if !found {
+		fv.writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	restPath = strings.TrimPrefix(restPath, "/")
+	switch {
+	case restPath == "resources/restrictions" && r.Method == http.MethodGet:
+		w.Write([]byte(`[{"type":"MAX_FILE_SIZE","value":104857600}]`))
+	case restPath == "bucket/create" && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(mod.WaifuBucket{Token: fv.createBucket(), Files: []mod.WaifuResponse[int]{}, Albums: []mod.AlbumStub{}})
+	case restPath == "bucket/get" && r.Method == http.MethodPost:
+		fv.handleGetBucket(w, r)
+	case strings.HasPrefix(restPath, "bucket/") && r.Method == http.MethodDelete:
+		fv.handleDeleteBucket(w, strings.TrimPrefix(restPath, "bucket/"))
+	case strings.HasPrefix(restPath, "album/"):
+		fv.handleAlbum(w, r, strings.TrimPrefix(restPath, "album/"))
+	case r.Method == http.MethodPut:
+		fv.handleUpload(w, r, restPath)
+	case r.Method == http.MethodGet:
+		file, found := fv.files[restPath]
+		if !found {
+			fv.writeError(w, http.StatusBadRequest, "file not found")
+			return
+		}
+		json.NewEncoder(w).Encode(file.response)
+	case r.Method == http.MethodDelete:
+		if _, found := fv.files[restPath]; !found {
+			fv.writeError(w, http.StatusBadRequest, "file not found")
+			return
+		}
+		fv.deleteFile(restPath)
+		w.Write([]byte("true"))
+	case r.Method == http.MethodPatch:
+		fv.handleModify(w, r, restPath)
+	default:
+		fv.writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (fv *fakeVault) handleGetBucket(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		BucketToken string `json:"bucket_token"`
+	}
+	json.NewDecoder(r.Body).Decode(&payload)
+	if !fv.buckets[payload.BucketToken] {
+		fv.writeError(w, http.StatusBadRequest, "bucket not found")
+		return
+	}
+	bucket := mod.WaifuBucket{Token: payload.BucketToken, Files: []mod.WaifuResponse[int]{}, Albums: []mod.AlbumStub{}}
+	for _, file := range fv.sortedFiles() {
+		if file.response.Bucket == payload.BucketToken {
+			bucket.Files = append(bucket.Files, file.response)
+		}
+	}
+	for _, album := range fv.albums {
+		if album.bucket == payload.BucketToken {
+			bucket.Albums = append(bucket.Albums, mod.AlbumStub{
+				Token:       album.token,
+				Bucket:      album.bucket,
+				PublicToken: album.publicToken,
+				Name:        album.name,
+				DateCreated: album.dateCreated,
+			})
+		}
+	}
+	json.NewEncoder(w).Encode(bucket)
+}
+
+func (fv *fakeVault) handleDeleteBucket(w http.ResponseWriter, token string) {
+	if !fv.buckets[token] {
+		fv.writeError(w, http.StatusBadRequest, "bucket not found")
+		return
+	}
+	for fileToken, file := range fv.files {
+		if file.response.Bucket == token {
+			delete(fv.files, fileToken)
+		}
+	}
+	for albumToken, album := range fv.albums {
+		if album.bucket == token {
+			delete(fv.albums, albumToken)
+		}
+	}
+	delete(fv.buckets, token)
+	w.Write([]byte("true"))
+}
+
+func (fv *fakeVault) handleUpload(w http.ResponseWriter, r *http.Request, bucket string) {
+	if bucket != "" && !fv.buckets[bucket] {
+		fv.writeError(w, http.StatusBadRequest, "bucket not found")
+		return
+	}
+	var name, password string
+	var content []byte
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		reader, err := r.MultipartReader()
+		if err != nil {
+			fv.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				fv.writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			value, _ := io.ReadAll(part)
+			switch part.FormName() {
+			case "file":
+				name = part.FileName()
+				content = value
+			case "password":
+				password = string(value)
+			}
+		}
+	} else {
+		var payload struct {
+			Url      string `json:"url"`
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			fv.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		name = path.Base(payload.Url)
+		content = []byte(payload.Url)
+		password = payload.Password
+	}
+	if name == "" {
+		fv.writeError(w, http.StatusBadRequest, "no file")
+		return
+	}
+
+	query := r.URL.Query()
+	options := mod.WaifuResponseOptions{
+		HideFilename:    query.Get("hide_filename") == "true",
+		OneTimeDownload: query.Get("one_time_download") == "true",
+		Protected:       password != "",
+	}
+	retention := fakeVaultRetention
+	if expires := query.Get("expires"); expires != "" {
+		retention = parseFakeExpiry(expires)
+	}
+	token := fv.storeFile(bucket, name, content, options, password, retention)
+	file := fv.files[token]
+	json.NewEncoder(w).Encode(mod.WaifuResponse[string]{
+		Token:           file.response.Token,
+		URL:             file.response.URL,
+		Options:         file.response.Options,
+		RetentionPeriod: strconv.Itoa(file.response.RetentionPeriod),
+		Bucket:          file.response.Bucket,
+		ID:              file.response.ID,
+	})
+}
+
+func (fv *fakeVault) handleModify(w http.ResponseWriter, r *http.Request, token string) {
+	file, found := fv.files[token]
+	if !found {
+		fv.writeError(w, http.StatusBadRequest, "file not found")
+		return
+	}
+	var payload mod.ModifyEntryPayload
+	json.NewDecoder(r.Body).Decode(&payload)
+	if payload.Password != nil {
+		if file.password != "" && (payload.PreviousPassword == nil || *payload.PreviousPassword != file.password) {
+			fv.writeError(w, http.StatusBadRequest, "previous password is incorrect")
+			return
+		}
+		file.password = *payload.Password
+		file.response.Options.Protected = file.password != ""
+	}
+	if payload.CustomExpiry != nil {
+		retention := fakeVaultRetention
+		if *payload.CustomExpiry != "" {
+			retention = parseFakeExpiry(*payload.CustomExpiry)
+		}
+		file.response.RetentionPeriod = int(retention / time.Millisecond)
+	}
+	if payload.HideFilename != nil && *payload.HideFilename != file.response.Options.HideFilename {
+		parsed, _ := ParseFileURL(file.response.URL)
+		parsed.Hidden = *payload.HideFilename
+		parsed.Filename = ""
+		if !parsed.Hidden {
+			parsed.Filename = file.name
+		}
+		file.response.URL = parsed.String()
+		file.response.Options.HideFilename = parsed.Hidden
+	}
+	json.NewEncoder(w).Encode(file.response)
+}
+
+func (fv *fakeVault) handleDownload(w http.ResponseWriter, r *http.Request, identifier string) {
+	for token, file := range fv.files {
+		parsed, _ := ParseFileURL(file.response.URL)
+		if parsed.Path() != identifier {
+			continue
+		}
+		if file.password != "" && r.Header.Get("x-password") != file.password {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		file.response.Views++
+		if file.response.Options.OneTimeDownload {
+			fv.deleteFile(token)
+		}
+		http.ServeContent(w, r, file.name, time.UnixMilli(parsed.Epoch.UnixMilli()), strings.NewReader(string(file.content)))
+		return
+	}
+	fv.writeError(w, http.StatusNotFound, "file not found")
+}
+
+func (fv *fakeVault) handleAlbum(w http.ResponseWriter, r *http.Request, albumPath string) {
+	action, token, hasAction := strings.Cut(albumPath, "/")
+	switch {
+	case hasAction && action == "share":
+		album, found := fv.albums[token]
+		if !found {
+			fv.writeError(w, http.StatusBadRequest, "album not found")
+			return
+		}
+		publicToken := fv.token("public")
+		album.publicToken = &publicToken
+		json.NewEncoder(w).Encode(mod.GenericSuccess{Success: true, Description: fv.server.URL + "/album/" + publicToken})
+	case hasAction && action == "revoke":
+		album, found := fv.albums[token]
+		if !found {
+			fv.writeError(w, http.StatusBadRequest, "album not found")
+			return
+		}
+		album.publicToken = nil
+		json.NewEncoder(w).Encode(mod.GenericSuccess{Success: true, Description: "revoked"})
+	case hasAction && action == "download":
+		fv.handleAlbumDownload(w, r, token)
+	case hasAction:
+		album, found := fv.albums[action]
+		if !found {
+			fv.writeError(w, http.StatusBadRequest, "album not found")
+			return
+		}
+		var payload struct {
+			FileTokens []string `json:"fileTokens"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		for _, fileToken := range payload.FileTokens {
+			file, found := fv.files[fileToken]
+			if !found || file.response.Bucket != album.bucket {
+				fv.writeError(w, http.StatusBadRequest, "file not in bucket")
+				return
+			}
+		}
+		if token == "associate" {
+			fv.associate(album, payload.FileTokens)
+		} else {
+			fv.disassociate(album, payload.FileTokens)
+		}
+		json.NewEncoder(w).Encode(fv.albumResponse(album))
+	case r.Method == http.MethodPost:
+		var body mod.WaifuAlbumCreateBody
+		json.NewDecoder(r.Body).Decode(&body)
+		if !fv.buckets[action] {
+			fv.writeError(w, http.StatusBadRequest, "bucket not found")
+			return
+		}
+		json.NewEncoder(w).Encode(fv.albumResponse(fv.createAlbum(action, body.Name)))
+	case r.Method == http.MethodGet:
+		album, found := fv.albums[action]
+		if !found {
+			fv.writeError(w, http.StatusBadRequest, "album not found")
+			return
+		}
+		json.NewEncoder(w).Encode(fv.albumResponse(album))
+	case r.Method == http.MethodDelete:
+		album, found := fv.albums[action]
+		if !found {
+			fv.writeError(w, http.StatusBadRequest, "album not found")
+			return
+		}
+		for _, fileToken := range append([]string{}, album.files...) {
+			if r.URL.Query().Get("deleteFiles") == "true" {
+				fv.deleteFile(fileToken)
+			} else {
+				fv.files[fileToken].album = ""
+			}
+		}
+		delete(fv.albums, action)
+		json.NewEncoder(w).Encode(mod.GenericSuccess{Success: true, Description: "deleted"})
+	default:
+		fv.writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (fv *fakeVault) handleAlbumDownload(w http.ResponseWriter, r *http.Request, token string) {
+	album, found := fv.albums[token]
+	if !found {
+		fv.writeError(w, http.StatusBadRequest, "album not found")
+		return
+	}
+	var ids []int
+	json.NewDecoder(r.Body).Decode(&ids)
+	archive := zip.NewWriter(w)
+	for _, fileToken := range album.files {
+		file := fv.files[fileToken]
+		if len(ids) > 0 && !slices.Contains(ids, file.response.ID) {
+			continue
+		}
+		entry, _ := archive.Create(file.name)
+		entry.Write(file.content)
+	}
+	archive.Close()
+}
+
+// parseFakeExpiry parses expiries such as 10m, 2h and 30d
+func parseFakeExpiry(expires string) time.Duration {
+	value, _ := strconv.Atoi(expires[:len(expires)-1])
+	switch expires[len(expires)-1] {
+	case 'm':
+		return time.Duration(value) * time.Minute
+	case 'h':
+		return time.Duration(value) * time.Hour
+	default:
+		return time.Duration(value) * 24 * time.Hour
+	}
+}
@@ -0,0 +1,34 @@
+package mod
+
+// OverwritePolicy decides what ExtractAlbum does with files that already exist
+type OverwritePolicy int
+
+const (
+	// OverwriteNever - the extraction fails before anything is written if a file already exists
+	OverwriteNever OverwritePolicy = iota
+
+	// OverwriteSkip - existing files are kept and left out of the result
+	OverwriteSkip
+
+	// OverwriteReplace - existing files are replaced
+	OverwriteReplace
+)
+
+// AlbumExtractOpts configures ExtractAlbum
+type AlbumExtractOpts struct {
+	// Overwrite decides what happens to files that already exist. defaults to OverwriteNever
+	Overwrite OverwritePolicy
+
+	// MaxFiles is the most entries the archive may contain. defaults to 10000
+	MaxFiles int
+
+	// MaxFileSize is the largest size in bytes of a single extracted file. defaults to MaxTotalSize
+	MaxFileSize int64
+
+	// MaxTotalSize is the largest size in bytes of all extracted files together. defaults to 10 GiB,
+	// the download of the archive is also limited to this plus 1 KiB per entry in MaxFiles
+	MaxTotalSize int64
+
+	// TempDir is the directory the archive is downloaded to before it is extracted. defaults to os.TempDir
+	TempDir string
+}
@@ -0,0 +1,4 @@
+package mod
+
+// AlbumFilter reports if an album should be kept when iterating over albums
+type AlbumFilter func(album AlbumStub) bool
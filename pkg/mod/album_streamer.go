@@ -0,0 +1,13 @@
+package mod
+
+import (
+	"context"
+	"io"
+)
+
+// AlbumStreamer is implemented by the clients of this package to stream album downloads. It is not part of Waifuvalt,
+// so other implementations of Waifuvalt do not have to provide it
+type AlbumStreamer interface {
+	// DownloadAlbumStream - Same as DownloadAlbum, but returns the ZIP file as a stream, the caller must close it
+	DownloadAlbumStream(ctx context.Context, albumToken string, files []int) (io.ReadCloser, error)
+}
@@ -0,0 +1,47 @@
+package mod
+
+// AlbumSyncOpts configures SyncAlbum
+type AlbumSyncOpts struct {
+	// DryRun reports the changes without uploading, associating or deleting anything
+	DryRun bool
+
+	// DeleteRemoved deletes the files that are no longer in the directory and the old versions of replaced files from the bucket,
+	// instead of only removing them from the album
+	DeleteRemoved bool
+
+	// Include - if supplied, only files whose name matches this path.Match pattern are synced
+	Include string
+
+	// Expires is applied to every uploaded file, same format as WaifuvaultPutOpts.Expires
+	Expires string
+
+	// Password protects every uploaded file, it is also sent to request the sizes of the album files
+	Password string
+}
+
+// AlbumSyncReport is the result of SyncAlbum, the lists are filenames in album order
+type AlbumSyncReport struct {
+	// AlbumToken is the album that was synced
+	AlbumToken string
+
+	// DryRun is set if the changes were only planned
+	DryRun bool
+
+	// Uploaded are the files that were missing from the album or replaced, and uploaded
+	Uploaded []string
+
+	// Replaced are the files whose size changed, they were uploaded again and the old versions removed from the album
+	Replaced []string
+
+	// Removed are the files that are no longer in the directory and were removed from the album
+	Removed []string
+
+	// Reordered are the files that were removed and added to the album again to keep it ordered by filename
+	Reordered []string
+
+	// Errors maps the filenames whose size could not be compared to the reason, those files are kept as they are
+	Errors map[string]error
+
+	// Files maps the filenames in the directory to their file tokens, files that would be uploaded in a dry run are missing
+	Files map[string]string
+}
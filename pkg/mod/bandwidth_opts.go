@@ -0,0 +1,10 @@
+package mod
+
+// BandwidthOpts are the limits of a bandwidth limiter, a limit of 0 is unlimited
+type BandwidthOpts struct {
+	// UploadBytesPerSecond is how fast request bodies are sent, bursting up to one second worth of bytes
+	UploadBytesPerSecond int64
+
+	// DownloadBytesPerSecond is how fast response bodies are read, bursting up to one second worth of bytes
+	DownloadBytesPerSecond int64
+}
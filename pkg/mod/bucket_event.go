@@ -0,0 +1,48 @@
+package mod
+
+// BucketEventType is the kind of change in a BucketEvent
+type BucketEventType int
+
+const (
+	// FileAdded - a file was added to the bucket
+	FileAdded BucketEventType = iota
+
+	// FileRemoved - a file was removed from the bucket
+	FileRemoved
+
+	// FileModified - the options, URL or views of a file changed
+	FileModified
+
+	// AlbumAdded - an album was created in the bucket
+	AlbumAdded
+
+	// AlbumRemoved - an album was deleted from the bucket
+	AlbumRemoved
+
+	// AlbumModified - an album was renamed, shared or revoked
+	AlbumModified
+
+	// BucketWatchError - the bucket could not be fetched, see Err
+	BucketWatchError
+)
+
+// BucketEvent is a change detected between two snapshots of a bucket
+type BucketEvent struct {
+	// Type is the kind of change
+	Type BucketEventType
+
+	// File is the current state of the file, or the last known state if it was removed
+	File *WaifuResponse[int]
+
+	// PreviousFile is the state of the file before it was modified
+	PreviousFile *WaifuResponse[int]
+
+	// Album is the current state of the album, or the last known state if it was removed
+	Album *AlbumStub
+
+	// PreviousAlbum is the state of the album before it was modified
+	PreviousAlbum *AlbumStub
+
+	// Err is the reason the bucket could not be fetched
+	Err error
+}
@@ -0,0 +1,44 @@
+package mod
+
+// ExportBucketOpts configures ExportBucket
+type ExportBucketOpts struct {
+	// IncludeContents writes a tar archive containing manifest.json and the contents of every file instead of only the manifest
+	IncludeContents bool
+
+	// Passwords are the passwords of protected files keyed by file token, needed to export their contents.
+	// protected files without a password are left out of the archive and reported in ExportReport.Failures
+	Passwords map[string]string
+}
+
+// ExportReport is the result of ExportBucket
+type ExportReport struct {
+	// Failures maps the tokens of files whose contents could not be exported to the reason
+	Failures map[string]error
+}
+
+// ImportBucketOpts configures ImportBucket
+type ImportBucketOpts struct {
+	// BucketToken is the bucket to import into. if omitted, a new bucket is created
+	BucketToken string
+
+	// Passwords are the passwords of protected files keyed by their old token, the imported files are protected with the same password
+	Passwords map[string]string
+
+	// Expires is applied to every imported file, same format as WaifuvaultPutOpts.Expires
+	Expires string
+}
+
+// ImportReport is the result of ImportBucket
+type ImportReport struct {
+	// BucketToken is the bucket the contents were imported into
+	BucketToken string
+
+	// Files maps old file tokens to new file tokens
+	Files map[string]string
+
+	// Albums maps old album tokens to new album tokens
+	Albums map[string]string
+
+	// Failures maps the tokens of files and albums that could not be imported to the reason
+	Failures map[string]error
+}
@@ -0,0 +1,12 @@
+package mod
+
+import "time"
+
+// BucketFSOpts configures the fs.FS view of a bucket
+type BucketFSOpts struct {
+	// TTL is how long the bucket listing is cached before it is fetched again. defaults to 1 minute
+	TTL time.Duration
+
+	// Passwords are the passwords of protected files keyed by file token, needed to open them
+	Passwords map[string]string
+}
@@ -0,0 +1,69 @@
+package mod
+
+import "time"
+
+// BucketManifestVersion is the version of the manifest written by ExportBucket
+const BucketManifestVersion = 1
+
+// BucketManifest is a snapshot of the contents of a bucket
+type BucketManifest struct {
+	// Version of the manifest format
+	Version int `json:"version"`
+
+	// ExportedAt is when the snapshot was taken
+	ExportedAt time.Time `json:"exportedAt"`
+
+	// BucketToken is the token of the exported bucket
+	BucketToken string `json:"bucketToken"`
+
+	// Files are the files contained in the bucket
+	Files []ManifestFile `json:"files"`
+
+	// Albums are the albums contained in the bucket
+	Albums []ManifestAlbum `json:"albums"`
+}
+
+// ManifestFile is a file in a BucketManifest
+type ManifestFile struct {
+	// Token is the token of the file
+	Token string `json:"token"`
+
+	// URL is the URL of the file
+	URL string `json:"url"`
+
+	// ID is the public ID of the file
+	ID int `json:"id"`
+
+	// Filename is the name used to upload the file again, the epoch and extension if the filename is hidden
+	Filename string `json:"filename"`
+
+	// Options are the options the file was uploaded with
+	Options WaifuResponseOptions `json:"options"`
+
+	// RetentionPeriod is the retention left at the time of the export, in milliseconds
+	RetentionPeriod int `json:"retentionPeriod"`
+
+	// Views is how many people had downloaded the file
+	Views int `json:"views"`
+
+	// Content is the path of the file contents in the archive, empty if the contents were not exported
+	Content string `json:"content,omitempty"`
+}
+
+// ManifestAlbum is an album in a BucketManifest
+type ManifestAlbum struct {
+	// Token is the private token of the album
+	Token string `json:"token"`
+
+	// Name is the name of the album
+	Name string `json:"name"`
+
+	// Shared is true if the album had a public token
+	Shared bool `json:"shared"`
+
+	// DateCreated is the date the album was created (epoch timestamp)
+	DateCreated int64 `json:"dateCreated"`
+
+	// Files are the tokens of the files in the album, in album order
+	Files []string `json:"files"`
+}
@@ -0,0 +1,39 @@
+package mod
+
+// BulkOpts configures DeleteFiles and ModifyFiles
+type BulkOpts struct {
+	// Concurrency is how many requests run at once. defaults to 4
+	Concurrency int
+
+	// DryRun reports the files that would be changed without changing them
+	DryRun bool
+
+	// BucketToken - if supplied, the files of this bucket that pass Filter are included as well as the given tokens.
+	// Filter is required with a bucket, so a forgotten filter never selects every file
+	BucketToken string
+
+	// Filter selects the files of BucketToken, use FilterAll() to select every file
+	Filter FileFilter
+}
+
+// BulkReport is the result of DeleteFiles and ModifyFiles
+type BulkReport struct {
+	// Results has one result per file, the given tokens first followed by the files selected from the bucket
+	Results []BulkResult
+
+	// DryRun is set if nothing was changed
+	DryRun bool
+}
+
+// BulkResult is the outcome of a single file
+type BulkResult struct {
+	// Token is the token of the file
+	Token string
+
+	// File is the file after it was modified, or before it was deleted if it is known.
+	// nil if the file could not be found or could not be modified
+	File *WaifuResponse[int]
+
+	// Err is the reason the file could not be changed, nil if it was changed or would be changed in a dry run
+	Err error
+}
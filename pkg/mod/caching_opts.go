@@ -0,0 +1,12 @@
+package mod
+
+import "time"
+
+// CachingOpts configures the metadata cache
+type CachingOpts struct {
+	// TTL is how long a lookup is cached. defaults to 1 minute
+	TTL time.Duration
+
+	// MaxEntries is how many lookups are cached before the least recently used is evicted. defaults to 1000
+	MaxEntries int
+}
@@ -0,0 +1,47 @@
+package mod
+
+import (
+	"net/http"
+	"time"
+)
+
+// CircuitThreshold decides when a circuit opens and closes again
+type CircuitThreshold struct {
+	// FailureRate is the share of failed requests, between 0 and 1, that opens the circuit. defaults to 0.5
+	FailureRate float64
+
+	// MinRequests is how many requests must be made within Window before the failure rate is checked. defaults to 10
+	MinRequests int
+
+	// Window is how far back requests count towards the failure rate. defaults to 1 minute
+	Window time.Duration
+
+	// OpenTimeout is how long the circuit stays open before trial requests are sent. defaults to 30 seconds
+	OpenTimeout time.Duration
+
+	// HalfOpenRequests is how many trial requests must succeed to close the circuit. defaults to 1
+	HalfOpenRequests int
+}
+
+// CircuitBreakerOpts configures the circuit breaker
+type CircuitBreakerOpts struct {
+	// Default is the threshold of classes that are not in Thresholds, fields that are not set use their defaults
+	Default CircuitThreshold
+
+	// Thresholds are the thresholds of each operation class
+	Thresholds map[OperationClass]CircuitThreshold
+
+	// Classify returns the class of an operation, each class has its own circuit.
+	// defaults to OperationClassRead for operations that only read and OperationClassWrite for the rest
+	Classify func(operation Operation) OperationClass
+
+	// IsFailure decides if a request failed, resp is nil if err is set.
+	// defaults to errors other than cancellation and 5xx responses
+	IsFailure func(operation Operation, resp *http.Response, err error) bool
+
+	// OnStateChange is called after a circuit changes state
+	OnStateChange func(class OperationClass, from, to CircuitState)
+
+	// Now returns the current time, set it to a fake clock in tests. defaults to time.Now
+	Now func() time.Time
+}
@@ -0,0 +1,27 @@
+package mod
+
+// CircuitState is the state of a circuit breaker
+type CircuitState int
+
+const (
+	// CircuitClosed - requests are sent and their failure rate is tracked
+	CircuitClosed CircuitState = iota
+
+	// CircuitOpen - requests fail fast without being sent
+	CircuitOpen
+
+	// CircuitHalfOpen - a few trial requests are sent to check if the instance has recovered
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
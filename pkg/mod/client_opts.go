@@ -0,0 +1,10 @@
+package mod
+
+// ClientOpts configures the client created by NewWaifuvaltApiWithOpts
+type ClientOpts struct {
+	// Middleware wraps every request, the first middleware is the outermost and sees the request first
+	Middleware []Middleware
+
+	// BaseURL is the URL of the WaifuVault instance, for example a self hosted mirror. defaults to https://waifuvault.moe
+	BaseURL string
+}
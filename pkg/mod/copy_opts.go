@@ -0,0 +1,35 @@
+package mod
+
+// CopyOpts configures CopyFile, CopyAlbum and MigrateBucket
+type CopyOpts struct {
+	// Passwords are the passwords of protected files keyed by file token, the copies are protected with the same password
+	Passwords map[string]string
+
+	// Expires is applied to every copied file, same format as WaifuvaultPutOpts.Expires
+	Expires string
+
+	// DeleteOriginals deletes files and albums once they have been copied, turning the copy into a move
+	DeleteOriginals bool
+
+	// AllowOneTimeDownload copies one time download files. downloading them to copy them deletes the original,
+	// so the file is lost if the upload fails. without it they are skipped
+	AllowOneTimeDownload bool
+}
+
+// CopyReport is the result of CopyAlbum and MigrateBucket
+type CopyReport struct {
+	// BucketToken is the bucket the contents were copied into
+	BucketToken string
+
+	// Files maps old file tokens to new file tokens
+	Files map[string]string
+
+	// Albums maps old album tokens to new album tokens
+	Albums map[string]string
+
+	// Failures maps the tokens of files and albums that could not be copied to the reason
+	Failures map[string]error
+
+	// Skipped are the tokens of one time download files that were not copied, see CopyOpts.AllowOneTimeDownload
+	Skipped []string
+}
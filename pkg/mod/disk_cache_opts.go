@@ -0,0 +1,17 @@
+package mod
+
+// DiskCacheOpts configures the on-disk cache of downloaded files
+type DiskCacheOpts struct {
+	// Dir is the directory the cache is kept in, it is created if it does not exist
+	Dir string
+
+	// MaxSize is the total size in bytes of the cached files before the least recently used are evicted. defaults to 1 GiB
+	MaxSize int64
+
+	// AllowProtected caches password protected files, their contents are stored decrypted and only served with the same password
+	AllowProtected bool
+
+	// AllowOneTimeDownload caches one time download files, and files downloaded by Url or Filename as they can not be checked.
+	// A one time download file is only deleted the first time it is downloaded, later downloads are served from the cache
+	AllowOneTimeDownload bool
+}
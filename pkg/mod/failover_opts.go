@@ -0,0 +1,28 @@
+package mod
+
+import (
+	"context"
+	"time"
+)
+
+// FailoverOpts configures the failover client
+type FailoverOpts struct {
+	// Instances are the base URLs of the WaifuVault instances, in order of preference
+	Instances []string
+
+	// Client configures the client of every instance, its BaseURL is ignored
+	Client ClientOpts
+
+	// HealthInterval is how long the result of a health check is trusted. defaults to 30 seconds
+	HealthInterval time.Duration
+
+	// HealthCheck returns an error if the instance at baseURL is not healthy.
+	// defaults to requesting the upload restrictions of the instance through the middleware of Client
+	HealthCheck func(ctx context.Context, baseURL string) error
+
+	// Tokens are tokens recorded earlier, mapped to the base URL of the instance they belong to
+	Tokens map[string]string
+
+	// OnRecord is called when the instance of a token is recorded, to persist it for Tokens
+	OnRecord func(token, baseURL string)
+}
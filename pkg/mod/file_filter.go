@@ -0,0 +1,4 @@
+package mod
+
+// FileFilter reports if a file should be kept when iterating over files
+type FileFilter func(file WaifuResponse[int]) bool
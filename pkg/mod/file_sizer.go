@@ -0,0 +1,11 @@
+package mod
+
+import "context"
+
+// FileSizer is implemented by the clients of this package to request the size of a file without downloading it.
+// It is not part of Waifuvalt, so other implementations of Waifuvalt do not have to provide it
+type FileSizer interface {
+	// GetFileSize - Get the size of the file at options.Url in bytes with a HEAD request, options.Password is sent for
+	// protected files. One time download files must not be requested, the request may use them up
+	GetFileSize(ctx context.Context, options GetFileInfo) (int64, error)
+}
@@ -0,0 +1,14 @@
+package mod
+
+import (
+	"context"
+	"io"
+)
+
+// FileStreamer is implemented by the clients of this package to stream downloads. It is not part of Waifuvalt,
+// so other implementations of Waifuvalt do not have to provide it
+type FileStreamer interface {
+	// GetFileStream - Same as GetFile, but returns the body of the download to be read as a stream, the caller must close it.
+	// The stream also implements Size() int64, which is -1 if the length is unknown, and ContentType() string
+	GetFileStream(ctx context.Context, options GetFileInfo) (io.ReadCloser, error)
+}
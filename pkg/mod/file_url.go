@@ -0,0 +1,42 @@
+package mod
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileURL is a file URL split into its components
+type FileURL struct {
+	// Host is the base URL of the instance serving the file including any path prefix, for example https://waifuvault.moe
+	Host string
+
+	// Epoch is the time the file was uploaded
+	Epoch time.Time
+
+	// Filename is the original filename including the extension, empty if the filename is hidden
+	Filename string
+
+	// Extension is the file extension including the leading dot, for example `.png`. empty if the file has none
+	Extension string
+
+	// Hidden is true if the file was uploaded with a hidden filename
+	Hidden bool
+}
+
+// Path returns the unique identifier of the file (epoch/filename) as used by GetFileInfo.Filename.
+// for example 1710111505084/08.png or 1710111505084.png for hidden filenames
+func (f FileURL) Path() string {
+	epoch := strconv.FormatInt(f.Epoch.UnixMilli(), 10)
+	if f.Hidden {
+		return epoch + f.Extension
+	}
+	return epoch + "/" + url.PathEscape(f.Filename)
+}
+
+// String builds the full URL of the file, it is the inverse of ParseFileURL
+func (f FileURL) String() string {
+	return fmt.Sprintf("%s/f/%s", strings.TrimSuffix(f.Host, "/"), f.Path())
+}
@@ -8,4 +8,7 @@ type GetFileInfo struct {
 	// the filename and the file upload epoch. for example, 1710111505084/08.png.
 	// files with hidden filenames will only contain the epoch with ext. for example, 1710111505084.png
 	Filename string
+	// the full URL of the file. for example, https://waifuvault.moe/f/1710111505084/08.png.
+	// takes precedence over Filename and Token
+	Url string
 }
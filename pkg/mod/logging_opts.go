@@ -0,0 +1,18 @@
+package mod
+
+import "log/slog"
+
+// LoggingOpts configures the logging middleware
+type LoggingOpts struct {
+	// Level is the level of successful requests. defaults to debug
+	Level slog.Leveler
+
+	// ErrorLevel is the level of requests that failed or returned an error status. defaults to warn
+	ErrorLevel slog.Leveler
+
+	// Headers logs the request headers, x-password, authorization and cookie headers are redacted
+	Headers bool
+
+	// Bodies logs JSON request bodies, passwords and tokens are redacted
+	Bodies bool
+}
@@ -0,0 +1,15 @@
+package mod
+
+import "time"
+
+// MetricsCollector receives measurements of the requests made by the client
+type MetricsCollector interface {
+	// ObserveRequest is called once a request has finished, status is 0 if the request failed without a response
+	ObserveRequest(operation Operation, status int, duration time.Duration)
+
+	// ObserveBytes is called with the bytes sent and received by a request
+	ObserveBytes(operation Operation, sent, received int64)
+
+	// ObserveRetry is called before a request is sent again, it matches RetryOpts.OnRetry
+	ObserveRetry(operation Operation, attempt int)
+}
@@ -0,0 +1,19 @@
+package mod
+
+import "net/http"
+
+// RequestHandler sends a request and returns its response
+type RequestHandler func(r *http.Request) (*http.Response, error)
+
+// Middleware wraps every request made by the client. It can change the request, call next zero or more times
+// and change the response. Middlewares must not read the request body unless it can be replayed with r.GetBody
+type Middleware interface {
+	Handle(operation Operation, r *http.Request, next RequestHandler) (*http.Response, error)
+}
+
+// MiddlewareFunc adapts a function to a Middleware
+type MiddlewareFunc func(operation Operation, r *http.Request, next RequestHandler) (*http.Response, error)
+
+func (f MiddlewareFunc) Handle(operation Operation, r *http.Request, next RequestHandler) (*http.Response, error) {
+	return f(operation, r, next)
+}
@@ -0,0 +1,32 @@
+package mod
+
+// Operation is the name of the Waifuvalt method a request is made for
+type Operation string
+
+const (
+	OperationUploadFile          Operation = "UploadFile"
+	OperationFileInfo            Operation = "FileInfo"
+	OperationFileInfoFormatted   Operation = "FileInfoFormatted"
+	OperationDeleteFile          Operation = "DeleteFile"
+	OperationGetFile             Operation = "GetFile"
+	OperationGetFileStream       Operation = "GetFileStream"
+	OperationModifyFile          Operation = "ModifyFile"
+	OperationCreateBucket        Operation = "CreateBucket"
+	OperationGetBucket           Operation = "GetBucket"
+	OperationDeleteBucket        Operation = "DeleteBucket"
+	OperationCreateAlbum         Operation = "CreateAlbum"
+	OperationAssociateFiles      Operation = "AssociateFiles"
+	OperationDisassociateFiles   Operation = "DisassociateFiles"
+	OperationGetAlbum            Operation = "GetAlbum"
+	OperationDeleteAlbum         Operation = "DeleteAlbum"
+	OperationShareAlbum          Operation = "ShareAlbum"
+	OperationRevokeAlbum         Operation = "RevokeAlbum"
+	OperationDownloadAlbum       Operation = "DownloadAlbum"
+	OperationDownloadAlbumStream Operation = "DownloadAlbumStream"
+
+	// OperationHealthCheck is not a Waifuvalt method, it is the health check a FailoverApi sends to its instances
+	OperationHealthCheck Operation = "HealthCheck"
+
+	// OperationFileSize is not a Waifuvalt method, it is the HEAD request sent by GetFileSize for the size of a file
+	OperationFileSize Operation = "FileSize"
+)
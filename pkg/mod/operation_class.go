@@ -0,0 +1,12 @@
+package mod
+
+// OperationClass groups operations that share a circuit breaker
+type OperationClass string
+
+const (
+	// OperationClassRead - operations that only read, such as FileInfo, GetFile and GetBucket
+	OperationClassRead OperationClass = "read"
+
+	// OperationClassWrite - operations that change something, such as UploadFile, DeleteFile and CreateAlbum
+	OperationClassWrite OperationClass = "write"
+)
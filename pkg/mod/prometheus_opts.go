@@ -0,0 +1,11 @@
+package mod
+
+// PrometheusOpts configures the Prometheus metrics collector
+type PrometheusOpts struct {
+	// Namespace is the prefix of every metric name. defaults to waifuvault
+	Namespace string
+
+	// Buckets are the upper bounds in seconds of the latency histogram buckets.
+	// defaults to .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30 and 60
+	Buckets []float64
+}
@@ -0,0 +1,33 @@
+package mod
+
+import (
+	"net/http"
+	"time"
+)
+
+// ProxyHandlerOpts configures the http.Handler that serves files from WaifuVault under your own domain
+type ProxyHandlerOpts struct {
+	// BucketToken allows files in this bucket to be requested by filename as well as by token
+	BucketToken string
+
+	// Tokens are the tokens of files outside BucketToken that can be requested
+	Tokens []string
+
+	// AllowAnyToken serves any file token that is requested. without it, tokens that are not in BucketToken or Tokens
+	// are not found without asking upstream, so the handler can not be used to proxy files of others
+	AllowAnyToken bool
+
+	// Passwords are the passwords of protected files keyed by file token, they are sent upstream and never to the client
+	Passwords map[string]string
+
+	// TTL is how long the file info of a token or filename is cached. defaults to 5 minutes,
+	// names that were not found are cached for at most 10 seconds
+	TTL time.Duration
+
+	// CacheControl is the Cache-Control header sent with files. defaults to "public, max-age=3600",
+	// one time download files are never cached and protected files are sent with "private, no-store"
+	CacheControl string
+
+	// Transport is used to download files. defaults to http.DefaultTransport
+	Transport http.RoundTripper
+}
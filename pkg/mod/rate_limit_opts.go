@@ -0,0 +1,19 @@
+package mod
+
+// RateLimitOpts configures the rate limit middleware, limits that are not set are not enforced
+type RateLimitOpts struct {
+	// RequestsPerSecond is how many requests are started per second
+	RequestsPerSecond float64
+
+	// Burst is how many requests can start at once before RequestsPerSecond applies. defaults to 1
+	Burst int
+
+	// UploadBytesPerSecond is how fast request bodies are sent, bursting up to one second worth of bytes
+	UploadBytesPerSecond int64
+
+	// DownloadBytesPerSecond is how fast response bodies are read, bursting up to one second worth of bytes
+	DownloadBytesPerSecond int64
+
+	// MaxInFlight is how many requests can run at once, a request runs until its response body is closed
+	MaxInFlight int
+}
@@ -0,0 +1,30 @@
+package mod
+
+import "time"
+
+// RecordedSpan is a finished span kept by the in memory span recorder
+type RecordedSpan struct {
+	// Operation is the operation the span was started for
+	Operation Operation
+
+	// TraceID is the hex encoded W3C trace ID
+	TraceID string
+
+	// SpanID is the hex encoded W3C span ID
+	SpanID string
+
+	// ParentSpanID is the span ID of the parent span, empty for a root span
+	ParentSpanID string
+
+	// Attributes are the values set on the span
+	Attributes map[string]any
+
+	// Err is the error the span ended with
+	Err error
+
+	// Start is when the span started
+	Start time.Time
+
+	// End is when the span ended
+	End time.Time
+}
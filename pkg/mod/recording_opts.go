@@ -0,0 +1,7 @@
+package mod
+
+// RecordingOpts configures the token recording client
+type RecordingOpts struct {
+	// PasswordHint returns the hint stored for a protected upload. if omitted, no hint is stored
+	PasswordHint func(password string) string
+}
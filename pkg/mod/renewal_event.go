@@ -0,0 +1,35 @@
+package mod
+
+import "time"
+
+// RenewalEventType is the kind of RenewalEvent
+type RenewalEventType int
+
+const (
+	// RenewalExtended - the expiry of the file was extended with ModifyFile
+	RenewalExtended RenewalEventType = iota
+
+	// RenewalReuploaded - the file could not be extended and was uploaded again from its local copy
+	RenewalReuploaded
+
+	// RenewalFailed - the file could not be checked or renewed, see Err
+	RenewalFailed
+)
+
+// RenewalEvent is emitted by the renewal service
+type RenewalEvent struct {
+	// Type is what happened to the file
+	Type RenewalEventType
+
+	// Token is the token of the file that was checked
+	Token string
+
+	// NewToken is the token of the re-uploaded file, only set for RenewalReuploaded
+	NewToken string
+
+	// Remaining is the retention left after the renewal, or before it if the renewal failed
+	Remaining time.Duration
+
+	// Err is the reason a renewal failed
+	Err error
+}
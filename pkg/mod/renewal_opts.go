@@ -0,0 +1,35 @@
+package mod
+
+import "time"
+
+// RenewalTarget is a file kept alive by the renewal service
+type RenewalTarget struct {
+	// Token is the token of the file
+	Token string
+
+	// LocalPath is a local copy of the file, used to re-upload it if the expiry can not be extended
+	LocalPath string
+
+	// Password of the file, re-uploads are protected with the same password
+	Password string
+}
+
+// RenewalOpts configures the renewal service
+type RenewalOpts struct {
+	// Targets are the files to keep alive
+	Targets []RenewalTarget
+
+	// BucketToken - if supplied, every file in this bucket is also kept alive.
+	// files that are not in Targets have no local copy and can only be extended
+	BucketToken string
+
+	// Interval is how often the retention of each file is checked. defaults to 1 hour
+	Interval time.Duration
+
+	// Threshold - files are renewed when the remaining retention drops below this. defaults to 24 hours
+	Threshold time.Duration
+
+	// Expiry is the new expiry applied to renewed files, same format as WaifuvaultPutOpts.Expires.
+	// Omit this to use the retention policy of the server, files are then not extended but only re-uploaded
+	Expiry string
+}
@@ -0,0 +1,33 @@
+package mod
+
+import "time"
+
+// RetentionAuditEntry is a line of the retention audit log
+type RetentionAuditEntry struct {
+	// Time is when the deletion was planned or executed
+	Time time.Time `json:"time"`
+
+	// BucketToken is the bucket the file was in
+	BucketToken string `json:"bucketToken"`
+
+	// Token is the token of the file, empty if the bucket could not be evaluated
+	Token string `json:"token,omitempty"`
+
+	// URL is the URL of the file
+	URL string `json:"url,omitempty"`
+
+	// Rule is the name of the rule that selected the file
+	Rule string `json:"rule,omitempty"`
+
+	// Reason says why the rule selected the file
+	Reason string `json:"reason,omitempty"`
+
+	// DryRun is set if the file was only planned for deletion
+	DryRun bool `json:"dryRun"`
+
+	// Deleted is set if the file was deleted
+	Deleted bool `json:"deleted"`
+
+	// Error is the reason the file could not be deleted or the bucket could not be evaluated
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,35 @@
+package mod
+
+// RetentionPlan is the outcome of evaluating retention rules against a bucket
+type RetentionPlan struct {
+	// BucketToken is the bucket the plan is for
+	BucketToken string
+
+	// DryRun is set if the deletions were only planned
+	DryRun bool
+
+	// Deletions are the files selected by the rules, oldest first
+	Deletions []RetentionDeletion
+
+	// Errors maps the tokens of files that could not be fully evaluated to the reason.
+	// files without an upload time are never deleted, files without a size count as 0 bytes
+	Errors map[string]error
+}
+
+// RetentionDeletion is a file selected for deletion by a retention rule
+type RetentionDeletion struct {
+	// File is the file to delete
+	File WaifuResponse[int]
+
+	// Rule is the name of the first rule that selected the file
+	Rule string
+
+	// Reason says why the rule selected the file
+	Reason string
+
+	// Deleted is set once the file was deleted
+	Deleted bool
+
+	// Err is the reason the file could not be deleted
+	Err error
+}
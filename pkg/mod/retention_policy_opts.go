@@ -0,0 +1,58 @@
+package mod
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// RetentionRule selects files to delete from a bucket. A file matched by the rule is deleted if any of KeepLast,
+// MaxAge or MaxTotalSize selects it, at least one of them must be set
+type RetentionRule struct {
+	// Name identifies the rule in plans and the audit log
+	Name string
+
+	// Match selects the files the rule applies to, defaults to every file
+	Match FileFilter
+
+	// Albums - if supplied, the rule only applies to files in an album whose name matches one of these path.Match patterns
+	Albums []string
+
+	// SkipAlbums - files that are in an album are never deleted by this rule
+	SkipAlbums bool
+
+	// KeepLast keeps the newest KeepLast matched files and deletes the rest
+	KeepLast int
+
+	// MaxAge deletes matched files that were uploaded longer ago than this
+	MaxAge time.Duration
+
+	// MaxTotalSize deletes the oldest matched files until the newest ones add up to at most this many bytes
+	MaxTotalSize int64
+}
+
+// RetentionPolicyOpts configures the retention policy engine
+type RetentionPolicyOpts struct {
+	// BucketToken is the bucket the rules are enforced on
+	BucketToken string
+
+	// Rules are the rules to enforce, a file is deleted if any rule selects it
+	Rules []RetentionRule
+
+	// DryRun plans and audits deletions without deleting anything
+	DryRun bool
+
+	// Interval is how often Start enforces the rules. defaults to 1 hour
+	Interval time.Duration
+
+	// Audit receives a JSON line for every planned or executed deletion, with the bucket and file tokens redacted
+	Audit io.Writer
+
+	// AuditTokens writes the bucket and file tokens to Audit instead of redacting them
+	AuditTokens bool
+
+	// SizeOf returns the size of a file in bytes, it is only used by rules with MaxTotalSize.
+	// defaults to a HEAD request on the file URL sent by the client, through its transport and middleware.
+	// one time download files are not requested and have no size
+	SizeOf func(ctx context.Context, file WaifuResponse[int]) (int64, error)
+}
@@ -0,0 +1,22 @@
+package mod
+
+import (
+	"net/http"
+	"time"
+)
+
+// RetryOpts configures the retry middleware
+type RetryOpts struct {
+	// MaxAttempts is how many times a request is sent in total. defaults to 3
+	MaxAttempts int
+
+	// Backoff is the delay before the first retry, it doubles after every retry. defaults to 500 milliseconds
+	Backoff time.Duration
+
+	// Retryable decides if a failed request is sent again, resp is nil if err is set.
+	// defaults to retrying operations that only read, when the request failed or the response is 429, 502, 503 or 504
+	Retryable func(operation Operation, resp *http.Response, err error) bool
+
+	// OnRetry is called before a request is sent again, attempt is the number of the attempt about to be sent
+	OnRetry func(operation Operation, attempt int)
+}
@@ -0,0 +1,42 @@
+package mod
+
+import "time"
+
+// TokenKind is the kind of entry a TokenRecord belongs to
+type TokenKind string
+
+const (
+	TokenFile   TokenKind = "file"
+	TokenBucket TokenKind = "bucket"
+	TokenAlbum  TokenKind = "album"
+)
+
+// TokenRecord is a token created by the client along with what is known about its entry
+type TokenRecord struct {
+	// Kind is what the token belongs to
+	Kind TokenKind `json:"kind"`
+
+	// Token is the private token of the file, bucket or album
+	Token string `json:"token"`
+
+	// Filename is the name of the uploaded file, or the name of the album
+	Filename string `json:"filename,omitempty"`
+
+	// URL is the URL of the file
+	URL string `json:"url,omitempty"`
+
+	// Bucket is the token of the bucket the file or album belongs to
+	Bucket string `json:"bucket,omitempty"`
+
+	// Album is the token of the album the file is associated with
+	Album string `json:"album,omitempty"`
+
+	// Expires is when the file will be deleted, zero if unknown
+	Expires time.Time `json:"expires,omitempty"`
+
+	// PasswordHint is a reminder of the password of a protected file, never the password itself
+	PasswordHint string `json:"passwordHint,omitempty"`
+
+	// CreatedAt is when the token was recorded
+	CreatedAt time.Time `json:"createdAt"`
+}
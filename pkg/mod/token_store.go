@@ -0,0 +1,16 @@
+package mod
+
+// TokenStore persists the tokens created by the client so they are never lost
+type TokenStore interface {
+	// Put - Add or replace a record
+	Put(record TokenRecord) error
+
+	// Get - Get a record by token, returns nil if the token is unknown
+	Get(token string) (*TokenRecord, error)
+
+	// Delete - Remove a record
+	Delete(token string) error
+
+	// List - Get all records
+	List() ([]TokenRecord, error)
+}
@@ -0,0 +1,33 @@
+package mod
+
+import "context"
+
+// Tracer starts a span for every operation and request made by the client.
+// To use OpenTelemetry, wrap a trace.Tracer and return its span context as the traceparent
+type Tracer interface {
+	// Start starts a span for operation, the returned context is used for the request
+	Start(ctx context.Context, operation Operation) (context.Context, Span)
+}
+
+// Span is a single traced operation or request
+type Span interface {
+	// SetAttribute records a value on the span, values are strings, ints or int64s
+	SetAttribute(key string, value any)
+
+	// TraceParent returns the W3C traceparent header to send with the request, an empty string sends none
+	TraceParent() string
+
+	// End finishes the span, err is set if the operation failed or the request failed without a response
+	End(err error)
+}
+
+// The attributes set on spans
+const (
+	AttributeOperation     = "waifuvault.operation"
+	AttributeTokenPrefix   = "waifuvault.token_prefix"
+	AttributeBucketPrefix  = "waifuvault.bucket_prefix"
+	AttributeMethod        = "http.request.method"
+	AttributeStatus        = "http.response.status_code"
+	AttributeBytesSent     = "waifuvault.bytes_sent"
+	AttributeBytesReceived = "waifuvault.bytes_received"
+)
@@ -0,0 +1,29 @@
+package mod
+
+import "net/http"
+
+// UploadHandlerOpts configures the http.Handler that forwards multipart uploads to WaifuVault
+type UploadHandlerOpts struct {
+	// MaxFileSize is the largest file in bytes that is accepted, 0 for no limit
+	MaxFileSize int64
+
+	// MaxFiles is how many files one request may upload, 0 for no limit
+	MaxFiles int
+
+	// AllowedTypes are the content types that are accepted, sniffed from the start of each file.
+	// A type can end in /* to accept every subtype, for example image/*. Empty accepts every type
+	AllowedTypes []string
+
+	// Upload is applied to every file, for example to set Expires, Password or BucketToken. The file sources must be empty
+	Upload WaifuvaultPutOpts
+
+	// Authorize is called before the request body is read, returning an error rejects the request with 403 Forbidden
+	Authorize func(r *http.Request) error
+
+	// Policy is called for every file and can change its upload options, returning an error rejects the request
+	Policy func(r *http.Request, filename string, options *WaifuvaultPutOpts) error
+
+	// OnUpload is called after every file is uploaded, returning an error fails the request.
+	// This is the only place the token of the uploaded file is available
+	OnUpload func(r *http.Request, resp *WaifuResponse[string]) error
+}
@@ -0,0 +1,28 @@
+package mod
+
+// UploadHandlerResponse is the JSON body returned by the upload handler
+type UploadHandlerResponse struct {
+	// Files are the uploaded files in the order they were sent
+	Files []UploadedFile `json:"files,omitempty"`
+
+	// Error is why the request failed, none of the files are kept if it is set
+	Error string `json:"error,omitempty"`
+}
+
+// UploadedFile is a file uploaded through the upload handler
+type UploadedFile struct {
+	// Field is the name of the form field the file was sent in
+	Field string `json:"field"`
+
+	// Filename is the name of the file that was sent
+	Filename string `json:"filename"`
+
+	// URL is the WaifuVault URL of the file
+	URL string `json:"url"`
+
+	// Size is the size of the file in bytes
+	Size int64 `json:"size"`
+
+	// ContentType is the content type sniffed from the file
+	ContentType string `json:"contentType"`
+}
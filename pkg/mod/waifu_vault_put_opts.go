@@ -1,6 +1,9 @@
 package mod
 
-import "os"
+import (
+	"io"
+	"os"
+)
 
 type WaifuvaultPutOpts struct {
 
@@ -21,10 +24,13 @@ type WaifuvaultPutOpts struct {
 	// the raw bytes of the file
 	Bytes *[]byte
 
+	// a stream of the file contents, it is read while uploading so the file is never fully buffered
+	Reader io.Reader
+
 	//An url to the file you want uploaded
 	Url string
 
-	// The filename if `Bytes` is used
+	// The filename if `Bytes` or `Reader` is used
 	FileName string
 
 	// If this is true, then the file will be deleted as soon as it is accessed
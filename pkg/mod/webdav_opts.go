@@ -0,0 +1,15 @@
+package mod
+
+import "time"
+
+// WebDAVOpts configures the WebDAV view of a bucket
+type WebDAVOpts struct {
+	// TTL is how long the bucket listing is cached before it is fetched again, it is discarded after every change. defaults to 1 minute
+	TTL time.Duration
+
+	// Passwords are the passwords of protected files keyed by file token, needed to download them
+	Passwords map[string]string
+
+	// Expires is the expiry of uploaded files, see WaifuvaultPutOpts.Expires
+	Expires string
+}
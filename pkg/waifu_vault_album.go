@@ -12,7 +12,7 @@ import (
 )
 
 func (re *api) CreateAlbum(ctx context.Context, body mod.WaifuAlbumCreateBody) (*mod.WaifuAlbum, error) {
-	albumUrl := getUrl(nil, fmt.Sprintf("album/%s", body.BucketToken))
+	albumUrl := re.getUrl(nil, fmt.Sprintf("album/%s", body.BucketToken))
 	jsonData, err := json.Marshal(body)
 	if err != nil {
 		return nil, err
@@ -21,7 +21,7 @@ func (re *api) CreateAlbum(ctx context.Context, body mod.WaifuAlbumCreateBody) (
 	if err != nil {
 		return nil, err
 	}
-	resp, err := re.client.Do(r)
+	resp, err := re.do(mod.OperationCreateAlbum, r)
 	if err != nil {
 		return nil, err
 	}
@@ -29,7 +29,7 @@ func (re *api) CreateAlbum(ctx context.Context, body mod.WaifuAlbumCreateBody) (
 }
 
 func (re *api) AssociateFiles(ctx context.Context, albumToken string, filesToAssociate []string) (*mod.WaifuAlbum, error) {
-	albumUrl := getUrl(nil, fmt.Sprintf("album/%s/associate", albumToken))
+	albumUrl := re.getUrl(nil, fmt.Sprintf("album/%s/associate", albumToken))
 	type payload struct {
 		FileTokens []string `json:"fileTokens"`
 	}
@@ -41,7 +41,7 @@ func (re *api) AssociateFiles(ctx context.Context, albumToken string, filesToAss
 	if err != nil {
 		return nil, err
 	}
-	resp, err := re.client.Do(r)
+	resp, err := re.do(mod.OperationAssociateFiles, r)
 	if err != nil {
 		return nil, err
 	}
@@ -49,7 +49,7 @@ func (re *api) AssociateFiles(ctx context.Context, albumToken string, filesToAss
 }
 
 func (re *api) DisassociateFiles(ctx context.Context, albumToken string, filesToDisassociate []string) (*mod.WaifuAlbum, error) {
-	albumUrl := getUrl(nil, fmt.Sprintf("album/%s/disassociate", albumToken))
+	albumUrl := re.getUrl(nil, fmt.Sprintf("album/%s/disassociate", albumToken))
 	type payload struct {
 		FileTokens []string `json:"fileTokens"`
 	}
@@ -61,7 +61,7 @@ func (re *api) DisassociateFiles(ctx context.Context, albumToken string, filesTo
 	if err != nil {
 		return nil, err
 	}
-	resp, err := re.client.Do(r)
+	resp, err := re.do(mod.OperationDisassociateFiles, r)
 	if err != nil {
 		return nil, err
 	}
@@ -69,12 +69,12 @@ func (re *api) DisassociateFiles(ctx context.Context, albumToken string, filesTo
 }
 
 func (re *api) GetAlbum(ctx context.Context, albumToken string) (*mod.WaifuAlbum, error) {
-	albumUrl := getUrl(nil, fmt.Sprintf("album/%s", albumToken))
+	albumUrl := re.getUrl(nil, fmt.Sprintf("album/%s", albumToken))
 	r, err := re.createRequest(ctx, http.MethodGet, albumUrl, nil, nil)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := re.client.Do(r)
+	resp, err := re.do(mod.OperationGetAlbum, r)
 	if err != nil {
 		return nil, err
 	}
@@ -82,12 +82,12 @@ func (re *api) GetAlbum(ctx context.Context, albumToken string) (*mod.WaifuAlbum
 }
 
 func (re *api) DeleteAlbum(ctx context.Context, albumToken string, deleteFiles bool) (*mod.GenericSuccess, error) {
-	albumUrl := getUrl(map[string]any{"deleteFiles": deleteFiles}, fmt.Sprintf("album/%s", albumToken))
+	albumUrl := re.getUrl(map[string]any{"deleteFiles": deleteFiles}, fmt.Sprintf("album/%s", albumToken))
 	r, err := re.createRequest(ctx, http.MethodDelete, albumUrl, nil, nil)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := re.client.Do(r)
+	resp, err := re.do(mod.OperationDeleteAlbum, r)
 	if err != nil {
 		return nil, err
 	}
@@ -95,12 +95,12 @@ func (re *api) DeleteAlbum(ctx context.Context, albumToken string, deleteFiles b
 }
 
 func (re *api) ShareAlbum(ctx context.Context, albumToken string) (string, error) {
-	albumUrl := getUrl(nil, fmt.Sprintf("album/share/%s", albumToken))
+	albumUrl := re.getUrl(nil, fmt.Sprintf("album/share/%s", albumToken))
 	r, err := re.createRequest(ctx, http.MethodGet, albumUrl, nil, nil)
 	if err != nil {
 		return "", err
 	}
-	resp, err := re.client.Do(r)
+	resp, err := re.do(mod.OperationShareAlbum, r)
 	if err != nil {
 		return "", err
 	}
@@ -112,12 +112,12 @@ func (re *api) ShareAlbum(ctx context.Context, albumToken string) (string, error
 }
 
 func (re *api) RevokeAlbum(ctx context.Context, albumToken string) (*mod.GenericSuccess, error) {
-	albumUrl := getUrl(nil, fmt.Sprintf("album/revoke/%s", albumToken))
+	albumUrl := re.getUrl(nil, fmt.Sprintf("album/revoke/%s", albumToken))
 	r, err := re.createRequest(ctx, http.MethodGet, albumUrl, nil, nil)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := re.client.Do(r)
+	resp, err := re.do(mod.OperationRevokeAlbum, r)
 	if err != nil {
 		return nil, err
 	}
@@ -125,7 +125,33 @@ func (re *api) RevokeAlbum(ctx context.Context, albumToken string) (*mod.Generic
 }
 
 func (re *api) DownloadAlbum(ctx context.Context, albumToken string, files []int) ([]byte, error) {
-	albumUrl := getUrl(nil, fmt.Sprintf("album/download/%s", albumToken))
+	body, err := re.downloadAlbum(ctx, mod.OperationDownloadAlbum, albumToken, files)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+func (re *api) DownloadAlbumStream(ctx context.Context, albumToken string, files []int) (io.ReadCloser, error) {
+	return re.downloadAlbum(ctx, mod.OperationDownloadAlbumStream, albumToken, files)
+}
+
+// StreamAlbum downloads an album as a stream with DownloadAlbumStream if client is a mod.AlbumStreamer, as every client
+// of this package is. Other clients download it with DownloadAlbum, and the stream is read from memory
+func StreamAlbum(ctx context.Context, client mod.Waifuvalt, albumToken string, files []int) (io.ReadCloser, error) {
+	if streamer, ok := client.(mod.AlbumStreamer); ok {
+		return streamer.DownloadAlbumStream(ctx, albumToken, files)
+	}
+	content, err := client.DownloadAlbum(ctx, albumToken, files)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+func (re *api) downloadAlbum(ctx context.Context, operation mod.Operation, albumToken string, files []int) (io.ReadCloser, error) {
+	albumUrl := re.getUrl(nil, fmt.Sprintf("album/download/%s", albumToken))
 	jsonData, err := json.Marshal(files)
 	if err != nil {
 		return nil, err
@@ -134,14 +160,14 @@ func (re *api) DownloadAlbum(ctx context.Context, albumToken string, files []int
 	if err != nil {
 		return nil, err
 	}
-	resp, err := re.client.Do(r)
+	resp, err := re.do(operation, r)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 	err = checkError(resp)
 	if err != nil {
+		resp.Body.Close()
 		return nil, err
 	}
-	return io.ReadAll(resp.Body)
+	return resp.Body, nil
 }
@@ -0,0 +1,216 @@
+package waifuVault
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+const (
+	defaultExtractMaxFiles     = 10000
+	defaultExtractMaxTotalSize = 10 << 30
+
+	// archiveEntryOverhead is allowed per entry on top of MaxTotalSize when the archive is downloaded,
+	// for the local and central directory headers and the name of the entry
+	archiveEntryOverhead = 1 << 10
+)
+
+// ErrUnsafeArchive is matched by errors.Is when an album archive has an entry outside the destination,
+// too many entries, entries larger than the limits or is too large to download
+var ErrUnsafeArchive = errors.New("unsafe album archive")
+
+// ExtractAlbum downloads an album or selected files from an album and extracts the ZIP file into destDir, which is created if needed.
+// The archive is streamed to a temporary file instead of being held in memory, and is checked before anything is written.
+// albumToken must be the private token as the album is fetched to map the extracted paths back to file IDs,
+// paths that can not be matched to a file of the album map to 0
+func ExtractAlbum(ctx context.Context, client mod.Waifuvalt, albumToken, destDir string, files []int, opts mod.AlbumExtractOpts) (map[string]int, error) {
+	if opts.MaxFiles <= 0 {
+		opts.MaxFiles = defaultExtractMaxFiles
+	}
+	if opts.MaxTotalSize <= 0 {
+		opts.MaxTotalSize = defaultExtractMaxTotalSize
+	}
+	if opts.MaxFileSize <= 0 || opts.MaxFileSize > opts.MaxTotalSize {
+		opts.MaxFileSize = opts.MaxTotalSize
+	}
+
+	album, err := client.GetAlbum(ctx, albumToken)
+	if err != nil {
+		return nil, err
+	}
+	maxArchiveSize := opts.MaxTotalSize + int64(opts.MaxFiles)*archiveEntryOverhead
+	archive, err := downloadArchive(ctx, client, albumToken, files, opts.TempDir, maxArchiveSize)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+	info, err := archive.Stat()
+	if err != nil {
+		return nil, err
+	}
+	reader, err := zip.NewReader(archive, info.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	if err = os.MkdirAll(destDir, 0o755); err != nil {
+		return nil, err
+	}
+	// every file operation goes through root, so neither entries nor existing symlinks can escape destDir
+	root, err := os.OpenRoot(destDir)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+	if err = checkArchive(root, reader.File, opts); err != nil {
+		return nil, err
+	}
+
+	ids := albumFileIDs(album, files)
+	extracted := map[string]int{}
+	var total int64
+	for _, entry := range reader.File {
+		if err = ctx.Err(); err != nil {
+			return extracted, err
+		}
+		if entry.FileInfo().IsDir() {
+			if err = root.MkdirAll(entry.Name, 0o755); err != nil {
+				return extracted, err
+			}
+			continue
+		}
+		if opts.Overwrite == mod.OverwriteSkip {
+			if _, err = root.Lstat(entry.Name); err == nil {
+				continue
+			}
+		}
+		written, err := extractEntry(root, entry, min(opts.MaxFileSize, opts.MaxTotalSize-total))
+		if err != nil {
+			return extracted, err
+		}
+		total += written
+
+		id := 0
+		if queue := ids[entry.Name]; len(queue) > 0 {
+			id, ids[entry.Name] = queue[0], queue[1:]
+		}
+		extracted[filepath.Join(destDir, filepath.FromSlash(entry.Name))] = id
+	}
+	return extracted, nil
+}
+
+// downloadArchive streams the album archive to a temporary file, the caller must close and remove it.
+// The download fails once it is larger than maxSize, so a huge archive does not fill the disk
+func downloadArchive(ctx context.Context, client mod.Waifuvalt, albumToken string, files []int, tempDir string, maxSize int64) (*os.File, error) {
+	body, err := StreamAlbum(ctx, client, albumToken, files)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	archive, err := os.CreateTemp(tempDir, "waifuvault-album-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	written, err := io.Copy(archive, io.LimitReader(body, maxSize+1))
+	if err == nil && written > maxSize {
+		err = fmt.Errorf("%w: the archive is larger than %d bytes", ErrUnsafeArchive, maxSize)
+	}
+	if err != nil {
+		archive.Close()
+		os.Remove(archive.Name())
+		return nil, err
+	}
+	return archive, nil
+}
+
+// checkArchive rejects archives that are unsafe or would overwrite files before anything is extracted.
+// The sizes in the headers can lie, so extractEntry enforces them again while writing
+func checkArchive(root *os.Root, entries []*zip.File, opts mod.AlbumExtractOpts) error {
+	if len(entries) > opts.MaxFiles {
+		return fmt.Errorf("%w: %d entries is more than %d", ErrUnsafeArchive, len(entries), opts.MaxFiles)
+	}
+	var total uint64
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name, "/")
+		if !filepath.IsLocal(filepath.FromSlash(name)) || strings.Contains(name, `\`) {
+			return fmt.Errorf("%w: %s is outside the destination", ErrUnsafeArchive, entry.Name)
+		}
+		mode := entry.FileInfo().Mode()
+		if !mode.IsRegular() && !mode.IsDir() {
+			return fmt.Errorf("%w: %s is not a regular file", ErrUnsafeArchive, entry.Name)
+		}
+		if mode.IsDir() {
+			continue
+		}
+		total += entry.UncompressedSize64
+		if entry.UncompressedSize64 > uint64(opts.MaxFileSize) || total > uint64(opts.MaxTotalSize) {
+			return fmt.Errorf("%w: %s is larger than the size limit", ErrUnsafeArchive, entry.Name)
+		}
+		if opts.Overwrite == mod.OverwriteNever {
+			if _, err := root.Lstat(entry.Name); err == nil {
+				return fmt.Errorf("%s: %w", entry.Name, fs.ErrExist)
+			}
+		}
+	}
+	return nil
+}
+
+// extractEntry writes an entry next to its destination and renames it into place, so a failed entry never leaves a partial file
+func extractEntry(root *os.Root, entry *zip.File, limit int64) (int64, error) {
+	if err := root.MkdirAll(path.Dir(entry.Name), 0o755); err != nil {
+		return 0, err
+	}
+	content, err := entry.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer content.Close()
+
+	tempName := path.Join(path.Dir(entry.Name), "."+path.Base(entry.Name)+"."+randomHex(4)+".tmp")
+	file, err := root.OpenFile(tempName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	written, err := io.Copy(file, io.LimitReader(content, limit+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && written > limit {
+		err = fmt.Errorf("%w: %s is larger than the size limit", ErrUnsafeArchive, entry.Name)
+	}
+	if err == nil {
+		err = root.Rename(tempName, entry.Name)
+	}
+	if err != nil {
+		root.Remove(tempName)
+		return 0, err
+	}
+	return written, nil
+}
+
+// albumFileIDs maps the filenames of the requested album files to their IDs in album order
+func albumFileIDs(album *mod.WaifuAlbum, files []int) map[string][]int {
+	requested := make(map[int]bool, len(files))
+	for _, id := range files {
+		requested[id] = true
+	}
+	ids := map[string][]int{}
+	for _, file := range album.Files {
+		if len(files) > 0 && !requested[file.ID] {
+			continue
+		}
+		name := uploadFilename(file)
+		ids[name] = append(ids[name], file.ID)
+	}
+	return ids
+}
@@ -0,0 +1,176 @@
+package waifuVault
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+// archiveApi serves a fixed ZIP file for every album
+type archiveApi struct {
+	mod.Waifuvalt
+	archive []byte
+}
+
+func (re *archiveApi) GetAlbum(_ context.Context, albumToken string) (*mod.WaifuAlbum, error) {
+	return &mod.WaifuAlbum{Token: albumToken}, nil
+}
+
+func (re *archiveApi) DownloadAlbumStream(context.Context, string, []int) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(re.archive)), nil
+}
+
+func TestExtractAlbum(t *testing.T) {
+	ctx := context.Background()
+	api := NewWaifuvaltApi(http.Client{})
+
+	newArchive := func(entries map[string]string) []byte {
+		var buf bytes.Buffer
+		writer := zip.NewWriter(&buf)
+		for name, content := range entries {
+			entry, _ := writer.Create(name)
+			entry.Write([]byte(content))
+		}
+		writer.Close()
+		return buf.Bytes()
+	}
+
+	t.Run("should extract files and map them to their IDs", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		first := fv.addFile(bucket, "08.png", []byte("first"), mod.WaifuResponseOptions{}, "")
+		second := fv.addFile(bucket, "09.png", []byte("second"), mod.WaifuResponseOptions{}, "")
+		third := fv.addFile(bucket, "10.png", []byte("third"), mod.WaifuResponseOptions{}, "")
+		album := fv.addAlbum(bucket, "album", first, second, third)
+		firstID, thirdID := fv.file(first).response.ID, fv.file(third).response.ID
+		dest := filepath.Join(t.TempDir(), "album")
+
+		extracted, err := ExtractAlbum(ctx, api, album, dest, []int{firstID, thirdID}, mod.AlbumExtractOpts{TempDir: t.TempDir()})
+		if err != nil {
+			t.Fatalf("ExtractAlbum failed: %v", err)
+		}
+		expected := map[string]int{filepath.Join(dest, "08.png"): firstID, filepath.Join(dest, "10.png"): thirdID}
+		if len(extracted) != len(expected) {
+			t.Fatalf("Expected %v, got %v", expected, extracted)
+		}
+		for path, id := range expected {
+			if extracted[path] != id {
+				t.Errorf("Expected %s to map to %d, got %d", path, id, extracted[path])
+			}
+		}
+		if content, _ := os.ReadFile(filepath.Join(dest, "10.png")); string(content) != "third" {
+			t.Errorf("Expected third, got %s", content)
+		}
+		if _, err = os.Stat(filepath.Join(dest, "09.png")); err == nil {
+			t.Errorf("Expected 09.png not to be extracted")
+		}
+	})
+
+	t.Run("should download with DownloadAlbum for other clients", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		album := fv.addAlbum(bucket, "album", fv.addFile(bucket, "08.png", []byte("image"), mod.WaifuResponseOptions{}, ""))
+		other := struct{ mod.Waifuvalt }{api}
+		dest := t.TempDir()
+
+		extracted, err := ExtractAlbum(ctx, other, album, dest, nil, mod.AlbumExtractOpts{})
+		if err != nil {
+			t.Fatalf("ExtractAlbum failed: %v", err)
+		}
+		if content, _ := os.ReadFile(filepath.Join(dest, "08.png")); len(extracted) != 1 || string(content) != "image" {
+			t.Errorf("Expected 08.png to be extracted, got %v", extracted)
+		}
+	})
+
+	t.Run("should apply the overwrite policy", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		album := fv.addAlbum(bucket, "album",
+			fv.addFile(bucket, "08.png", []byte("new"), mod.WaifuResponseOptions{}, ""),
+			fv.addFile(bucket, "09.png", []byte("new"), mod.WaifuResponseOptions{}, ""),
+		)
+		dest := t.TempDir()
+		os.WriteFile(filepath.Join(dest, "09.png"), []byte("old"), 0o644)
+
+		if _, err := ExtractAlbum(ctx, api, album, dest, nil, mod.AlbumExtractOpts{}); !errors.Is(err, fs.ErrExist) {
+			t.Errorf("Expected fs.ErrExist, got %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(dest, "08.png")); err == nil {
+			t.Errorf("Expected nothing to be extracted")
+		}
+
+		extracted, err := ExtractAlbum(ctx, api, album, dest, nil, mod.AlbumExtractOpts{Overwrite: mod.OverwriteSkip})
+		if err != nil {
+			t.Fatalf("ExtractAlbum failed: %v", err)
+		}
+		if content, _ := os.ReadFile(filepath.Join(dest, "09.png")); len(extracted) != 1 || string(content) != "old" {
+			t.Errorf("Expected 09.png to be skipped, got %v", extracted)
+		}
+
+		if _, err = ExtractAlbum(ctx, api, album, dest, nil, mod.AlbumExtractOpts{Overwrite: mod.OverwriteReplace}); err != nil {
+			t.Fatalf("ExtractAlbum failed: %v", err)
+		}
+		if content, _ := os.ReadFile(filepath.Join(dest, "09.png")); string(content) != "new" {
+			t.Errorf("Expected 09.png to be replaced, got %s", content)
+		}
+	})
+
+	t.Run("should reject entries outside the destination", func(t *testing.T) {
+		parent := t.TempDir()
+		dest := filepath.Join(parent, "album")
+		client := &archiveApi{archive: newArchive(map[string]string{"safe.png": "image", "../evil.sh": "evil"})}
+
+		if _, err := ExtractAlbum(ctx, client, "album", dest, nil, mod.AlbumExtractOpts{}); !errors.Is(err, ErrUnsafeArchive) {
+			t.Errorf("Expected ErrUnsafeArchive, got %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(parent, "evil.sh")); err == nil {
+			t.Errorf("Expected evil.sh not to be written")
+		}
+		if _, err := os.Stat(filepath.Join(dest, "safe.png")); err == nil {
+			t.Errorf("Expected nothing to be extracted")
+		}
+	})
+
+	t.Run("should enforce the size limits", func(t *testing.T) {
+		client := &archiveApi{archive: newArchive(map[string]string{"bomb.bin": string(bytes.Repeat([]byte{0}, 1<<20))})}
+		dest := t.TempDir()
+
+		if _, err := ExtractAlbum(ctx, client, "album", dest, nil, mod.AlbumExtractOpts{MaxTotalSize: 1 << 10}); !errors.Is(err, ErrUnsafeArchive) {
+			t.Errorf("Expected ErrUnsafeArchive, got %v", err)
+		}
+		if entries, _ := os.ReadDir(dest); len(entries) != 0 {
+			t.Errorf("Expected nothing to be extracted, got %v", entries)
+		}
+	})
+
+	t.Run("should stop downloading archives that are too large", func(t *testing.T) {
+		var buf bytes.Buffer
+		writer := zip.NewWriter(&buf)
+		entry, _ := writer.CreateHeader(&zip.FileHeader{Name: "large.bin", Method: zip.Store})
+		entry.Write(bytes.Repeat([]byte{0}, 64<<10))
+		writer.Close()
+		client := &archiveApi{archive: buf.Bytes()}
+		tempDir := t.TempDir()
+
+		_, err := ExtractAlbum(ctx, client, "album", t.TempDir(), nil, mod.AlbumExtractOpts{
+			MaxFiles:     1,
+			MaxTotalSize: 1 << 10,
+			TempDir:      tempDir,
+		})
+		if !errors.Is(err, ErrUnsafeArchive) {
+			t.Errorf("Expected ErrUnsafeArchive, got %v", err)
+		}
+		if entries, _ := os.ReadDir(tempDir); len(entries) != 0 {
+			t.Errorf("Expected the download to be removed, got %v", entries)
+		}
+	})
+}
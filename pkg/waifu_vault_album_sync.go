@@ -0,0 +1,210 @@
+package waifuVault
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+// SyncAlbum makes an album mirror the files of a directory. Files missing from the album are uploaded into its bucket
+// and associated, files that are no longer in the directory are removed from the album and the album is kept ordered by filename.
+// Files are matched by filename and size, a file whose size changed is uploaded again and replaces the old one in the album,
+// so syncing an unchanged directory again does nothing. Subdirectories are ignored,
+// and album files with a hidden filename can not be matched so they are treated as no longer in the directory.
+// If associating the files fails the album is restored and the new uploads are deleted, so a later sync starts over
+func SyncAlbum(ctx context.Context, client mod.Waifuvalt, albumToken, dir string, opts mod.AlbumSyncOpts) (*mod.AlbumSyncReport, error) {
+	names, err := syncFilenames(dir, opts.Include)
+	if err != nil {
+		return nil, err
+	}
+	album, err := client.GetAlbum(ctx, albumToken)
+	if err != nil {
+		return nil, err
+	}
+
+	local := make(map[string]bool, len(names))
+	for _, name := range names {
+		local[name] = true
+	}
+	tokens := map[string]string{}
+	sizeErrors := map[string]error{}
+	var kept, removed, replaced []string
+	var removedTokens, replacedTokens []string
+	for _, file := range album.Files {
+		name := uploadFilename(file)
+		if _, duplicate := tokens[name]; duplicate || slices.Contains(replaced, name) || !local[name] {
+			removed = append(removed, name)
+			removedTokens = append(removedTokens, file.Token)
+			continue
+		}
+		changed, err := syncChanged(ctx, client, file, filepath.Join(dir, name), opts.Password)
+		if err != nil {
+			sizeErrors[name] = err
+		}
+		if changed {
+			replaced = append(replaced, name)
+			replacedTokens = append(replacedTokens, file.Token)
+			continue
+		}
+		tokens[name] = file.Token
+		kept = append(kept, name)
+	}
+
+	// files at the start of the album that are already in filename order stay, everything from the first difference is added again
+	settled := 0
+	var pending, uploads []string
+	for _, name := range names {
+		if len(pending) == 0 && settled < len(kept) && kept[settled] == name {
+			settled++
+			continue
+		}
+		pending = append(pending, name)
+		if _, found := tokens[name]; !found {
+			uploads = append(uploads, name)
+		}
+	}
+	reordered := kept[settled:]
+
+	report := &mod.AlbumSyncReport{AlbumToken: albumToken, DryRun: opts.DryRun, Files: map[string]string{}, Errors: sizeErrors}
+	for name, token := range tokens {
+		report.Files[name] = token
+	}
+	if opts.DryRun {
+		report.Uploaded, report.Replaced, report.Removed, report.Reordered = uploads, replaced, removed, reordered
+		return report, nil
+	}
+
+	// the uploads are not in the album yet, so they are deleted on failure and a later sync uploads them again
+	undoUploads := func() {
+		for _, uploadedName := range report.Uploaded {
+			client.DeleteFile(ctx, report.Files[uploadedName])
+			delete(report.Files, uploadedName)
+		}
+		report.Uploaded = nil
+	}
+	for _, name := range uploads {
+		uploaded, err := syncUpload(ctx, client, album.BucketToken, filepath.Join(dir, name), opts)
+		if err != nil {
+			undoUploads()
+			return report, fmt.Errorf("unable to upload %s: %w", name, err)
+		}
+		tokens[name] = uploaded.Token
+		report.Files[name] = uploaded.Token
+		report.Uploaded = append(report.Uploaded, name)
+	}
+
+	disassociated := map[string]bool{}
+	for _, token := range slices.Concat(removedTokens, replacedTokens) {
+		disassociated[token] = true
+	}
+	for _, name := range reordered {
+		disassociated[tokens[name]] = true
+	}
+	// the files are disassociated and associated again in album order, so a failure can put them back where they were
+	var disassociate []string
+	for _, file := range album.Files {
+		if disassociated[file.Token] {
+			disassociate = append(disassociate, file.Token)
+		}
+	}
+	if len(disassociate) > 0 {
+		if _, err = client.DisassociateFiles(ctx, albumToken, disassociate); err != nil {
+			undoUploads()
+			return report, err
+		}
+	}
+
+	if len(pending) > 0 {
+		associate := make([]string, len(pending))
+		for i, name := range pending {
+			associate[i] = tokens[name]
+		}
+		if _, err = client.AssociateFiles(ctx, albumToken, associate); err != nil {
+			if len(disassociate) > 0 {
+				if _, restoreErr := client.AssociateFiles(ctx, albumToken, disassociate); restoreErr != nil {
+					err = fmt.Errorf("%w, and the removed files could not be restored: %w", err, restoreErr)
+				}
+			}
+			undoUploads()
+			return report, err
+		}
+	}
+	report.Replaced, report.Removed, report.Reordered = replaced, removed, reordered
+
+	if opts.DeleteRemoved {
+		deleted := slices.Concat(removed, replaced)
+		for i, token := range slices.Concat(removedTokens, replacedTokens) {
+			if _, err = client.DeleteFile(ctx, token); err != nil {
+				return report, fmt.Errorf("unable to delete %s: %w", deleted[i], err)
+			}
+		}
+	}
+	return report, nil
+}
+
+// syncChanged reports whether the size of a local file differs from the size of the album file it is matched with,
+// the size is requested with password. One time download files can not be requested and clients that can not request
+// sizes are never changed
+func syncChanged(ctx context.Context, client mod.Waifuvalt, file mod.WaifuResponse[int], filePath, password string) (bool, error) {
+	if _, ok := client.(mod.FileSizer); !ok || file.Options.OneTimeDownload {
+		return false, nil
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return false, err
+	}
+	size, err := FileSize(ctx, client, mod.GetFileInfo{Url: file.URL, Password: password})
+	if err != nil {
+		return false, fmt.Errorf("unable to get the size of %s: %w", file.Token, err)
+	}
+	return size != info.Size(), nil
+}
+
+// syncFilenames returns the names of the regular files in dir that match include, sorted by filename
+func syncFilenames(dir, include string) ([]string, error) {
+	if include != "" {
+		if _, err := path.Match(include, ""); err != nil {
+			return nil, err
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if include != "" {
+			if matched, _ := path.Match(include, entry.Name()); !matched {
+				continue
+			}
+		}
+		// Stat follows symlinks, so linked files are synced too
+		info, err := os.Stat(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if info.Mode().IsRegular() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+func syncUpload(ctx context.Context, client mod.Waifuvalt, bucketToken, filePath string, opts mod.AlbumSyncOpts) (*mod.WaifuResponse[string], error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return client.UploadFile(ctx, mod.WaifuvaultPutOpts{
+		File:        file,
+		Expires:     opts.Expires,
+		Password:    opts.Password,
+		BucketToken: bucketToken,
+	})
+}
@@ -0,0 +1,197 @@
+package waifuVault
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+func TestSyncAlbum(t *testing.T) {
+	ctx := context.Background()
+	api := NewWaifuvaltApi(http.Client{})
+
+	writeFiles := func(t *testing.T, names ...string) string {
+		dir := t.TempDir()
+		for _, name := range names {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644); err != nil {
+				t.Fatalf("WriteFile failed: %v", err)
+			}
+		}
+		return dir
+	}
+	albumNames := func(fv *fakeVault, album string) []string {
+		var names []string
+		for _, token := range fv.album(album).files {
+			names = append(names, fv.file(token).name)
+		}
+		return names
+	}
+
+	t.Run("should upload missing files in filename order", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		album := fv.addAlbum(bucket, "album")
+		dir := writeFiles(t, "b.png", "a.png", "notes.txt")
+		os.Mkdir(filepath.Join(dir, "nested"), 0o755)
+
+		report, err := SyncAlbum(ctx, api, album, dir, mod.AlbumSyncOpts{Include: "*.png"})
+		if err != nil {
+			t.Fatalf("SyncAlbum failed: %v", err)
+		}
+		if !slices.Equal(report.Uploaded, []string{"a.png", "b.png"}) || len(report.Files) != 2 {
+			t.Errorf("Expected a.png and b.png to be uploaded, got %+v", report)
+		}
+		if names := albumNames(fv, album); !slices.Equal(names, []string{"a.png", "b.png"}) {
+			t.Errorf("Expected the album to be a.png, b.png, got %v", names)
+		}
+		if fv.file(report.Files["a.png"]).response.Bucket != bucket {
+			t.Errorf("Expected the files to be uploaded into the album bucket")
+		}
+
+		uploads := fv.requestCount(http.MethodPut, "/rest")
+		report, err = SyncAlbum(ctx, api, album, dir, mod.AlbumSyncOpts{Include: "*.png"})
+		if err != nil {
+			t.Fatalf("SyncAlbum failed: %v", err)
+		}
+		if len(report.Uploaded)+len(report.Removed)+len(report.Reordered) != 0 || fv.requestCount(http.MethodPut, "/rest") != uploads {
+			t.Errorf("Expected a second sync to change nothing, got %+v", report)
+		}
+	})
+
+	t.Run("should remove and reorder files", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		b := fv.addFile(bucket, "b.png", []byte("b.png"), mod.WaifuResponseOptions{}, "")
+		a := fv.addFile(bucket, "a.png", []byte("a.png"), mod.WaifuResponseOptions{}, "")
+		old := fv.addFile(bucket, "old.png", []byte("old"), mod.WaifuResponseOptions{}, "")
+		album := fv.addAlbum(bucket, "album", b, a, old)
+		dir := writeFiles(t, "a.png", "b.png", "c.png")
+
+		report, err := SyncAlbum(ctx, api, album, dir, mod.AlbumSyncOpts{DeleteRemoved: true})
+		if err != nil {
+			t.Fatalf("SyncAlbum failed: %v", err)
+		}
+		if !slices.Equal(report.Removed, []string{"old.png"}) || !slices.Equal(report.Reordered, []string{"b.png", "a.png"}) {
+			t.Errorf("Expected old.png to be removed and the rest reordered, got %+v", report)
+		}
+		if names := albumNames(fv, album); !slices.Equal(names, []string{"a.png", "b.png", "c.png"}) {
+			t.Errorf("Expected the album to be a.png, b.png, c.png, got %v", names)
+		}
+		if fv.file(old) != nil || report.Files["a.png"] != a || report.Files["b.png"] != b {
+			t.Errorf("Expected old.png to be deleted and the existing files kept")
+		}
+	})
+
+	t.Run("should replace files whose size changed", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		a := fv.addFile(bucket, "a.png", []byte("a.png"), mod.WaifuResponseOptions{}, "")
+		b := fv.addFile(bucket, "b.png", []byte("old"), mod.WaifuResponseOptions{}, "")
+		album := fv.addAlbum(bucket, "album", a, b)
+		dir := writeFiles(t, "a.png", "b.png")
+
+		report, err := SyncAlbum(ctx, api, album, dir, mod.AlbumSyncOpts{DeleteRemoved: true})
+		if err != nil {
+			t.Fatalf("SyncAlbum failed: %v", err)
+		}
+		if !slices.Equal(report.Replaced, []string{"b.png"}) || !slices.Equal(report.Uploaded, []string{"b.png"}) || len(report.Removed) != 0 {
+			t.Errorf("Expected b.png to be replaced, got %+v", report)
+		}
+		if names := albumNames(fv, album); !slices.Equal(names, []string{"a.png", "b.png"}) {
+			t.Errorf("Expected the album to be a.png, b.png, got %v", names)
+		}
+		if fv.file(b) != nil || report.Files["a.png"] != a || string(fv.file(report.Files["b.png"]).content) != "b.png" {
+			t.Errorf("Expected the old b.png to be deleted and a.png kept")
+		}
+	})
+
+	t.Run("should sync protected files again", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		album := fv.addAlbum(bucket, "album")
+		dir := writeFiles(t, "a.png", "b.png")
+
+		if _, err := SyncAlbum(ctx, api, album, dir, mod.AlbumSyncOpts{Password: "pw"}); err != nil {
+			t.Fatalf("SyncAlbum failed: %v", err)
+		}
+		report, err := SyncAlbum(ctx, api, album, dir, mod.AlbumSyncOpts{Password: "pw"})
+		if err != nil {
+			t.Fatalf("SyncAlbum failed: %v", err)
+		}
+		if len(report.Uploaded)+len(report.Replaced)+len(report.Errors) != 0 {
+			t.Errorf("Expected a second sync to change nothing, got %+v", report)
+		}
+	})
+
+	t.Run("should report files whose size can not be requested", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		a := fv.addFile(bucket, "a.png", []byte("a.png"), mod.WaifuResponseOptions{Protected: true}, "other")
+		b := fv.addFile(bucket, "b.png", []byte("old"), mod.WaifuResponseOptions{}, "")
+		album := fv.addAlbum(bucket, "album", a, b)
+		dir := writeFiles(t, "a.png", "b.png")
+
+		report, err := SyncAlbum(ctx, api, album, dir, mod.AlbumSyncOpts{})
+		if err != nil {
+			t.Fatalf("SyncAlbum failed: %v", err)
+		}
+		if report.Errors["a.png"] == nil || report.Files["a.png"] != a || !slices.Equal(report.Replaced, []string{"b.png"}) {
+			t.Errorf("Expected a.png to be kept with an error and b.png replaced, got %+v", report)
+		}
+	})
+
+	t.Run("should restore the album when associating fails", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		b := fv.addFile(bucket, "b.png", []byte("b.png"), mod.WaifuResponseOptions{}, "")
+		old := fv.addFile(bucket, "old.png", []byte("old"), mod.WaifuResponseOptions{}, "")
+		album := fv.addAlbum(bucket, "album", b, old)
+		dir := writeFiles(t, "a.png", "b.png")
+		associations := 0
+		failing := NewWaifuvaltApiWithOpts(http.Client{}, mod.ClientOpts{Middleware: []mod.Middleware{
+			mod.MiddlewareFunc(func(operation mod.Operation, r *http.Request, next mod.RequestHandler) (*http.Response, error) {
+				if operation == mod.OperationAssociateFiles {
+					if associations++; associations == 1 {
+						return statusResponse(http.StatusInternalServerError), nil
+					}
+				}
+				return next(r)
+			}),
+		}})
+
+		report, err := SyncAlbum(ctx, failing, album, dir, mod.AlbumSyncOpts{DeleteRemoved: true})
+		if err == nil {
+			t.Fatalf("Expected SyncAlbum to fail")
+		}
+		if names := albumNames(fv, album); !slices.Equal(names, []string{"b.png", "old.png"}) {
+			t.Errorf("Expected the album to be restored to b.png, old.png, got %v", names)
+		}
+		if fv.file(old) == nil || len(fv.bucketFiles(bucket)) != 2 || len(report.Uploaded)+len(report.Removed) != 0 {
+			t.Errorf("Expected nothing to be deleted and the upload to be undone, got %+v", report)
+		}
+	})
+
+	t.Run("should only report changes in a dry run", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		old := fv.addFile(bucket, "old.png", []byte("old"), mod.WaifuResponseOptions{}, "")
+		album := fv.addAlbum(bucket, "album", old)
+		dir := writeFiles(t, "new.png")
+
+		report, err := SyncAlbum(ctx, api, album, dir, mod.AlbumSyncOpts{DryRun: true, DeleteRemoved: true})
+		if err != nil {
+			t.Fatalf("SyncAlbum failed: %v", err)
+		}
+		if !report.DryRun || !slices.Equal(report.Uploaded, []string{"new.png"}) || !slices.Equal(report.Removed, []string{"old.png"}) {
+			t.Errorf("Expected new.png to be uploaded and old.png removed, got %+v", report)
+		}
+		if fv.file(old) == nil || len(fv.bucketFiles(bucket)) != 1 || len(fv.album(album).files) != 1 {
+			t.Errorf("Expected nothing to change")
+		}
+	})
+}
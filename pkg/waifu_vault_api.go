@@ -9,6 +9,7 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
 )
@@ -17,11 +18,18 @@ var baseUrl = "https://waifuvault.moe"
 
 type api struct {
 	client http.Client
+	opts   mod.ClientOpts
 }
 
 func NewWaifuvaltApi(client http.Client) mod.Waifuvalt {
+	return NewWaifuvaltApiWithOpts(client, mod.ClientOpts{})
+}
+
+// NewWaifuvaltApiWithOpts creates a client configured with opts, for example to add middleware
+func NewWaifuvaltApiWithOpts(client http.Client, opts mod.ClientOpts) mod.Waifuvalt {
 	return &api{
 		client: client,
+		opts:   opts,
 	}
 }
 
@@ -41,8 +49,40 @@ func (re *api) createRequest(ctx context.Context, method, url string, body io.Re
 	return r, nil
 }
 
-func getUrl(obj map[string]any, path string) string {
-	baseRestUrl := fmt.Sprintf("%s/rest", baseUrl)
+// do sends a request through the middleware chain, throttled by the bandwidth limiter of its context
+func (re *api) do(operation mod.Operation, r *http.Request) (*http.Response, error) {
+	next := re.client.Do
+	if limiter := bandwidthLimiterFrom(r.Context()); limiter != nil {
+		send := next
+		next = func(r *http.Request) (*http.Response, error) {
+			return limiter.Handle(operation, r, send)
+		}
+	}
+	for i := len(re.opts.Middleware) - 1; i >= 0; i-- {
+		middleware, inner := re.opts.Middleware[i], next
+		next = func(r *http.Request) (*http.Response, error) {
+			return middleware.Handle(operation, r, inner)
+		}
+	}
+	resp, err := next(r)
+	if err != nil && resp == nil && r.Body != nil {
+		// like http.Client.Do, close the body even if a middleware did not send the request,
+		// otherwise the goroutine streaming an upload into it blocks forever
+		r.Body.Close()
+	}
+	return resp, err
+}
+
+// getBaseUrl returns the base URL of the instance the client talks to
+func (re *api) getBaseUrl() string {
+	if re.opts.BaseURL != "" {
+		return strings.TrimSuffix(re.opts.BaseURL, "/")
+	}
+	return baseUrl
+}
+
+func (re *api) getUrl(obj map[string]any, path string) string {
+	baseRestUrl := fmt.Sprintf("%s/rest", re.getBaseUrl())
 	if path != "" {
 		baseRestUrl = fmt.Sprintf("%s/%s", baseRestUrl, path)
 	}
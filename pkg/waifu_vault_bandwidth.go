@@ -0,0 +1,161 @@
+package waifuVault
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+// BandwidthLimiter throttles request and response bodies. Its limits can be changed while transfers are running.
+// Add it to ClientOpts.Middleware to limit a client, or use WithBandwidthLimiter to limit a single call
+type BandwidthLimiter struct {
+	upload   *tokenBucket
+	download *tokenBucket
+}
+
+type bandwidthLimiterKey struct{}
+
+// NewBandwidthLimiter creates a limiter, limits of 0 are unlimited
+func NewBandwidthLimiter(opts mod.BandwidthOpts) *BandwidthLimiter {
+	return &BandwidthLimiter{
+		upload:   newTokenBucket(float64(opts.UploadBytesPerSecond), float64(opts.UploadBytesPerSecond)),
+		download: newTokenBucket(float64(opts.DownloadBytesPerSecond), float64(opts.DownloadBytesPerSecond)),
+	}
+}
+
+// WithBandwidthLimiter returns a context that limits every request of a call made with it, on top of the client limits
+func WithBandwidthLimiter(ctx context.Context, limiter *BandwidthLimiter) context.Context {
+	return context.WithValue(ctx, bandwidthLimiterKey{}, limiter)
+}
+
+func bandwidthLimiterFrom(ctx context.Context) *BandwidthLimiter {
+	limiter, _ := ctx.Value(bandwidthLimiterKey{}).(*BandwidthLimiter)
+	return limiter
+}
+
+// SetLimits changes the limits, transfers that are running use them from their next read
+func (re *BandwidthLimiter) SetLimits(opts mod.BandwidthOpts) {
+	re.upload.setRate(float64(opts.UploadBytesPerSecond), float64(opts.UploadBytesPerSecond))
+	re.download.setRate(float64(opts.DownloadBytesPerSecond), float64(opts.DownloadBytesPerSecond))
+}
+
+// Limits returns the current limits
+func (re *BandwidthLimiter) Limits() mod.BandwidthOpts {
+	return mod.BandwidthOpts{
+		UploadBytesPerSecond:   int64(re.upload.currentRate()),
+		DownloadBytesPerSecond: int64(re.download.currentRate()),
+	}
+}
+
+func (re *BandwidthLimiter) Handle(operation mod.Operation, r *http.Request, next mod.RequestHandler) (*http.Response, error) {
+	ctx := r.Context()
+	if r.Body != nil && r.Body != http.NoBody {
+		r.Body = &throttledReadCloser{ReadCloser: r.Body, ctx: ctx, bucket: re.upload}
+	}
+	resp, err := next(r)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &throttledReadCloser{ReadCloser: resp.Body, ctx: ctx, bucket: re.download}
+	return resp, nil
+}
+
+// tokenBucket hands out tokens at rate per second, holding at most burst of them. A rate of 0 is unlimited.
+// Tokens are reserved up front, so waiting callers are served in order
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+func (re *tokenBucket) setRate(rate, burst float64) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	re.refill()
+	if re.rate <= 0 || rate <= 0 {
+		re.tokens = burst
+	}
+	re.rate, re.burst = rate, burst
+	re.tokens = math.Min(re.tokens, burst)
+}
+
+func (re *tokenBucket) currentRate() float64 {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	return re.rate
+}
+
+// chunk returns how many tokens can be asked for at once, 0 if there is no limit
+func (re *tokenBucket) chunk() int {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	if re.rate <= 0 {
+		return 0
+	}
+	return max(1, int(re.burst))
+}
+
+func (re *tokenBucket) refill() {
+	now := time.Now()
+	if re.rate > 0 {
+		re.tokens = math.Min(re.burst, re.tokens+now.Sub(re.last).Seconds()*re.rate)
+	}
+	re.last = now
+}
+
+// wait takes n tokens, waiting until they are available or ctx is cancelled
+func (re *tokenBucket) wait(ctx context.Context, n float64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	re.mu.Lock()
+	if re.rate <= 0 {
+		re.mu.Unlock()
+		return nil
+	}
+	re.refill()
+	re.tokens -= n
+	delay := time.Duration(-re.tokens / re.rate * float64(time.Second))
+	re.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	if err := sleepContext(ctx, delay); err != nil {
+		re.mu.Lock()
+		re.tokens += n
+		re.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// throttledReadCloser waits for a token per byte read, reading at most a burst at a time
+type throttledReadCloser struct {
+	io.ReadCloser
+	ctx    context.Context
+	bucket *tokenBucket
+}
+
+func (re *throttledReadCloser) Read(p []byte) (int, error) {
+	if chunk := re.bucket.chunk(); chunk > 0 && len(p) > chunk {
+		p = p[:chunk]
+	}
+	n, err := re.ReadCloser.Read(p)
+	if n > 0 {
+		if waitErr := re.bucket.wait(re.ctx, float64(n)); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,80 @@
+package waifuVault
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+func TestBandwidthLimiter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should limit a single call", func(t *testing.T) {
+		fv := newFakeVault(t)
+		token := fv.addFile("", "08.png", bytes.Repeat([]byte("a"), 1500), mod.WaifuResponseOptions{}, "")
+		api := NewWaifuvaltApi(http.Client{})
+
+		limiter := NewBandwidthLimiter(mod.BandwidthOpts{DownloadBytesPerSecond: 1000})
+		start := time.Now()
+		content, err := api.GetFile(WithBandwidthLimiter(ctx, limiter), mod.GetFileInfo{Token: token})
+		if err != nil {
+			t.Fatalf("GetFile failed: %v", err)
+		}
+		if elapsed := time.Since(start); len(content) != 1500 || elapsed < 400*time.Millisecond {
+			t.Errorf("Expected 1500 bytes to be throttled, got %d bytes in %s", len(content), elapsed)
+		}
+
+		start = time.Now()
+		if _, err = api.GetFile(ctx, mod.GetFileInfo{Token: token}); err != nil {
+			t.Fatalf("GetFile failed: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+			t.Errorf("Expected other calls not to be throttled, took %s", elapsed)
+		}
+	})
+
+	t.Run("should change limits while uploading", func(t *testing.T) {
+		limiter := NewBandwidthLimiter(mod.BandwidthOpts{UploadBytesPerSecond: 100})
+		api := NewWaifuvaltApiWithOpts(http.Client{}, mod.ClientOpts{Middleware: []mod.Middleware{limiter}})
+		fv := newFakeVault(t)
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			limiter.SetLimits(mod.BandwidthOpts{})
+		}()
+		// 2000 bytes at 100 bytes per second would take 20 seconds
+		start := time.Now()
+		content := bytes.Repeat([]byte("a"), 2000)
+		resp, err := api.UploadFile(ctx, mod.WaifuvaultPutOpts{Bytes: &content, FileName: "08.png"})
+		if err != nil {
+			t.Fatalf("UploadFile failed: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second || len(fv.file(resp.Token).content) != 2000 {
+			t.Errorf("Expected the upload to speed up, took %s", elapsed)
+		}
+		if limits := limiter.Limits(); limits != (mod.BandwidthOpts{}) {
+			t.Errorf("Expected no limits, got %+v", limits)
+		}
+	})
+
+	t.Run("should stop waiting when the context is cancelled", func(t *testing.T) {
+		limiter := NewBandwidthLimiter(mod.BandwidthOpts{DownloadBytesPerSecond: 10})
+		ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://waifuvault.moe/f/08.png", nil)
+		resp, err := limiter.Handle(mod.OperationGetFile, req, func(r *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(make([]byte, 100)))}, nil
+		})
+		if err != nil {
+			t.Fatalf("Handle failed: %v", err)
+		}
+		if _, err = io.ReadAll(resp.Body); err != context.DeadlineExceeded {
+			t.Errorf("Expected the context error, got %v", err)
+		}
+	})
+}
@@ -11,12 +11,12 @@ import (
 )
 
 func (re *api) CreateBucket(ctx context.Context) (*mod.WaifuBucket, error) {
-	restUrl := baseUrl + "/rest/bucket/create"
+	restUrl := re.getBaseUrl() + "/rest/bucket/create"
 	r, err := re.createRequest(ctx, http.MethodGet, restUrl, nil, nil)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := re.client.Do(r)
+	resp, err := re.do(mod.OperationCreateBucket, r)
 	if err != nil {
 		return nil, err
 	}
@@ -24,7 +24,7 @@ func (re *api) CreateBucket(ctx context.Context) (*mod.WaifuBucket, error) {
 }
 
 func (re *api) GetBucket(ctx context.Context, token string) (*mod.WaifuBucket, error) {
-	restUrl := baseUrl + "/rest/bucket/get"
+	restUrl := re.getBaseUrl() + "/rest/bucket/get"
 	type payload struct {
 		BucketToken string `json:"bucket_token"`
 	}
@@ -33,7 +33,7 @@ func (re *api) GetBucket(ctx context.Context, token string) (*mod.WaifuBucket, e
 	if err != nil {
 		return nil, err
 	}
-	resp, err := re.client.Do(r)
+	resp, err := re.do(mod.OperationGetBucket, r)
 	if err != nil {
 		return nil, err
 	}
@@ -41,12 +41,15 @@ func (re *api) GetBucket(ctx context.Context, token string) (*mod.WaifuBucket, e
 }
 
 func (re *api) DeleteBucket(ctx context.Context, token string) (bool, error) {
-	deleteUrl := baseUrl + "/rest/bucket/" + token
+	deleteUrl := re.getBaseUrl() + "/rest/bucket/" + token
 	r, err := re.createRequest(ctx, http.MethodDelete, deleteUrl, nil, nil)
 	if err != nil {
 		return false, err
 	}
-	resp, err := re.client.Do(r)
+	resp, err := re.do(mod.OperationDeleteBucket, r)
+	if err != nil {
+		return false, err
+	}
 	defer resp.Body.Close()
 	err = checkError(resp)
 	if err != nil {
@@ -0,0 +1,94 @@
+package waifuVault
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+const defaultBulkConcurrency = 4
+
+// DeleteFiles deletes files concurrently and reports the result of each one. A failed file does not stop the others,
+// the returned error is only set if the bucket in opts could not be listed or has no filter
+func DeleteFiles(ctx context.Context, client mod.Waifuvalt, tokens []string, opts mod.BulkOpts) (*mod.BulkReport, error) {
+	return runBulk(ctx, client, tokens, opts, func(ctx context.Context, target *mod.BulkResult) error {
+		deleted, err := client.DeleteFile(ctx, target.Token)
+		if err == nil && !deleted {
+			err = errors.New("the server did not delete the file")
+		}
+		return err
+	})
+}
+
+// ModifyFiles applies payload to files concurrently and reports the result of each one. A failed file does not stop
+// the others, the returned error is only set if the bucket in opts could not be listed or has no filter
+func ModifyFiles(ctx context.Context, client mod.Waifuvalt, tokens []string, payload mod.ModifyEntryPayload, opts mod.BulkOpts) (*mod.BulkReport, error) {
+	return runBulk(ctx, client, tokens, opts, func(ctx context.Context, target *mod.BulkResult) error {
+		file, err := client.ModifyFile(ctx, target.Token, payload)
+		if err != nil {
+			// the file from the bucket listing would look like it had been modified
+			target.File = nil
+			return err
+		}
+		target.File = file
+		return nil
+	})
+}
+
+// runBulk applies change to every target. In a dry run, targets that are not known from the bucket are looked up instead
+func runBulk(ctx context.Context, client mod.Waifuvalt, tokens []string, opts mod.BulkOpts, change func(ctx context.Context, target *mod.BulkResult) error) (*mod.BulkReport, error) {
+	if opts.BucketToken != "" && opts.Filter == nil {
+		// a forgotten filter would select every file of the bucket
+		return nil, errors.New("a filter is required to select the files of a bucket, use FilterAll() for every file")
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultBulkConcurrency
+	}
+	report := &mod.BulkReport{DryRun: opts.DryRun}
+	seen := map[string]bool{}
+	for _, token := range tokens {
+		if !seen[token] {
+			seen[token] = true
+			report.Results = append(report.Results, mod.BulkResult{Token: token})
+		}
+	}
+	if opts.BucketToken != "" {
+		for file, err := range BucketFiles(ctx, client, opts.BucketToken, opts.Filter) {
+			if err != nil {
+				return nil, err
+			}
+			if seen[file.Token] {
+				continue
+			}
+			seen[file.Token] = true
+			report.Results = append(report.Results, mod.BulkResult{Token: file.Token, File: &file})
+		}
+	}
+
+	work := make(chan *mod.BulkResult)
+	var wg sync.WaitGroup
+	for range min(opts.Concurrency, len(report.Results)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for target := range work {
+				switch {
+				case ctx.Err() != nil:
+					target.Err = ctx.Err()
+				case opts.DryRun && target.File == nil:
+					target.File, target.Err = client.FileInfo(ctx, target.Token)
+				case !opts.DryRun:
+					target.Err = change(ctx, target)
+				}
+			}
+		}()
+	}
+	for i := range report.Results {
+		work <- &report.Results[i]
+	}
+	close(work)
+	wg.Wait()
+	return report, nil
+}
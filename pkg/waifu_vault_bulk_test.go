@@ -0,0 +1,128 @@
+package waifuVault
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+func TestBulk(t *testing.T) {
+	ctx := context.Background()
+	api := NewWaifuvaltApi(http.Client{})
+
+	t.Run("should delete files and report failures", func(t *testing.T) {
+		fv := newFakeVault(t)
+		first := fv.addFile("", "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		second := fv.addFile("", "09.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+
+		report, err := DeleteFiles(ctx, api, []string{first, "missing", second, first}, mod.BulkOpts{Concurrency: 2})
+		if err != nil {
+			t.Fatalf("DeleteFiles failed: %v", err)
+		}
+		if len(report.Results) != 3 || report.DryRun {
+			t.Fatalf("Expected 3 results, got %+v", report)
+		}
+		for _, result := range report.Results {
+			if (result.Token == "missing") != (result.Err != nil) {
+				t.Errorf("Expected only the missing file to fail, got %+v", result)
+			}
+		}
+		if fv.file(first) != nil || fv.file(second) != nil {
+			t.Errorf("Expected the files to be deleted")
+		}
+	})
+
+	t.Run("should select bucket files in a dry run", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		stale := fv.addFile(bucket, "stale.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		popular := fv.addFile(bucket, "popular.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		fv.file(popular).response.Views = 3
+		loose := fv.addFile("", "loose.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+
+		report, err := DeleteFiles(ctx, api, []string{loose}, mod.BulkOpts{
+			DryRun:      true,
+			BucketToken: bucket,
+			Filter:      FilterAll(FilterOlderThan(30*24*time.Hour), FilterMaxViews(0)),
+		})
+		if err != nil {
+			t.Fatalf("DeleteFiles failed: %v", err)
+		}
+		if len(report.Results) != 2 || report.Results[0].Token != loose || report.Results[1].Token != stale || !report.DryRun {
+			t.Fatalf("Expected the loose and stale files, got %+v", report.Results)
+		}
+		if report.Results[0].File == nil || report.Results[0].File.Token != loose {
+			t.Errorf("Expected the loose file to be looked up, got %+v", report.Results[0])
+		}
+		if fv.requestCount(http.MethodDelete, "/rest/") != 0 || len(fv.bucketFiles(bucket)) != 2 {
+			t.Errorf("Expected nothing to be deleted in a dry run")
+		}
+	})
+
+	t.Run("should modify files", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		fv.addFile(bucket, "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		fv.addFile(bucket, "09.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+
+		expiry := "1h"
+		report, err := ModifyFiles(ctx, api, nil, mod.ModifyEntryPayload{CustomExpiry: &expiry}, mod.BulkOpts{BucketToken: bucket, Filter: FilterAll()})
+		if err != nil {
+			t.Fatalf("ModifyFiles failed: %v", err)
+		}
+		if len(report.Results) != 2 {
+			t.Fatalf("Expected 2 results, got %d", len(report.Results))
+		}
+		for _, result := range report.Results {
+			if result.Err != nil || retentionDuration(result.File.RetentionPeriod) != time.Hour {
+				t.Errorf("Expected the expiry to be changed, got %+v", result)
+			}
+		}
+	})
+
+	t.Run("should require a filter to select bucket files", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		token := fv.addFile(bucket, "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+
+		if _, err := DeleteFiles(ctx, api, nil, mod.BulkOpts{BucketToken: bucket}); err == nil {
+			t.Errorf("Expected DeleteFiles to fail without a filter")
+		}
+		if fv.file(token) == nil || fv.requestCount(http.MethodPost, "/rest/bucket/get") != 0 {
+			t.Errorf("Expected the bucket not to be touched")
+		}
+	})
+
+	t.Run("should not report the listed file when a modification fails", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		token := fv.addFile(bucket, "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		failing := NewWaifuvaltApiWithOpts(http.Client{}, mod.ClientOpts{Middleware: []mod.Middleware{
+			mod.MiddlewareFunc(func(operation mod.Operation, r *http.Request, next mod.RequestHandler) (*http.Response, error) {
+				if operation == mod.OperationModifyFile {
+					return statusResponse(http.StatusInternalServerError), nil
+				}
+				return next(r)
+			}),
+		}})
+
+		expiry := "1h"
+		report, err := ModifyFiles(ctx, failing, nil, mod.ModifyEntryPayload{CustomExpiry: &expiry}, mod.BulkOpts{BucketToken: bucket, Filter: FilterAll()})
+		if err != nil {
+			t.Fatalf("ModifyFiles failed: %v", err)
+		}
+		if len(report.Results) != 1 || report.Results[0].Token != token || report.Results[0].Err == nil || report.Results[0].File != nil {
+			t.Errorf("Expected a failure without a file, got %+v", report.Results)
+		}
+	})
+
+	t.Run("should fail if the bucket can not be listed", func(t *testing.T) {
+		newFakeVault(t)
+		if _, err := DeleteFiles(ctx, api, nil, mod.BulkOpts{BucketToken: "missing", Filter: FilterAll()}); err == nil {
+			t.Errorf("Expected DeleteFiles to fail")
+		}
+	})
+}
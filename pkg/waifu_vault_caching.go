@@ -0,0 +1,381 @@
+package waifuVault
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+const (
+	defaultCacheTTL        = time.Minute
+	defaultCacheMaxEntries = 1000
+)
+
+type cachingApi struct {
+	mod.Waifuvalt
+	cache  *lruCache
+	flight flightGroup
+}
+
+// NewCachingApi wraps a client so FileInfo, GetBucket and GetAlbum lookups are cached, and concurrent identical lookups
+// are only requested once. The request keeps the values and the deadline of the first caller's context, every caller
+// stops waiting when its own context is done and the request is cancelled once none is left.
+// Changes made through the wrapped client invalidate the lookups they affect, other changes are seen once the TTL has elapsed
+func NewCachingApi(client mod.Waifuvalt, opts mod.CachingOpts) mod.Waifuvalt {
+	if opts.TTL <= 0 {
+		opts.TTL = defaultCacheTTL
+	}
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = defaultCacheMaxEntries
+	}
+	return &cachingApi{
+		Waifuvalt: client,
+		cache:     newLRUCache(opts.TTL, opts.MaxEntries),
+	}
+}
+
+func (re *cachingApi) FileInfo(ctx context.Context, token string) (*mod.WaifuResponse[int], error) {
+	value, err := re.lookup(ctx, "file:"+token, func(ctx context.Context) (any, error) {
+		return re.Waifuvalt.FileInfo(ctx, token)
+	})
+	if err != nil {
+		return nil, err
+	}
+	file := *value.(*mod.WaifuResponse[int])
+	return &file, nil
+}
+
+func (re *cachingApi) GetBucket(ctx context.Context, token string) (*mod.WaifuBucket, error) {
+	value, err := re.lookup(ctx, "bucket:"+token, func(ctx context.Context) (any, error) {
+		return re.Waifuvalt.GetBucket(ctx, token)
+	})
+	if err != nil {
+		return nil, err
+	}
+	bucket := *value.(*mod.WaifuBucket)
+	bucket.Files = slices.Clone(bucket.Files)
+	bucket.Albums = slices.Clone(bucket.Albums)
+	return &bucket, nil
+}
+
+func (re *cachingApi) GetAlbum(ctx context.Context, albumToken string) (*mod.WaifuAlbum, error) {
+	value, err := re.lookup(ctx, "album:"+albumToken, func(ctx context.Context) (any, error) {
+		return re.Waifuvalt.GetAlbum(ctx, albumToken)
+	})
+	if err != nil {
+		return nil, err
+	}
+	album := *value.(*mod.WaifuAlbum)
+	album.Files = slices.Clone(album.Files)
+	return &album, nil
+}
+
+func (re *cachingApi) UploadFile(ctx context.Context, options mod.WaifuvaultPutOpts) (*mod.WaifuResponse[string], error) {
+	if options.BucketToken != "" {
+		defer re.invalidateBucket(options.BucketToken)
+	}
+	return re.Waifuvalt.UploadFile(ctx, options)
+}
+
+func (re *cachingApi) ModifyFile(ctx context.Context, token string, options mod.ModifyEntryPayload) (*mod.WaifuResponse[int], error) {
+	defer re.invalidateFiles(token)
+	return re.Waifuvalt.ModifyFile(ctx, token, options)
+}
+
+func (re *cachingApi) DeleteFile(ctx context.Context, token string) (bool, error) {
+	defer re.invalidateFiles(token)
+	return re.Waifuvalt.DeleteFile(ctx, token)
+}
+
+func (re *cachingApi) DeleteBucket(ctx context.Context, token string) (bool, error) {
+	defer re.cache.removeIf(func(value any) bool {
+		switch cached := value.(type) {
+		case *mod.WaifuResponse[int]:
+			return cached.Bucket == token
+		case *mod.WaifuBucket:
+			return cached.Token == token
+		case *mod.WaifuAlbum:
+			return cached.BucketToken == token
+		}
+		return false
+	})
+	return re.Waifuvalt.DeleteBucket(ctx, token)
+}
+
+func (re *cachingApi) CreateAlbum(ctx context.Context, body mod.WaifuAlbumCreateBody) (*mod.WaifuAlbum, error) {
+	defer re.invalidateBucket(body.BucketToken)
+	return re.Waifuvalt.CreateAlbum(ctx, body)
+}
+
+func (re *cachingApi) AssociateFiles(ctx context.Context, albumToken string, filesToAssociate []string) (*mod.WaifuAlbum, error) {
+	defer re.invalidateAlbum(albumToken)
+	defer re.invalidateFiles(filesToAssociate...)
+	return re.Waifuvalt.AssociateFiles(ctx, albumToken, filesToAssociate)
+}
+
+func (re *cachingApi) DisassociateFiles(ctx context.Context, albumToken string, filesToDisassociate []string) (*mod.WaifuAlbum, error) {
+	defer re.invalidateAlbum(albumToken)
+	defer re.invalidateFiles(filesToDisassociate...)
+	return re.Waifuvalt.DisassociateFiles(ctx, albumToken, filesToDisassociate)
+}
+
+func (re *cachingApi) DeleteAlbum(ctx context.Context, albumToken string, deleteFiles bool) (*mod.GenericSuccess, error) {
+	if deleteFiles {
+		// the cached album knows which files are deleted, otherwise every file is looked up again
+		if value, found := re.cache.get("album:" + albumToken); found {
+			var tokens []string
+			for _, file := range value.(*mod.WaifuAlbum).Files {
+				tokens = append(tokens, file.Token)
+			}
+			defer re.invalidateFiles(tokens...)
+		} else {
+			defer re.cache.removeIf(func(value any) bool {
+				_, isFile := value.(*mod.WaifuResponse[int])
+				return isFile
+			})
+		}
+	}
+	defer re.invalidateAlbum(albumToken)
+	return re.Waifuvalt.DeleteAlbum(ctx, albumToken, deleteFiles)
+}
+
+func (re *cachingApi) ShareAlbum(ctx context.Context, albumToken string) (string, error) {
+	defer re.invalidateAlbum(albumToken)
+	return re.Waifuvalt.ShareAlbum(ctx, albumToken)
+}
+
+func (re *cachingApi) RevokeAlbum(ctx context.Context, albumToken string) (*mod.GenericSuccess, error) {
+	defer re.invalidateAlbum(albumToken)
+	return re.Waifuvalt.RevokeAlbum(ctx, albumToken)
+}
+
+func (re *cachingApi) GetFileStream(ctx context.Context, options mod.GetFileInfo) (io.ReadCloser, error) {
+	return StreamFile(ctx, re.Waifuvalt, options)
+}
+
+func (re *cachingApi) DownloadAlbumStream(ctx context.Context, albumToken string, files []int) (io.ReadCloser, error) {
+	return StreamAlbum(ctx, re.Waifuvalt, albumToken, files)
+}
+
+func (re *cachingApi) GetFileSize(ctx context.Context, options mod.GetFileInfo) (int64, error) {
+	return FileSize(ctx, re.Waifuvalt, options)
+}
+
+// lookup returns the cached value of key, or fetches it once for every concurrent caller
+func (re *cachingApi) lookup(ctx context.Context, key string, fetch func(ctx context.Context) (any, error)) (any, error) {
+	if value, found := re.cache.get(key); found {
+		return value, nil
+	}
+	return re.flight.do(ctx, key, func(ctx context.Context) (any, error) {
+		generation := re.cache.generation()
+		value, err := fetch(ctx)
+		if err == nil {
+			re.cache.add(key, value, generation)
+		}
+		return value, err
+	})
+}
+
+// invalidateFiles removes the files and every bucket and album containing them
+func (re *cachingApi) invalidateFiles(tokens ...string) {
+	containsFile := func(files []mod.WaifuResponse[int]) bool {
+		return slices.ContainsFunc(files, func(file mod.WaifuResponse[int]) bool {
+			return slices.Contains(tokens, file.Token)
+		})
+	}
+	re.cache.removeIf(func(value any) bool {
+		switch cached := value.(type) {
+		case *mod.WaifuResponse[int]:
+			return slices.Contains(tokens, cached.Token)
+		case *mod.WaifuBucket:
+			return containsFile(cached.Files)
+		case *mod.WaifuAlbum:
+			return containsFile(cached.Files)
+		}
+		return false
+	})
+}
+
+func (re *cachingApi) invalidateBucket(token string) {
+	re.cache.removeIf(func(value any) bool {
+		bucket, ok := value.(*mod.WaifuBucket)
+		return ok && bucket.Token == token
+	})
+}
+
+// invalidateAlbum removes the album and the bucket containing it
+func (re *cachingApi) invalidateAlbum(albumToken string) {
+	re.cache.removeIf(func(value any) bool {
+		switch cached := value.(type) {
+		case *mod.WaifuBucket:
+			return slices.ContainsFunc(cached.Albums, func(album mod.AlbumStub) bool {
+				return album.Token == albumToken
+			})
+		case *mod.WaifuAlbum:
+			return cached.Token == albumToken
+		}
+		return false
+	})
+}
+
+// lruCache is a size bound cache that evicts the least recently used entry, entries expire after the ttl
+type lruCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu          sync.Mutex
+	entries     *list.List
+	items       map[string]*list.Element
+	removeCount uint64
+}
+
+type lruEntry struct {
+	key     string
+	value   any
+	expires time.Time
+}
+
+func newLRUCache(ttl time.Duration, maxEntries int) *lruCache {
+	return &lruCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    list.New(),
+		items:      map[string]*list.Element{},
+	}
+}
+
+func (re *lruCache) get(key string) (any, bool) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	element, found := re.items[key]
+	if !found {
+		return nil, false
+	}
+	entry := element.Value.(*lruEntry)
+	if time.Now().After(entry.expires) {
+		re.remove(element)
+		return nil, false
+	}
+	re.entries.MoveToFront(element)
+	return entry.value, true
+}
+
+// generation changes every time entries are invalidated
+func (re *lruCache) generation() uint64 {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	return re.removeCount
+}
+
+// add caches a value fetched at generation, it is discarded if entries were invalidated since then as it may be stale
+func (re *lruCache) add(key string, value any, generation uint64) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	if generation != re.removeCount {
+		return
+	}
+	if element, found := re.items[key]; found {
+		re.entries.Remove(element)
+	}
+	re.items[key] = re.entries.PushFront(&lruEntry{key: key, value: value, expires: time.Now().Add(re.ttl)})
+	for re.entries.Len() > re.maxEntries {
+		oldest := re.entries.Back()
+		re.entries.Remove(oldest)
+		delete(re.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// removeIf removes every entry whose value matches
+func (re *lruCache) removeIf(match func(value any) bool) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	re.removeCount++
+	for element := re.entries.Front(); element != nil; {
+		next := element.Next()
+		if match(element.Value.(*lruEntry).value) {
+			re.remove(element)
+		}
+		element = next
+	}
+}
+
+func (re *lruCache) remove(element *list.Element) {
+	re.entries.Remove(element)
+	delete(re.items, element.Value.(*lruEntry).key)
+}
+
+// flightGroup runs a function once for every key, concurrent callers with the same key wait for and share its result
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+	value   any
+	err     error
+}
+
+// do runs fn for key unless it is already running, and waits for its result until ctx is done.
+// fn gets a context with the values and the deadline of the first caller, which is not cancelled with it as other
+// callers may still be waiting. It is cancelled once every caller stopped waiting
+func (re *flightGroup) do(ctx context.Context, key string, fn func(ctx context.Context) (any, error)) (any, error) {
+	re.mu.Lock()
+	if re.calls == nil {
+		re.calls = map[string]*flightCall{}
+	}
+	call, found := re.calls[key]
+	if !found {
+		var shared context.Context
+		var cancel context.CancelFunc
+		if deadline, ok := ctx.Deadline(); ok {
+			shared, cancel = context.WithDeadline(context.WithoutCancel(ctx), deadline)
+		} else {
+			shared, cancel = context.WithCancel(context.WithoutCancel(ctx))
+		}
+		call = &flightCall{done: make(chan struct{}), cancel: cancel}
+		re.calls[key] = call
+		go re.run(shared, key, call, fn)
+	}
+	call.waiters++
+	re.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.value, call.err
+	case <-ctx.Done():
+		re.mu.Lock()
+		// the last caller to leave cancels fn, and a later caller starts it again
+		if call.waiters--; call.waiters == 0 && re.calls[key] == call {
+			delete(re.calls, key)
+			call.cancel()
+		}
+		re.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// run calls fn and releases its waiters, a panic in fn is returned to them as an error
+func (re *flightGroup) run(ctx context.Context, key string, call *flightCall, fn func(ctx context.Context) (any, error)) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			call.value, call.err = nil, fmt.Errorf("lookup of %s panicked: %v", key, recovered)
+		}
+		re.mu.Lock()
+		if re.calls[key] == call {
+			delete(re.calls, key)
+		}
+		re.mu.Unlock()
+		call.cancel()
+		close(call.done)
+	}()
+	call.value, call.err = fn(ctx)
+}
@@ -0,0 +1,279 @@
+package waifuVault
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+// blockingInfoApi counts FileInfo calls, which wait until release is closed and fail if their context was cancelled
+type blockingInfoApi struct {
+	mod.Waifuvalt
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (re *blockingInfoApi) FileInfo(ctx context.Context, token string) (*mod.WaifuResponse[int], error) {
+	re.calls.Add(1)
+	<-re.release
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &mod.WaifuResponse[int]{Token: token}, nil
+}
+
+// panickingInfoApi panics on the first FileInfo call
+type panickingInfoApi struct {
+	mod.Waifuvalt
+	calls atomic.Int32
+}
+
+func (re *panickingInfoApi) FileInfo(_ context.Context, token string) (*mod.WaifuResponse[int], error) {
+	if re.calls.Add(1) == 1 {
+		panic("broken client")
+	}
+	return &mod.WaifuResponse[int]{Token: token}, nil
+}
+
+// hangingInfoApi blocks FileInfo until its context is done, and sends the context on started
+type hangingInfoApi struct {
+	mod.Waifuvalt
+	started chan context.Context
+}
+
+func (re *hangingInfoApi) FileInfo(ctx context.Context, _ string) (*mod.WaifuResponse[int], error) {
+	re.started <- ctx
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestCachingApi(t *testing.T) {
+	ctx := context.Background()
+
+	// waiters counts the callers waiting for a lookup of the caching client api
+	waiters := func(api mod.Waifuvalt) int {
+		group := &api.(*cachingApi).flight
+		group.mu.Lock()
+		defer group.mu.Unlock()
+		count := 0
+		for _, call := range group.calls {
+			count += call.waiters
+		}
+		return count
+	}
+
+	t.Run("should cache lookups", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		token := fv.addFile(bucket, "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		album := fv.addAlbum(bucket, "images", token)
+
+		api := NewCachingApi(NewWaifuvaltApi(http.Client{}), mod.CachingOpts{})
+		for range 3 {
+			api.FileInfo(ctx, token)
+			api.GetBucket(ctx, bucket)
+			api.GetAlbum(ctx, album)
+		}
+
+		if fv.requestCount(http.MethodGet, "/rest/"+token) != 1 || fv.requestCount(http.MethodPost, "/rest/bucket/get") != 1 ||
+			fv.requestCount(http.MethodGet, "/rest/album/"+album) != 1 {
+			t.Errorf("Expected every lookup to be requested once")
+		}
+	})
+
+	t.Run("should return copies", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		fv.addFile(bucket, "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+
+		api := NewCachingApi(NewWaifuvaltApi(http.Client{}), mod.CachingOpts{})
+		first, _ := api.GetBucket(ctx, bucket)
+		first.Files[0].Token = "changed"
+		second, _ := api.GetBucket(ctx, bucket)
+
+		if second.Files[0].Token == "changed" {
+			t.Errorf("Expected changes to a result not to change the cache")
+		}
+	})
+
+	t.Run("should invalidate lookups affected by changes", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		token := fv.addFile(bucket, "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		other := fv.addFile(bucket, "09.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		album := fv.addAlbum(bucket, "images")
+
+		api := NewCachingApi(NewWaifuvaltApi(http.Client{}), mod.CachingOpts{})
+		api.FileInfo(ctx, other)
+		api.GetAlbum(ctx, album)
+		api.AssociateFiles(ctx, album, []string{token})
+		resp, _ := api.GetAlbum(ctx, album)
+		if len(resp.Files) != 1 {
+			t.Errorf("Expected the album to be fetched again after associating files, got %d files", len(resp.Files))
+		}
+
+		api.GetBucket(ctx, bucket)
+		api.DeleteFile(ctx, token)
+		bucketResp, _ := api.GetBucket(ctx, bucket)
+		if len(bucketResp.Files) != 1 {
+			t.Errorf("Expected the bucket to be fetched again after deleting a file, got %d files", len(bucketResp.Files))
+		}
+
+		hide := true
+		api.ModifyFile(ctx, other, mod.ModifyEntryPayload{HideFilename: &hide})
+		info, _ := api.FileInfo(ctx, other)
+		if !info.Options.HideFilename || fv.requestCount(http.MethodGet, "/rest/"+other) != 2 {
+			t.Errorf("Expected the file to be fetched again after modifying it")
+		}
+
+		api.DeleteAlbum(ctx, album, false)
+		bucketResp, _ = api.GetBucket(ctx, bucket)
+		if len(bucketResp.Albums) != 0 {
+			t.Errorf("Expected the bucket to be fetched again after deleting an album")
+		}
+	})
+
+	t.Run("should expire lookups", func(t *testing.T) {
+		fv := newFakeVault(t)
+		token := fv.addFile("", "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+
+		api := NewCachingApi(NewWaifuvaltApi(http.Client{}), mod.CachingOpts{TTL: 10 * time.Millisecond})
+		api.FileInfo(ctx, token)
+		time.Sleep(20 * time.Millisecond)
+		api.FileInfo(ctx, token)
+
+		if fv.requestCount(http.MethodGet, "/rest/"+token) != 2 {
+			t.Errorf("Expected the lookup to expire")
+		}
+	})
+
+	t.Run("should evict the least recently used lookup", func(t *testing.T) {
+		fv := newFakeVault(t)
+		a := fv.addFile("", "a.txt", []byte("a"), mod.WaifuResponseOptions{}, "")
+		b := fv.addFile("", "b.txt", []byte("b"), mod.WaifuResponseOptions{}, "")
+		c := fv.addFile("", "c.txt", []byte("c"), mod.WaifuResponseOptions{}, "")
+
+		api := NewCachingApi(NewWaifuvaltApi(http.Client{}), mod.CachingOpts{MaxEntries: 2})
+		for _, token := range []string{a, b, a, c, a, b} {
+			api.FileInfo(ctx, token)
+		}
+
+		if fv.requestCount(http.MethodGet, "/rest/"+a) != 1 || fv.requestCount(http.MethodGet, "/rest/"+b) != 2 {
+			t.Errorf("Expected b to be evicted")
+		}
+	})
+
+	t.Run("should request concurrent lookups once", func(t *testing.T) {
+		client := &blockingInfoApi{release: make(chan struct{})}
+		api := NewCachingApi(client, mod.CachingOpts{})
+
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Go(func() {
+				if info, err := api.FileInfo(ctx, "token"); err != nil || info.Token != "token" {
+					t.Errorf("Unexpected lookup %v, %v", info, err)
+				}
+			})
+		}
+		for client.calls.Load() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(10 * time.Millisecond)
+		close(client.release)
+		wg.Wait()
+
+		if client.calls.Load() != 1 {
+			t.Errorf("Expected 1 lookup, got %d", client.calls.Load())
+		}
+	})
+
+	t.Run("should stop waiting when the context of a caller is done", func(t *testing.T) {
+		client := &blockingInfoApi{release: make(chan struct{})}
+		api := NewCachingApi(client, mod.CachingOpts{})
+
+		first, cancel := context.WithCancel(ctx)
+		cancelled := make(chan error, 1)
+		go func() {
+			_, err := api.FileInfo(first, "token")
+			cancelled <- err
+		}()
+		for client.calls.Load() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		waiting := make(chan error, 1)
+		go func() {
+			_, err := api.FileInfo(ctx, "token")
+			waiting <- err
+		}()
+		for waiters(api) != 2 {
+			time.Sleep(time.Millisecond)
+		}
+
+		cancel()
+		if err := <-cancelled; !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+		close(client.release)
+		if err := <-waiting; err != nil {
+			t.Errorf("Expected the other caller to get the lookup, got %v", err)
+		}
+		if client.calls.Load() != 1 {
+			t.Errorf("Expected 1 lookup, got %d", client.calls.Load())
+		}
+	})
+
+	t.Run("should cancel the lookup once every caller stopped waiting", func(t *testing.T) {
+		client := &hangingInfoApi{started: make(chan context.Context, 1)}
+		api := NewCachingApi(client, mod.CachingOpts{})
+
+		caller, cancel := context.WithCancel(ctx)
+		done := make(chan error, 1)
+		go func() {
+			_, err := api.FileInfo(caller, "token")
+			done <- err
+		}()
+		lookup := <-client.started
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+		select {
+		case <-lookup.Done():
+		case <-time.After(time.Second):
+			t.Fatalf("Expected the lookup to be cancelled")
+		}
+	})
+
+	t.Run("should keep the deadline of the first caller", func(t *testing.T) {
+		client := &hangingInfoApi{started: make(chan context.Context, 1)}
+		api := NewCachingApi(client, mod.CachingOpts{})
+
+		caller, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		go api.FileInfo(caller, "token")
+		lookup := <-client.started
+		deadline, _ := caller.Deadline()
+		if lookupDeadline, ok := lookup.Deadline(); !ok || !lookupDeadline.Equal(deadline) {
+			t.Errorf("Expected the lookup to have the deadline %s, got %s", deadline, lookupDeadline)
+		}
+	})
+
+	t.Run("should return a panic as an error", func(t *testing.T) {
+		client := &panickingInfoApi{}
+		api := NewCachingApi(client, mod.CachingOpts{})
+
+		if _, err := api.FileInfo(ctx, "token"); err == nil {
+			t.Fatalf("Expected the panic to be returned as an error")
+		}
+		info, err := api.FileInfo(ctx, "token")
+		if err != nil || info.Token != "token" {
+			t.Errorf("Expected the next lookup to be requested again, got %v, %v", info, err)
+		}
+	})
+}
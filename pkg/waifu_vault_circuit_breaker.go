@@ -0,0 +1,237 @@
+package waifuVault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+const (
+	defaultCircuitFailureRate      = 0.5
+	defaultCircuitMinRequests      = 10
+	defaultCircuitWindow           = time.Minute
+	defaultCircuitOpenTimeout      = 30 * time.Second
+	defaultCircuitHalfOpenRequests = 1
+)
+
+// ErrCircuitOpen is matched by errors.Is for every CircuitOpenError
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitOpenError is returned instead of sending a request while its circuit is open
+type CircuitOpenError struct {
+	// Class is the class of the open circuit
+	Class mod.OperationClass
+
+	// Operation is the operation that was not sent
+	Operation mod.Operation
+
+	// RetryAt is when trial requests will be sent, zero if trial requests are already running
+	RetryAt time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker for %s operations is open, %s was not sent", e.Class, e.Operation)
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+// CircuitBreaker fails requests fast while the instance is failing, instead of letting every caller wait for its timeout.
+// Each operation class has its own circuit. Put it before RetryMiddleware so retries of a call count once
+type CircuitBreaker struct {
+	opts mod.CircuitBreakerOpts
+
+	mu       sync.Mutex
+	circuits map[mod.OperationClass]*circuit
+}
+
+type circuit struct {
+	threshold mod.CircuitThreshold
+	state     mod.CircuitState
+	openedAt  time.Time
+	outcomes  []circuitOutcome
+	trials    int
+	successes int
+}
+
+type circuitOutcome struct {
+	at     time.Time
+	failed bool
+}
+
+type stateChange struct {
+	class    mod.OperationClass
+	from, to mod.CircuitState
+}
+
+// NewCircuitBreaker creates a circuit breaker with every circuit closed
+func NewCircuitBreaker(opts mod.CircuitBreakerOpts) *CircuitBreaker {
+	if opts.Classify == nil {
+		opts.Classify = defaultClassify
+	}
+	if opts.IsFailure == nil {
+		opts.IsFailure = defaultIsFailure
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &CircuitBreaker{opts: opts, circuits: map[mod.OperationClass]*circuit{}}
+}
+
+// State returns the state of the circuit of class
+func (re *CircuitBreaker) State(class mod.OperationClass) mod.CircuitState {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	if c, ok := re.circuits[class]; ok {
+		return c.state
+	}
+	return mod.CircuitClosed
+}
+
+func (re *CircuitBreaker) Handle(operation mod.Operation, r *http.Request, next mod.RequestHandler) (*http.Response, error) {
+	class := re.opts.Classify(operation)
+
+	re.mu.Lock()
+	c := re.circuit(class)
+	now := re.opts.Now()
+	var changes []stateChange
+	if c.state == mod.CircuitOpen {
+		retryAt := c.openedAt.Add(c.threshold.OpenTimeout)
+		if now.Before(retryAt) {
+			re.mu.Unlock()
+			return nil, &CircuitOpenError{Class: class, Operation: operation, RetryAt: retryAt}
+		}
+		changes = append(changes, c.setState(class, mod.CircuitHalfOpen, now))
+	}
+	trial := c.state == mod.CircuitHalfOpen
+	if trial {
+		if c.trials+c.successes >= c.threshold.HalfOpenRequests {
+			re.mu.Unlock()
+			re.notify(changes)
+			return nil, &CircuitOpenError{Class: class, Operation: operation}
+		}
+		c.trials++
+	}
+	re.mu.Unlock()
+	re.notify(changes)
+
+	resp, err := next(r)
+	cancelled := errors.Is(err, context.Canceled)
+	failed := !cancelled && re.opts.IsFailure(operation, resp, err)
+
+	re.mu.Lock()
+	now = re.opts.Now()
+	changes = nil
+	switch {
+	case trial && c.state == mod.CircuitHalfOpen:
+		c.trials--
+		if failed {
+			changes = append(changes, c.setState(class, mod.CircuitOpen, now))
+		} else if !cancelled {
+			c.successes++
+			if c.successes >= c.threshold.HalfOpenRequests {
+				changes = append(changes, c.setState(class, mod.CircuitClosed, now))
+			}
+		}
+	case !trial && c.state == mod.CircuitClosed && !cancelled:
+		c.outcomes = append(c.outcomes, circuitOutcome{at: now, failed: failed})
+		if c.failing(now) {
+			changes = append(changes, c.setState(class, mod.CircuitOpen, now))
+		}
+	}
+	re.mu.Unlock()
+	re.notify(changes)
+	return resp, err
+}
+
+func (re *CircuitBreaker) circuit(class mod.OperationClass) *circuit {
+	c, ok := re.circuits[class]
+	if !ok {
+		threshold, ok := re.opts.Thresholds[class]
+		if !ok {
+			threshold = re.opts.Default
+		}
+		c = &circuit{threshold: withCircuitDefaults(threshold)}
+		re.circuits[class] = c
+	}
+	return c
+}
+
+func (re *CircuitBreaker) notify(changes []stateChange) {
+	if re.opts.OnStateChange == nil {
+		return
+	}
+	for _, change := range changes {
+		re.opts.OnStateChange(change.class, change.from, change.to)
+	}
+}
+
+func (re *circuit) setState(class mod.OperationClass, state mod.CircuitState, now time.Time) stateChange {
+	change := stateChange{class: class, from: re.state, to: state}
+	re.state = state
+	re.outcomes = nil
+	re.trials = 0
+	re.successes = 0
+	if state == mod.CircuitOpen {
+		re.openedAt = now
+	}
+	return change
+}
+
+// failing drops outcomes outside the window and reports if the rest exceed the failure rate
+func (re *circuit) failing(now time.Time) bool {
+	start := now.Add(-re.threshold.Window)
+	re.outcomes = slices.DeleteFunc(re.outcomes, func(outcome circuitOutcome) bool {
+		return outcome.at.Before(start)
+	})
+	if len(re.outcomes) < re.threshold.MinRequests {
+		return false
+	}
+	failures := 0
+	for _, outcome := range re.outcomes {
+		if outcome.failed {
+			failures++
+		}
+	}
+	return float64(failures)/float64(len(re.outcomes)) >= re.threshold.FailureRate
+}
+
+func withCircuitDefaults(threshold mod.CircuitThreshold) mod.CircuitThreshold {
+	if threshold.FailureRate <= 0 {
+		threshold.FailureRate = defaultCircuitFailureRate
+	}
+	if threshold.MinRequests <= 0 {
+		threshold.MinRequests = defaultCircuitMinRequests
+	}
+	if threshold.Window <= 0 {
+		threshold.Window = defaultCircuitWindow
+	}
+	if threshold.OpenTimeout <= 0 {
+		threshold.OpenTimeout = defaultCircuitOpenTimeout
+	}
+	if threshold.HalfOpenRequests <= 0 {
+		threshold.HalfOpenRequests = defaultCircuitHalfOpenRequests
+	}
+	return threshold
+}
+
+func defaultClassify(operation mod.Operation) mod.OperationClass {
+	if slices.Contains(readOperations, operation) {
+		return mod.OperationClassRead
+	}
+	return mod.OperationClassWrite
+}
+
+func defaultIsFailure(operation mod.Operation, resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= http.StatusInternalServerError
+}
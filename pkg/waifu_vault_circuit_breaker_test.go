@@ -0,0 +1,146 @@
+package waifuVault
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+// fakeClock is a clock that only moves when advanced
+type fakeClock struct {
+	now time.Time
+}
+
+func (re *fakeClock) Now() time.Time {
+	return re.now
+}
+
+func (re *fakeClock) advance(d time.Duration) {
+	re.now = re.now.Add(d)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	newBreaker := func(changes *[]string) (*CircuitBreaker, *fakeClock) {
+		clock := &fakeClock{now: time.Unix(1710111505, 0)}
+		breaker := NewCircuitBreaker(mod.CircuitBreakerOpts{
+			Default: mod.CircuitThreshold{MinRequests: 4, OpenTimeout: time.Minute},
+			Thresholds: map[mod.OperationClass]mod.CircuitThreshold{
+				mod.OperationClassWrite: {MinRequests: 2, FailureRate: 1, OpenTimeout: time.Minute, HalfOpenRequests: 2},
+			},
+			OnStateChange: func(class mod.OperationClass, from, to mod.CircuitState) {
+				*changes = append(*changes, string(class)+" "+from.String()+" "+to.String())
+			},
+			Now: clock.Now,
+		})
+		return breaker, clock
+	}
+	send := func(breaker *CircuitBreaker, operation mod.Operation, status int) (bool, error) {
+		sent := false
+		req, _ := http.NewRequest(http.MethodGet, "https://waifuvault.moe/rest/token", nil)
+		_, err := breaker.Handle(operation, req, func(r *http.Request) (*http.Response, error) {
+			sent = true
+			return statusResponse(status), nil
+		})
+		return sent, err
+	}
+
+	t.Run("should open when the failure rate is reached", func(t *testing.T) {
+		var changes []string
+		breaker, _ := newBreaker(&changes)
+		for _, status := range []int{http.StatusOK, http.StatusBadGateway, http.StatusOK, http.StatusServiceUnavailable} {
+			send(breaker, mod.OperationFileInfo, status)
+		}
+
+		sent, err := send(breaker, mod.OperationGetBucket, http.StatusOK)
+		var openErr *CircuitOpenError
+		if sent || !errors.Is(err, ErrCircuitOpen) || !errors.As(err, &openErr) || openErr.Operation != mod.OperationGetBucket {
+			t.Errorf("Expected GetBucket to fail fast, got %v", err)
+		}
+		if breaker.State(mod.OperationClassRead) != mod.CircuitOpen || breaker.State(mod.OperationClassWrite) != mod.CircuitClosed {
+			t.Errorf("Expected only the read circuit to be open")
+		}
+		if sent, _ = send(breaker, mod.OperationDeleteFile, http.StatusOK); !sent {
+			t.Errorf("Expected writes to be sent")
+		}
+		if !slices.Equal(changes, []string{"read closed open"}) {
+			t.Errorf("Expected the read circuit to open, got %v", changes)
+		}
+	})
+
+	t.Run("should close after trial requests succeed", func(t *testing.T) {
+		var changes []string
+		breaker, clock := newBreaker(&changes)
+		send(breaker, mod.OperationUploadFile, http.StatusInternalServerError)
+		send(breaker, mod.OperationUploadFile, http.StatusInternalServerError)
+
+		clock.advance(59 * time.Second)
+		if sent, _ := send(breaker, mod.OperationUploadFile, http.StatusOK); sent {
+			t.Errorf("Expected the circuit to stay open before the timeout")
+		}
+		clock.advance(time.Second)
+		send(breaker, mod.OperationUploadFile, http.StatusOK)
+		if breaker.State(mod.OperationClassWrite) != mod.CircuitHalfOpen {
+			t.Errorf("Expected the circuit to wait for a second trial, got %s", breaker.State(mod.OperationClassWrite))
+		}
+		send(breaker, mod.OperationUploadFile, http.StatusOK)
+
+		expected := []string{"write closed open", "write open half-open", "write half-open closed"}
+		if !slices.Equal(changes, expected) {
+			t.Errorf("Expected %v, got %v", expected, changes)
+		}
+	})
+
+	t.Run("should open again when a trial request fails", func(t *testing.T) {
+		var changes []string
+		breaker, clock := newBreaker(&changes)
+		send(breaker, mod.OperationDeleteFile, http.StatusInternalServerError)
+		send(breaker, mod.OperationDeleteFile, http.StatusInternalServerError)
+		clock.advance(time.Minute)
+		send(breaker, mod.OperationDeleteFile, http.StatusInternalServerError)
+
+		_, err := send(breaker, mod.OperationDeleteFile, http.StatusOK)
+		var openErr *CircuitOpenError
+		if !errors.As(err, &openErr) || !openErr.RetryAt.Equal(clock.now.Add(time.Minute)) {
+			t.Errorf("Expected the circuit to be open for another minute, got %v", err)
+		}
+	})
+
+	t.Run("should not count cancelled requests", func(t *testing.T) {
+		var changes []string
+		breaker, _ := newBreaker(&changes)
+		for range 5 {
+			req, _ := http.NewRequest(http.MethodGet, "https://waifuvault.moe/rest/token", nil)
+			breaker.Handle(mod.OperationFileInfo, req, func(r *http.Request) (*http.Response, error) {
+				return nil, context.Canceled
+			})
+		}
+		if breaker.State(mod.OperationClassRead) != mod.CircuitClosed {
+			t.Errorf("Expected the circuit to stay closed")
+		}
+	})
+	t.Run("should not leak uploads while open", func(t *testing.T) {
+		newFakeVault(t)
+		breaker := NewCircuitBreaker(mod.CircuitBreakerOpts{Default: mod.CircuitThreshold{MinRequests: 1, FailureRate: 1, OpenTimeout: time.Hour}})
+		req, _ := http.NewRequest(http.MethodPut, "https://waifuvault.moe/rest", nil)
+		breaker.Handle(mod.OperationUploadFile, req, func(r *http.Request) (*http.Response, error) {
+			return statusResponse(http.StatusInternalServerError), nil
+		})
+		api := NewWaifuvaltApiWithOpts(http.Client{}, mod.ClientOpts{Middleware: []mod.Middleware{breaker}})
+
+		before := runtime.NumGoroutine()
+		for range 20 {
+			_, err := api.UploadFile(context.Background(), mod.WaifuvaultPutOpts{Reader: strings.NewReader("image"), FileName: "08.png"})
+			if !errors.Is(err, ErrCircuitOpen) {
+				t.Fatalf("Expected ErrCircuitOpen, got %v", err)
+			}
+		}
+		checkGoroutines(t, before)
+	})
+}
@@ -0,0 +1,172 @@
+package waifuVault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+// ErrOneTimeDownload is returned when copying a one time download file without mod.CopyOpts.AllowOneTimeDownload
+var ErrOneTimeDownload = errors.New("one time download files are only copied with AllowOneTimeDownload, downloading them deletes the original")
+
+// CopyFile copies a file into another bucket with the same options. The download is streamed straight into the upload.
+// One time download files are not copied unless opts.AllowOneTimeDownload is set, as copying them consumes the original
+func CopyFile(ctx context.Context, client mod.Waifuvalt, token, targetBucket string, opts mod.CopyOpts) (*mod.WaifuResponse[string], error) {
+	info, err := client.FileInfo(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return copyFile(ctx, client, *info, targetBucket, opts)
+}
+
+// CopyAlbum copies an album and its files into another bucket, keeping the album order and share state.
+// Skipped one time download files are listed in the report
+func CopyAlbum(ctx context.Context, client mod.Waifuvalt, albumToken, targetBucket string, opts mod.CopyOpts) (*mod.CopyReport, error) {
+	album, err := client.GetAlbum(ctx, albumToken)
+	if err != nil {
+		return nil, err
+	}
+	report := newCopyReport(targetBucket)
+	if err = copyAlbum(ctx, client, album, opts, report); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// MigrateBucket copies every file and album of a bucket into another bucket.
+// If targetBucket is empty, a new bucket is created. If opts.DeleteOriginals is set and everything was copied, the source bucket is deleted
+func MigrateBucket(ctx context.Context, client mod.Waifuvalt, sourceBucket, targetBucket string, opts mod.CopyOpts) (*mod.CopyReport, error) {
+	bucket, err := client.GetBucket(ctx, sourceBucket)
+	if err != nil {
+		return nil, err
+	}
+	if targetBucket == "" {
+		created, err := client.CreateBucket(ctx)
+		if err != nil {
+			return nil, err
+		}
+		targetBucket = created.Token
+	}
+
+	report := newCopyReport(targetBucket)
+	inAlbum := map[string]bool{}
+	for _, stub := range bucket.Albums {
+		album, err := client.GetAlbum(ctx, stub.Token)
+		if err != nil {
+			report.Failures[stub.Token] = err
+			continue
+		}
+		for _, file := range album.Files {
+			inAlbum[file.Token] = true
+		}
+		if err = copyAlbum(ctx, client, album, opts, report); err != nil {
+			report.Failures[stub.Token] = err
+		}
+	}
+	for _, file := range bucket.Files {
+		if inAlbum[file.Token] {
+			continue
+		}
+		copied, err := copyFile(ctx, client, file, targetBucket, opts)
+		reportCopy(report, file.Token, copied, err)
+	}
+
+	// deleting the bucket would also delete the skipped files
+	if opts.DeleteOriginals && len(report.Failures) == 0 && len(report.Skipped) == 0 {
+		if _, err = client.DeleteBucket(ctx, sourceBucket); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+func copyAlbum(ctx context.Context, client mod.Waifuvalt, album *mod.WaifuAlbum, opts mod.CopyOpts, report *mod.CopyReport) error {
+	var copied []string
+	for _, file := range album.Files {
+		result, err := copyFile(ctx, client, file, report.BucketToken, opts)
+		reportCopy(report, file.Token, result, err)
+		if result != nil {
+			copied = append(copied, result.Token)
+		}
+	}
+
+	created, err := client.CreateAlbum(ctx, mod.WaifuAlbumCreateBody{Name: album.Name, BucketToken: report.BucketToken})
+	if err != nil {
+		return err
+	}
+	report.Albums[album.Token] = created.Token
+	if len(copied) > 0 {
+		if _, err = client.AssociateFiles(ctx, created.Token, copied); err != nil {
+			return err
+		}
+	}
+	if album.PublicToken != nil {
+		if _, err = client.ShareAlbum(ctx, created.Token); err != nil {
+			return err
+		}
+	}
+
+	if opts.DeleteOriginals && len(copied) == len(album.Files) {
+		if _, err = client.DeleteAlbum(ctx, album.Token, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(ctx context.Context, client mod.Waifuvalt, file mod.WaifuResponse[int], targetBucket string, opts mod.CopyOpts) (*mod.WaifuResponse[string], error) {
+	if file.Options.OneTimeDownload && !opts.AllowOneTimeDownload {
+		return nil, ErrOneTimeDownload
+	}
+	password := opts.Passwords[file.Token]
+	if file.Options.Protected && password == "" {
+		return nil, errors.New("a password is required to copy a protected file")
+	}
+	body, err := StreamFile(ctx, client, mod.GetFileInfo{Url: file.URL, Password: password})
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	copied, err := client.UploadFile(ctx, mod.WaifuvaultPutOpts{
+		Reader:          body,
+		FileName:        uploadFilename(file),
+		Password:        password,
+		Expires:         opts.Expires,
+		HideFilename:    file.Options.HideFilename,
+		OneTimeDownload: file.Options.OneTimeDownload,
+		BucketToken:     targetBucket,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if opts.DeleteOriginals && !file.Options.OneTimeDownload {
+		if _, err = client.DeleteFile(ctx, file.Token); err != nil {
+			return copied, fmt.Errorf("copied but unable to delete the original: %w", err)
+		}
+	}
+	return copied, nil
+}
+
+// reportCopy records the result of copying a file, skipped one time download files are not failures
+func reportCopy(report *mod.CopyReport, token string, copied *mod.WaifuResponse[string], err error) {
+	if copied != nil {
+		report.Files[token] = copied.Token
+	}
+	if errors.Is(err, ErrOneTimeDownload) {
+		report.Skipped = append(report.Skipped, token)
+	} else if err != nil {
+		report.Failures[token] = err
+	}
+}
+
+func newCopyReport(bucketToken string) *mod.CopyReport {
+	return &mod.CopyReport{
+		BucketToken: bucketToken,
+		Files:       map[string]string{},
+		Albums:      map[string]string{},
+		Failures:    map[string]error{},
+	}
+}
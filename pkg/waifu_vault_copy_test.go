@@ -0,0 +1,178 @@
+package waifuVault
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+func TestCopyFile(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should copy a file with its options", func(t *testing.T) {
+		fv := newFakeVault(t)
+		source := fv.addBucket()
+		target := fv.addBucket()
+		token := fv.addFile(source, "secret.png", []byte("image"), mod.WaifuResponseOptions{HideFilename: true}, "pass")
+
+		result, err := CopyFile(ctx, NewWaifuvaltApi(http.Client{}), token, target, mod.CopyOpts{
+			Passwords: map[string]string{token: "pass"},
+		})
+		if err != nil {
+			t.Fatalf("CopyFile failed: %v", err)
+		}
+
+		copied := fv.file(result.Token)
+		if copied.response.Bucket != target || string(copied.content) != "image" || copied.password != "pass" {
+			t.Errorf("Unexpected copy %+v", copied)
+		}
+		if !copied.response.Options.HideFilename {
+			t.Error("Expected the filename to stay hidden")
+		}
+		if fv.file(token) == nil {
+			t.Error("Expected the original to be kept")
+		}
+	})
+
+	t.Run("should move a file", func(t *testing.T) {
+		fv := newFakeVault(t)
+		source := fv.addBucket()
+		target := fv.addBucket()
+		token := fv.addFile(source, "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+
+		_, err := CopyFile(ctx, NewWaifuvaltApi(http.Client{}), token, target, mod.CopyOpts{DeleteOriginals: true})
+		if err != nil {
+			t.Fatalf("CopyFile failed: %v", err)
+		}
+
+		if fv.file(token) != nil {
+			t.Error("Expected the original to be deleted")
+		}
+	})
+
+	t.Run("should not copy one time download files unless allowed", func(t *testing.T) {
+		fv := newFakeVault(t)
+		target := fv.addBucket()
+		token := fv.addFile(fv.addBucket(), "once.png", []byte("image"), mod.WaifuResponseOptions{OneTimeDownload: true}, "")
+		api := NewWaifuvaltApi(http.Client{})
+
+		_, err := CopyFile(ctx, api, token, target, mod.CopyOpts{})
+		if !errors.Is(err, ErrOneTimeDownload) {
+			t.Fatalf("Expected ErrOneTimeDownload, got %v", err)
+		}
+		if fv.file(token) == nil || fv.requestCount(http.MethodGet, "/f/") != 0 {
+			t.Fatal("Expected the original not to be downloaded")
+		}
+
+		result, err := CopyFile(ctx, api, token, target, mod.CopyOpts{AllowOneTimeDownload: true})
+		if err != nil {
+			t.Fatalf("CopyFile failed: %v", err)
+		}
+		if copied := fv.file(result.Token); copied == nil || !copied.response.Options.OneTimeDownload {
+			t.Errorf("Expected a one time download copy, got %+v", copied)
+		}
+	})
+
+	t.Run("should require the password of protected files", func(t *testing.T) {
+		fv := newFakeVault(t)
+		token := fv.addFile(fv.addBucket(), "08.png", []byte("image"), mod.WaifuResponseOptions{}, "pass")
+
+		_, err := CopyFile(ctx, NewWaifuvaltApi(http.Client{}), token, fv.addBucket(), mod.CopyOpts{})
+		if err == nil {
+			t.Fatal("Expected error but got none")
+		}
+	})
+}
+
+func TestCopyAlbum(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should copy an album and its files", func(t *testing.T) {
+		fv := newFakeVault(t)
+		source := fv.addBucket()
+		target := fv.addBucket()
+		file1 := fv.addFile(source, "1.png", []byte("one"), mod.WaifuResponseOptions{}, "")
+		file2 := fv.addFile(source, "2.png", []byte("two"), mod.WaifuResponseOptions{}, "")
+		album := fv.addAlbum(source, "album1", file2, file1)
+		api := NewWaifuvaltApi(http.Client{})
+		api.ShareAlbum(ctx, album)
+
+		report, err := CopyAlbum(ctx, api, album, target, mod.CopyOpts{})
+		if err != nil {
+			t.Fatalf("CopyAlbum failed: %v", err)
+		}
+
+		copied := fv.album(report.Albums[album])
+		if copied == nil || copied.bucket != target || copied.name != "album1" || copied.publicToken == nil {
+			t.Fatalf("Unexpected album copy %+v", copied)
+		}
+		if len(copied.files) != 2 || copied.files[0] != report.Files[file2] || copied.files[1] != report.Files[file1] {
+			t.Errorf("Expected album order to be kept, got %v", copied.files)
+		}
+	})
+}
+
+func TestMigrateBucket(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should migrate a bucket into a new bucket", func(t *testing.T) {
+		fv := newFakeVault(t)
+		source := fv.addBucket()
+		file1 := fv.addFile(source, "1.png", []byte("one"), mod.WaifuResponseOptions{}, "")
+		file2 := fv.addFile(source, "2.png", []byte("two"), mod.WaifuResponseOptions{}, "")
+		album := fv.addAlbum(source, "album1", file2)
+
+		report, err := MigrateBucket(ctx, NewWaifuvaltApi(http.Client{}), source, "", mod.CopyOpts{DeleteOriginals: true})
+		if err != nil {
+			t.Fatalf("MigrateBucket failed: %v", err)
+		}
+
+		if len(report.Failures) != 0 {
+			t.Fatalf("Expected no failures, got %v", report.Failures)
+		}
+		if report.BucketToken == "" || report.BucketToken == source {
+			t.Fatalf("Expected a new bucket, got %s", report.BucketToken)
+		}
+		if len(fv.bucketFiles(report.BucketToken)) != 2 {
+			t.Errorf("Expected 2 files in the new bucket")
+		}
+		if fv.file(report.Files[file1]) == nil {
+			t.Errorf("Expected %s to be migrated", file1)
+		}
+		newAlbum := fv.album(report.Albums[album])
+		if newAlbum == nil || len(newAlbum.files) != 1 || newAlbum.files[0] != report.Files[file2] {
+			t.Errorf("Expected album to be migrated, got %+v", newAlbum)
+		}
+		if fv.requestCount(http.MethodDelete, "/rest/bucket/"+source) != 1 {
+			t.Error("Expected the source bucket to be deleted")
+		}
+	})
+
+	t.Run("should skip one time download files and keep the source bucket", func(t *testing.T) {
+		fv := newFakeVault(t)
+		source := fv.addBucket()
+		file := fv.addFile(source, "1.png", []byte("one"), mod.WaifuResponseOptions{}, "")
+		once := fv.addFile(source, "once.png", []byte("once"), mod.WaifuResponseOptions{OneTimeDownload: true}, "")
+
+		report, err := MigrateBucket(ctx, NewWaifuvaltApi(http.Client{}), source, "", mod.CopyOpts{DeleteOriginals: true})
+		if err != nil {
+			t.Fatalf("MigrateBucket failed: %v", err)
+		}
+
+		if len(report.Failures) != 0 {
+			t.Fatalf("Expected no failures, got %v", report.Failures)
+		}
+		if len(report.Skipped) != 1 || report.Skipped[0] != once {
+			t.Errorf("Expected %s to be skipped, got %v", once, report.Skipped)
+		}
+		if report.Files[file] == "" {
+			t.Errorf("Expected %s to be migrated", file)
+		}
+		if fv.file(once) == nil || fv.requestCount(http.MethodDelete, "/rest/bucket/"+source) != 0 {
+			t.Error("Expected the one time download file and its bucket to be kept")
+		}
+	})
+}
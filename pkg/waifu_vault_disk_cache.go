@@ -0,0 +1,282 @@
+package waifuVault
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+const defaultDiskCacheMaxSize = 1 << 30
+
+type diskCacheApi struct {
+	mod.Waifuvalt
+	opts mod.DiskCacheOpts
+	mu   sync.Mutex
+}
+
+// NewDiskCacheApi wraps a client so GetFile and DownloadAlbum are served from a cache directory.
+// Files are cached by URL, which contains the upload epoch, and albums by the URLs of the files they contain.
+// Contents are stored by their SHA-256 hash and verified when read.
+// Protected and one time download files are not cached unless allowed
+func NewDiskCacheApi(client mod.Waifuvalt, opts mod.DiskCacheOpts) (mod.Waifuvalt, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("a cache directory is required")
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = defaultDiskCacheMaxSize
+	}
+	for _, dir := range []string{"refs", "blobs"} {
+		if err := os.MkdirAll(filepath.Join(opts.Dir, dir), 0o700); err != nil {
+			return nil, err
+		}
+	}
+	return &diskCacheApi{
+		Waifuvalt: client,
+		opts:      opts,
+	}, nil
+}
+
+func (re *diskCacheApi) GetFile(ctx context.Context, options mod.GetFileInfo) ([]byte, error) {
+	key, cacheable, err := re.fileKey(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	return re.cached(key, cacheable, func() ([]byte, error) {
+		return re.Waifuvalt.GetFile(ctx, options)
+	})
+}
+
+func (re *diskCacheApi) DownloadAlbum(ctx context.Context, albumToken string, files []int) ([]byte, error) {
+	album, err := re.Waifuvalt.GetAlbum(ctx, albumToken)
+	if err != nil {
+		return nil, err
+	}
+	key := strings.Builder{}
+	key.WriteString("album " + albumToken)
+	cacheable := true
+	for _, file := range album.Files {
+		if len(files) > 0 && !slices.Contains(files, file.ID) {
+			continue
+		}
+		key.WriteString(" " + file.URL)
+		cacheable = cacheable && re.allowed(file.Options.Protected, file.Options.OneTimeDownload)
+	}
+	return re.cached(key.String(), cacheability{read: cacheable, store: cacheable}, func() ([]byte, error) {
+		return re.Waifuvalt.DownloadAlbum(ctx, albumToken, files)
+	})
+}
+
+// fileKey returns the cache key of a file and if it can be cached, the file info is looked up if a token is given.
+// Files downloaded without a token can be read from the cache, but are only stored if one time downloads are allowed.
+// The key includes a hash of the password, so a protected file is only served from the cache with the password it was stored with
+func (re *diskCacheApi) fileKey(ctx context.Context, options mod.GetFileInfo) (string, cacheability, error) {
+	fileUrl := options.Url
+	if fileUrl == "" && options.Filename != "" {
+		fileUrl = fmt.Sprintf("%s/f/%s", re.baseUrl(), options.Filename)
+	}
+	protected := options.Password != ""
+	oneTime := true
+	if options.Token != "" {
+		info, err := re.Waifuvalt.FileInfo(ctx, options.Token)
+		if err != nil {
+			return "", cacheability{}, err
+		}
+		if fileUrl == "" {
+			fileUrl = info.URL
+		}
+		protected = protected || info.Options.Protected
+		oneTime = info.Options.OneTimeDownload
+	}
+	parsed, err := ParseFileURL(fileUrl)
+	if err != nil {
+		return "", cacheability{}, err
+	}
+	result := cacheability{read: re.allowed(protected, false), store: re.allowed(protected, oneTime)}
+	if options.Token != "" {
+		result.read = result.store
+	}
+	key := "file " + parsed.String()
+	if options.Password != "" {
+		sum := sha256.Sum256([]byte(options.Password))
+		key += " password " + hex.EncodeToString(sum[:])
+	}
+	return key, result, nil
+}
+
+func (re *diskCacheApi) GetFileStream(ctx context.Context, options mod.GetFileInfo) (io.ReadCloser, error) {
+	return StreamFile(ctx, re.Waifuvalt, options)
+}
+
+func (re *diskCacheApi) DownloadAlbumStream(ctx context.Context, albumToken string, files []int) (io.ReadCloser, error) {
+	return StreamAlbum(ctx, re.Waifuvalt, albumToken, files)
+}
+
+func (re *diskCacheApi) GetFileSize(ctx context.Context, options mod.GetFileInfo) (int64, error) {
+	return FileSize(ctx, re.Waifuvalt, options)
+}
+
+// baseUrl returns the base URL of the wrapped client, so caches of clients for different instances do not collide
+func (re *diskCacheApi) baseUrl() string {
+	if client, ok := re.Waifuvalt.(interface{ getBaseUrl() string }); ok {
+		return client.getBaseUrl()
+	}
+	return baseUrl
+}
+
+// cacheability is whether an entry can be read from and stored in the cache
+type cacheability struct {
+	read  bool
+	store bool
+}
+
+func (re *diskCacheApi) allowed(protected, oneTime bool) bool {
+	return (!protected || re.opts.AllowProtected) && (!oneTime || re.opts.AllowOneTimeDownload)
+}
+
+// cached returns the cached content of key, or downloads it and caches it if allowed.
+// The cache is best effort, failing to read or write it falls back to downloading
+func (re *diskCacheApi) cached(key string, cacheable cacheability, download func() ([]byte, error)) ([]byte, error) {
+	ref := re.refPath(key)
+	if cacheable.read {
+		if content, ok := re.load(ref); ok {
+			return content, nil
+		}
+	}
+	content, err := download()
+	if err != nil {
+		return nil, err
+	}
+	if cacheable.store {
+		re.store(ref, content)
+	}
+	return content, nil
+}
+
+func (re *diskCacheApi) refPath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(re.opts.Dir, "refs", hex.EncodeToString(sum[:]))
+}
+
+func (re *diskCacheApi) blobPath(hash string) string {
+	return filepath.Join(re.opts.Dir, "blobs", hash)
+}
+
+// load reads the content a ref points to, content that does not match its hash is removed
+func (re *diskCacheApi) load(ref string) ([]byte, bool) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	hash, err := os.ReadFile(ref)
+	if err != nil {
+		return nil, false
+	}
+	blob := re.blobPath(string(hash))
+	content, err := os.ReadFile(blob)
+	if err != nil {
+		os.Remove(ref)
+		return nil, false
+	}
+	sum := sha256.Sum256(content)
+	if hex.EncodeToString(sum[:]) != string(hash) {
+		os.Remove(blob)
+		os.Remove(ref)
+		return nil, false
+	}
+	now := time.Now()
+	os.Chtimes(blob, now, now)
+	return content, true
+}
+
+func (re *diskCacheApi) store(ref string, content []byte) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	if int64(len(content)) > re.opts.MaxSize {
+		return
+	}
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	if writeAtomic(re.blobPath(hash), content) != nil {
+		return
+	}
+	if writeAtomic(ref, []byte(hash)) != nil {
+		return
+	}
+	re.evict(hash)
+}
+
+// evict removes the least recently used contents except keep until the cache fits in MaxSize, and the refs pointing to them
+func (re *diskCacheApi) evict(keep string) {
+	blobs, err := os.ReadDir(filepath.Join(re.opts.Dir, "blobs"))
+	if err != nil {
+		return
+	}
+	var infos []fs.FileInfo
+	var total int64
+	for _, blob := range blobs {
+		if strings.HasPrefix(blob.Name(), ".") {
+			continue
+		}
+		if info, err := blob.Info(); err == nil && info.Mode().IsRegular() {
+			total += info.Size()
+			if blob.Name() != keep {
+				infos = append(infos, info)
+			}
+		}
+	}
+	if total <= re.opts.MaxSize {
+		return
+	}
+	slices.SortFunc(infos, func(a, b fs.FileInfo) int {
+		return cmp.Compare(a.ModTime().UnixNano(), b.ModTime().UnixNano())
+	})
+	evicted := map[string]bool{}
+	for _, info := range infos {
+		if total <= re.opts.MaxSize {
+			break
+		}
+		if os.Remove(re.blobPath(info.Name())) == nil {
+			evicted[info.Name()] = true
+			total -= info.Size()
+		}
+	}
+
+	refs, err := os.ReadDir(filepath.Join(re.opts.Dir, "refs"))
+	if err != nil {
+		return
+	}
+	for _, ref := range refs {
+		path := filepath.Join(re.opts.Dir, "refs", ref.Name())
+		if hash, err := os.ReadFile(path); err == nil && evicted[string(hash)] {
+			os.Remove(path)
+		}
+	}
+}
+
+// writeAtomic writes a file through a temporary file so a partially written file is never read
+func writeAtomic(path string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
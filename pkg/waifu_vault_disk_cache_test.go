@@ -0,0 +1,177 @@
+package waifuVault
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+func newDiskCacheApi(t *testing.T, opts mod.DiskCacheOpts) mod.Waifuvalt {
+	opts.Dir = t.TempDir()
+	api, err := NewDiskCacheApi(NewWaifuvaltApi(http.Client{}), opts)
+	if err != nil {
+		t.Fatalf("NewDiskCacheApi failed: %v", err)
+	}
+	return api
+}
+
+func TestDiskCacheApi(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should cache downloads", func(t *testing.T) {
+		fv := newFakeVault(t)
+		token := fv.addFile("", "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+
+		api := newDiskCacheApi(t, mod.DiskCacheOpts{})
+		for range 2 {
+			content, err := api.GetFile(ctx, mod.GetFileInfo{Token: token})
+			if err != nil || string(content) != "image" {
+				t.Fatalf("GetFile failed: %s, %v", content, err)
+			}
+		}
+		content, _ := api.GetFile(ctx, mod.GetFileInfo{Url: fv.file(token).response.URL})
+
+		if string(content) != "image" || fv.requestCount(http.MethodGet, "/f/") != 1 {
+			t.Errorf("Expected the file to be downloaded once, got %d downloads", fv.requestCount(http.MethodGet, "/f/"))
+		}
+	})
+
+	t.Run("should not store downloads by url unless allowed", func(t *testing.T) {
+		fv := newFakeVault(t)
+		token := fv.addFile("", "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		fileUrl := fv.file(token).response.URL
+
+		api := newDiskCacheApi(t, mod.DiskCacheOpts{})
+		api.GetFile(ctx, mod.GetFileInfo{Url: fileUrl})
+		api.GetFile(ctx, mod.GetFileInfo{Url: fileUrl})
+		if fv.requestCount(http.MethodGet, "/f/") != 2 {
+			t.Errorf("Expected downloads by url not to be cached")
+		}
+
+		api = newDiskCacheApi(t, mod.DiskCacheOpts{AllowOneTimeDownload: true})
+		api.GetFile(ctx, mod.GetFileInfo{Url: fileUrl})
+		api.GetFile(ctx, mod.GetFileInfo{Url: fileUrl})
+		if fv.requestCount(http.MethodGet, "/f/") != 3 {
+			t.Errorf("Expected downloads by url to be cached when allowed")
+		}
+	})
+
+	t.Run("should not cache protected or one time download files unless allowed", func(t *testing.T) {
+		fv := newFakeVault(t)
+		protected := fv.addFile("", "secret.txt", []byte("secret"), mod.WaifuResponseOptions{Protected: true}, "pass")
+		once := fv.addFile("", "once.txt", []byte("once"), mod.WaifuResponseOptions{OneTimeDownload: true}, "")
+
+		api := newDiskCacheApi(t, mod.DiskCacheOpts{})
+		api.GetFile(ctx, mod.GetFileInfo{Token: protected, Password: "pass"})
+		api.GetFile(ctx, mod.GetFileInfo{Token: protected, Password: "pass"})
+		if fv.requestCount(http.MethodGet, "/f/") != 2 {
+			t.Errorf("Expected protected files not to be cached")
+		}
+		api.GetFile(ctx, mod.GetFileInfo{Token: once})
+		if _, err := api.GetFile(ctx, mod.GetFileInfo{Token: once}); err == nil {
+			t.Errorf("Expected one time download files not to be cached")
+		}
+
+		api = newDiskCacheApi(t, mod.DiskCacheOpts{AllowProtected: true})
+		api.GetFile(ctx, mod.GetFileInfo{Token: protected, Password: "pass"})
+		content, _ := api.GetFile(ctx, mod.GetFileInfo{Token: protected, Password: "pass"})
+		if string(content) != "secret" || fv.requestCount(http.MethodGet, "/f/") != 4 {
+			t.Errorf("Expected protected files to be cached when allowed")
+		}
+	})
+
+	t.Run("should download contents that fail verification again", func(t *testing.T) {
+		fv := newFakeVault(t)
+		token := fv.addFile("", "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+
+		dir := t.TempDir()
+		api, _ := NewDiskCacheApi(NewWaifuvaltApi(http.Client{}), mod.DiskCacheOpts{Dir: dir})
+		api.GetFile(ctx, mod.GetFileInfo{Token: token})
+		blobs, _ := filepath.Glob(filepath.Join(dir, "blobs", "*"))
+		for _, blob := range blobs {
+			os.WriteFile(blob, []byte("corrupted"), 0o600)
+		}
+		content, _ := api.GetFile(ctx, mod.GetFileInfo{Token: token})
+
+		if string(content) != "image" || fv.requestCount(http.MethodGet, "/f/") != 2 {
+			t.Errorf("Expected the file to be downloaded again, got %s", content)
+		}
+	})
+
+	t.Run("should evict the least recently used files", func(t *testing.T) {
+		fv := newFakeVault(t)
+		a := fv.addFile("", "a.txt", []byte("aaaaaa"), mod.WaifuResponseOptions{}, "")
+		b := fv.addFile("", "b.txt", []byte("bbbbbb"), mod.WaifuResponseOptions{}, "")
+
+		api := newDiskCacheApi(t, mod.DiskCacheOpts{MaxSize: 10})
+		api.GetFile(ctx, mod.GetFileInfo{Token: a})
+		api.GetFile(ctx, mod.GetFileInfo{Token: b})
+		api.GetFile(ctx, mod.GetFileInfo{Token: b})
+		api.GetFile(ctx, mod.GetFileInfo{Token: a})
+
+		if fv.requestCount(http.MethodGet, "/f/") != 3 {
+			t.Errorf("Expected a to be evicted, got %d downloads", fv.requestCount(http.MethodGet, "/f/"))
+		}
+	})
+
+	t.Run("should cache albums until their files change", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		image := fv.addFile(bucket, "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		album := fv.addAlbum(bucket, "images", image)
+
+		api := newDiskCacheApi(t, mod.DiskCacheOpts{})
+		api.DownloadAlbum(ctx, album, nil)
+		api.DownloadAlbum(ctx, album, nil)
+		if fv.requestCount(http.MethodPost, "/rest/album/download/") != 1 {
+			t.Errorf("Expected the album to be downloaded once")
+		}
+
+		other := fv.addFile(bucket, "09.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		api.AssociateFiles(ctx, album, []string{other})
+		api.DownloadAlbum(ctx, album, nil)
+		if fv.requestCount(http.MethodPost, "/rest/album/download/") != 2 {
+			t.Errorf("Expected the album to be downloaded again after its files changed")
+		}
+	})
+	t.Run("should only serve protected files with their password", func(t *testing.T) {
+		fv := newFakeVault(t)
+		protected := fv.addFile("", "secret.txt", []byte("secret"), mod.WaifuResponseOptions{Protected: true}, "pass")
+		fileUrl := fv.file(protected).response.URL
+
+		api := newDiskCacheApi(t, mod.DiskCacheOpts{AllowProtected: true, AllowOneTimeDownload: true})
+		if _, err := api.GetFile(ctx, mod.GetFileInfo{Token: protected, Password: "pass"}); err != nil {
+			t.Fatalf("GetFile failed: %v", err)
+		}
+		if content, err := api.GetFile(ctx, mod.GetFileInfo{Token: protected, Password: "wrong"}); err == nil {
+			t.Errorf("Expected a wrong password to fail, got %s", content)
+		}
+		if content, err := api.GetFile(ctx, mod.GetFileInfo{Url: fileUrl}); err == nil {
+			t.Errorf("Expected a missing password to fail, got %s", content)
+		}
+		if content, _ := api.GetFile(ctx, mod.GetFileInfo{Url: fileUrl, Password: "pass"}); string(content) != "secret" {
+			t.Errorf("Expected the cached file, got %s", content)
+		}
+	})
+
+	t.Run("should keep the files of different instances apart", func(t *testing.T) {
+		primary, mirror := newFakeVault(t), newFakeVault(t)
+		primaryFile := primary.addFile("", "08.png", []byte("primary"), mod.WaifuResponseOptions{}, "")
+		mirror.addFile("", "08.png", []byte("mirror"), mod.WaifuResponseOptions{}, "")
+		parsed, _ := ParseFileURL(primary.file(primaryFile).response.URL)
+
+		dir := t.TempDir()
+		opts := mod.DiskCacheOpts{Dir: dir, AllowOneTimeDownload: true}
+		primaryApi, _ := NewDiskCacheApi(NewWaifuvaltApiWithOpts(http.Client{}, mod.ClientOpts{BaseURL: primary.server.URL}), opts)
+		mirrorApi, _ := NewDiskCacheApi(NewWaifuvaltApiWithOpts(http.Client{}, mod.ClientOpts{BaseURL: mirror.server.URL}), opts)
+
+		primaryApi.GetFile(ctx, mod.GetFileInfo{Filename: parsed.Path()})
+		if content, _ := mirrorApi.GetFile(ctx, mod.GetFileInfo{Filename: parsed.Path()}); string(content) != "mirror" {
+			t.Errorf("Expected the file of the mirror, got %s", content)
+		}
+	})
+}
@@ -0,0 +1,320 @@
+package waifuVault
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+const (
+	manifestArchiveName = "manifest.json"
+	contentArchiveDir   = "files/"
+)
+
+// ExportBucket writes a manifest of the files and albums in a bucket to w.
+// If opts.IncludeContents is set, a tar archive containing the manifest and the contents of every file is written instead.
+// The contents are streamed into the archive. The contents of one time download files are never exported as downloading
+// them would delete them, protected files without a password and files that can not be downloaded are reported as failures
+func ExportBucket(ctx context.Context, client mod.Waifuvalt, token string, w io.Writer, opts mod.ExportBucketOpts) (*mod.ExportReport, error) {
+	bucket, err := client.GetBucket(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	report := &mod.ExportReport{Failures: map[string]error{}}
+
+	manifest := mod.BucketManifest{
+		Version:     mod.BucketManifestVersion,
+		ExportedAt:  time.Now().UTC(),
+		BucketToken: bucket.Token,
+		Files:       make([]mod.ManifestFile, 0, len(bucket.Files)),
+		Albums:      make([]mod.ManifestAlbum, 0, len(bucket.Albums)),
+	}
+	for _, file := range bucket.Files {
+		entry := mod.ManifestFile{
+			Token:           file.Token,
+			URL:             file.URL,
+			ID:              file.ID,
+			Filename:        uploadFilename(file),
+			Options:         file.Options,
+			RetentionPeriod: file.RetentionPeriod,
+			Views:           file.Views,
+		}
+		_, hasPassword := opts.Passwords[file.Token]
+		if opts.IncludeContents && file.Options.Protected && !hasPassword {
+			report.Failures[file.Token] = errors.New("no password was given for the protected file")
+		} else if opts.IncludeContents && !file.Options.OneTimeDownload {
+			entry.Content = contentArchiveDir + file.Token
+		}
+		manifest.Files = append(manifest.Files, entry)
+	}
+	for _, stub := range bucket.Albums {
+		album, err := client.GetAlbum(ctx, stub.Token)
+		if err != nil {
+			return nil, err
+		}
+		entry := mod.ManifestAlbum{
+			Token:       stub.Token,
+			Name:        stub.Name,
+			Shared:      stub.PublicToken != nil,
+			DateCreated: stub.DateCreated,
+			Files:       make([]string, 0, len(album.Files)),
+		}
+		for _, file := range album.Files {
+			entry.Files = append(entry.Files, file.Token)
+		}
+		manifest.Albums = append(manifest.Albums, entry)
+	}
+
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if !opts.IncludeContents {
+		_, err = w.Write(manifestBytes)
+		return report, err
+	}
+
+	archive := tar.NewWriter(w)
+	if err = writeArchiveEntry(archive, manifestArchiveName, bytes.NewReader(manifestBytes), int64(len(manifestBytes))); err != nil {
+		return nil, err
+	}
+	for _, file := range manifest.Files {
+		if file.Content == "" {
+			continue
+		}
+		if err = exportContent(ctx, client, archive, file, opts.Passwords[file.Token], report); err != nil {
+			return report, fmt.Errorf("unable to export %s: %w", file.Token, err)
+		}
+	}
+	return report, archive.Close()
+}
+
+// exportContent streams the contents of a file into the archive. Files that can not be downloaded are reported as
+// failures and left out of the archive, the returned error means the archive itself could not be written
+func exportContent(ctx context.Context, client mod.Waifuvalt, archive *tar.Writer, file mod.ManifestFile, password string, report *mod.ExportReport) error {
+	stream, err := StreamFile(ctx, client, mod.GetFileInfo{Url: file.URL, Password: password})
+	if err != nil {
+		report.Failures[file.Token] = err
+		return nil
+	}
+	defer stream.Close()
+
+	var content io.Reader = stream
+	size := int64(-1)
+	if sized, ok := stream.(interface{ Size() int64 }); ok {
+		size = sized.Size()
+	}
+	if size < 0 {
+		// a tar header needs the size up front, so a stream without a length is read into memory
+		buffered, err := io.ReadAll(stream)
+		if err != nil {
+			report.Failures[file.Token] = err
+			return nil
+		}
+		content, size = bytes.NewReader(buffered), int64(len(buffered))
+	}
+	return writeArchiveEntry(archive, file.Content, content, size)
+}
+
+// ImportBucket recreates the files and albums of a manifest or archive written by ExportBucket.
+// Files without exported contents are uploaded again from their original URL, except one time download files
+// as fetching their URL would delete the original. The contents in an archive are streamed into the uploads
+func ImportBucket(ctx context.Context, client mod.Waifuvalt, r io.Reader, opts mod.ImportBucketOpts) (*mod.ImportReport, error) {
+	reader := bufio.NewReader(r)
+	isArchive, err := isTarArchive(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest mod.BucketManifest
+	var archive *tar.Reader
+	if isArchive {
+		archive = tar.NewReader(reader)
+		header, err := archive.Next()
+		if err != nil {
+			return nil, err
+		}
+		if header.Name != manifestArchiveName {
+			return nil, fmt.Errorf("expected %s as the first entry of the archive, got %s", manifestArchiveName, header.Name)
+		}
+		err = json.NewDecoder(archive).Decode(&manifest)
+		if err != nil {
+			return nil, err
+		}
+	} else if err = json.NewDecoder(reader).Decode(&manifest); err != nil {
+		return nil, err
+	}
+	if manifest.Version != mod.BucketManifestVersion {
+		return nil, fmt.Errorf("unsupported manifest version %d", manifest.Version)
+	}
+
+	report := &mod.ImportReport{
+		BucketToken: opts.BucketToken,
+		Files:       map[string]string{},
+		Albums:      map[string]string{},
+		Failures:    map[string]error{},
+	}
+	if report.BucketToken == "" {
+		bucket, err := client.CreateBucket(ctx)
+		if err != nil {
+			return nil, err
+		}
+		report.BucketToken = bucket.Token
+	}
+
+	contents := map[string]mod.ManifestFile{}
+	for _, file := range manifest.Files {
+		if file.Content == "" || archive == nil {
+			importFile(ctx, client, file, nil, opts, report)
+		} else {
+			contents[file.Content] = file
+		}
+	}
+	for archive != nil {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return report, err
+		}
+		file, found := contents[header.Name]
+		if !found {
+			continue
+		}
+		delete(contents, header.Name)
+		// a failed upload may still be reading when it returns, so it must not read past its entry
+		content := &detachableReader{reader: archive}
+		importFile(ctx, client, file, content, opts, report)
+		content.detach()
+	}
+	for _, file := range contents {
+		report.Failures[file.Token] = fmt.Errorf("%s is missing from the archive", file.Content)
+	}
+
+	for _, album := range manifest.Albums {
+		importAlbum(ctx, client, album, report)
+	}
+	return report, nil
+}
+
+func importFile(ctx context.Context, client mod.Waifuvalt, file mod.ManifestFile, content io.Reader, opts mod.ImportBucketOpts, report *mod.ImportReport) {
+	putOpts := mod.WaifuvaultPutOpts{
+		Expires:         opts.Expires,
+		HideFilename:    file.Options.HideFilename,
+		Password:        opts.Passwords[file.Token],
+		OneTimeDownload: file.Options.OneTimeDownload,
+		BucketToken:     report.BucketToken,
+	}
+	if content != nil {
+		putOpts.Reader = content
+		putOpts.FileName = file.Filename
+	} else if file.Options.Protected {
+		report.Failures[file.Token] = errors.New("protected files can only be imported from an archive")
+		return
+	} else if file.Options.OneTimeDownload {
+		report.Failures[file.Token] = errors.New("one time download files can not be imported, uploading them from their url would delete the original")
+		return
+	} else {
+		putOpts.Url = file.URL
+	}
+	uploaded, err := client.UploadFile(ctx, putOpts)
+	if err != nil {
+		report.Failures[file.Token] = err
+		return
+	}
+	report.Files[file.Token] = uploaded.Token
+}
+
+func importAlbum(ctx context.Context, client mod.Waifuvalt, album mod.ManifestAlbum, report *mod.ImportReport) {
+	created, err := client.CreateAlbum(ctx, mod.WaifuAlbumCreateBody{Name: album.Name, BucketToken: report.BucketToken})
+	if err != nil {
+		report.Failures[album.Token] = err
+		return
+	}
+	report.Albums[album.Token] = created.Token
+
+	var files []string
+	for _, token := range album.Files {
+		if newToken, found := report.Files[token]; found {
+			files = append(files, newToken)
+		}
+	}
+	if len(files) > 0 {
+		if _, err = client.AssociateFiles(ctx, created.Token, files); err != nil {
+			report.Failures[album.Token] = err
+			return
+		}
+	}
+	if album.Shared {
+		if _, err = client.ShareAlbum(ctx, created.Token); err != nil {
+			report.Failures[album.Token] = err
+		}
+	}
+}
+
+func writeArchiveEntry(archive *tar.Writer, name string, content io.Reader, size int64) error {
+	err := archive.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    size,
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	written, err := io.Copy(archive, content)
+	if err == nil && written != size {
+		err = fmt.Errorf("expected %d bytes, got %d", size, written)
+	}
+	return err
+}
+
+// detachableReader reads from reader until it is detached, after which every read fails
+type detachableReader struct {
+	mu       sync.Mutex
+	reader   io.Reader
+	detached bool
+}
+
+func (re *detachableReader) Read(p []byte) (int, error) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	if re.detached {
+		return 0, io.ErrClosedPipe
+	}
+	return re.reader.Read(p)
+}
+
+// detach waits for a running read to finish and stops any further reads
+func (re *detachableReader) detach() {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	re.detached = true
+}
+
+// isTarArchive checks if the reader contains a tar archive rather than a JSON manifest
+func isTarArchive(reader *bufio.Reader) (bool, error) {
+	for {
+		b, err := reader.Peek(1)
+		if err != nil {
+			return false, err
+		}
+		if !strings.ContainsRune(" \t\r\n", rune(b[0])) {
+			return b[0] != '{', nil
+		}
+		if _, err = reader.ReadByte(); err != nil {
+			return false, err
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package waifuVault
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+func TestExportBucket(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should export a manifest", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		file1 := fv.addFile(bucket, "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		file2 := fv.addFile(bucket, "secret.txt", []byte("secret"), mod.WaifuResponseOptions{HideFilename: true}, "pass")
+		album := fv.addAlbum(bucket, "album1", file2, file1)
+
+		var out bytes.Buffer
+		_, err := ExportBucket(ctx, NewWaifuvaltApi(http.Client{}), bucket, &out, mod.ExportBucketOpts{})
+		if err != nil {
+			t.Fatalf("ExportBucket failed: %v", err)
+		}
+
+		var manifest mod.BucketManifest
+		if err = json.Unmarshal(out.Bytes(), &manifest); err != nil {
+			t.Fatalf("Manifest is not valid JSON: %v", err)
+		}
+		if manifest.Version != mod.BucketManifestVersion {
+			t.Errorf("Expected version %d, got %d", mod.BucketManifestVersion, manifest.Version)
+		}
+		if len(manifest.Files) != 2 {
+			t.Fatalf("Expected 2 files, got %d", len(manifest.Files))
+		}
+		if manifest.Files[0].Filename != "08.png" {
+			t.Errorf("Expected filename 08.png, got %s", manifest.Files[0].Filename)
+		}
+		if !strings.HasSuffix(manifest.Files[1].Filename, ".txt") || manifest.Files[1].Content != "" {
+			t.Errorf("Unexpected hidden file entry %+v", manifest.Files[1])
+		}
+		if len(manifest.Albums) != 1 || manifest.Albums[0].Token != album {
+			t.Fatalf("Expected album %s, got %+v", album, manifest.Albums)
+		}
+		if manifest.Albums[0].Files[0] != file2 || manifest.Albums[0].Files[1] != file1 {
+			t.Errorf("Expected album files in album order, got %v", manifest.Albums[0].Files)
+		}
+	})
+
+	t.Run("should export the contents in a tar archive", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		fv.addFile(bucket, "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		protected := fv.addFile(bucket, "secret.txt", []byte("secret"), mod.WaifuResponseOptions{}, "pass")
+		fv.addFile(bucket, "once.txt", []byte("once"), mod.WaifuResponseOptions{OneTimeDownload: true}, "")
+
+		var out bytes.Buffer
+		report, err := ExportBucket(ctx, NewWaifuvaltApi(http.Client{}), bucket, &out, mod.ExportBucketOpts{
+			IncludeContents: true,
+			Passwords:       map[string]string{protected: "pass"},
+		})
+		if err != nil {
+			t.Fatalf("ExportBucket failed: %v", err)
+		}
+
+		archive := tar.NewReader(&out)
+		var names []string
+		contents := map[string]string{}
+		for {
+			header, err := archive.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Invalid archive: %v", err)
+			}
+			names = append(names, header.Name)
+			content, _ := io.ReadAll(archive)
+			contents[header.Name] = string(content)
+		}
+		if len(names) != 3 || names[0] != "manifest.json" {
+			t.Fatalf("Expected manifest and two files, got %v", names)
+		}
+		if contents["files/"+protected] != "secret" {
+			t.Errorf("Expected protected file contents, got %s", contents["files/"+protected])
+		}
+		if fv.requestCount(http.MethodGet, "/f/") != 2 {
+			t.Errorf("Expected the one time download file not to be downloaded")
+		}
+		if len(report.Failures) != 0 {
+			t.Errorf("Expected no failures, got %v", report.Failures)
+		}
+	})
+
+	t.Run("should skip and report files that can not be exported", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		file := fv.addFile(bucket, "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		protected := fv.addFile(bucket, "secret.txt", []byte("secret"), mod.WaifuResponseOptions{}, "pass")
+		wrongPassword := fv.addFile(bucket, "other.txt", []byte("other"), mod.WaifuResponseOptions{}, "pass")
+
+		var out bytes.Buffer
+		report, err := ExportBucket(ctx, NewWaifuvaltApi(http.Client{}), bucket, &out, mod.ExportBucketOpts{
+			IncludeContents: true,
+			Passwords:       map[string]string{wrongPassword: "wrong"},
+		})
+		if err != nil {
+			t.Fatalf("ExportBucket failed: %v", err)
+		}
+
+		if report.Failures[protected] == nil || report.Failures[wrongPassword] == nil || len(report.Failures) != 2 {
+			t.Errorf("Expected both protected files to be reported, got %v", report.Failures)
+		}
+		archive := tar.NewReader(&out)
+		var names []string
+		for {
+			header, err := archive.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Invalid archive: %v", err)
+			}
+			names = append(names, header.Name)
+		}
+		if len(names) != 2 || names[1] != "files/"+file {
+			t.Errorf("Expected manifest and %s, got %v", file, names)
+		}
+	})
+
+	t.Run("should handle error", func(t *testing.T) {
+		newFakeVault(t)
+
+		_, err := ExportBucket(ctx, NewWaifuvaltApi(http.Client{}), "missing", io.Discard, mod.ExportBucketOpts{})
+		if err == nil {
+			t.Fatal("Expected error but got none")
+		}
+	})
+}
+
+func TestImportBucket(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should recreate a bucket from an archive", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		file1 := fv.addFile(bucket, "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		file2 := fv.addFile(bucket, "secret.txt", []byte("secret"), mod.WaifuResponseOptions{HideFilename: true}, "pass")
+		album := fv.addAlbum(bucket, "album1", file2, file1)
+		api := NewWaifuvaltApi(http.Client{})
+		api.ShareAlbum(ctx, album)
+		passwords := map[string]string{file2: "pass"}
+
+		var out bytes.Buffer
+		_, err := ExportBucket(ctx, api, bucket, &out, mod.ExportBucketOpts{IncludeContents: true, Passwords: passwords})
+		if err != nil {
+			t.Fatalf("ExportBucket failed: %v", err)
+		}
+		report, err := ImportBucket(ctx, api, &out, mod.ImportBucketOpts{Passwords: passwords})
+		if err != nil {
+			t.Fatalf("ImportBucket failed: %v", err)
+		}
+
+		if len(report.Failures) != 0 {
+			t.Fatalf("Expected no failures, got %v", report.Failures)
+		}
+		if report.BucketToken == "" || report.BucketToken == bucket {
+			t.Errorf("Expected a new bucket, got %s", report.BucketToken)
+		}
+		newFile := fv.file(report.Files[file2])
+		if newFile == nil || string(newFile.content) != "secret" || newFile.password != "pass" {
+			t.Fatalf("Expected protected file to be re-uploaded, got %+v", newFile)
+		}
+		if !newFile.response.Options.HideFilename || newFile.response.Bucket != report.BucketToken {
+			t.Errorf("Expected options and bucket to be preserved, got %+v", newFile.response)
+		}
+		newAlbum := fv.album(report.Albums[album])
+		if newAlbum == nil || newAlbum.name != "album1" || newAlbum.publicToken == nil {
+			t.Fatalf("Expected shared album to be recreated, got %+v", newAlbum)
+		}
+		if len(newAlbum.files) != 2 || newAlbum.files[0] != report.Files[file2] || newAlbum.files[1] != report.Files[file1] {
+			t.Errorf("Expected album membership to be preserved, got %v", newAlbum.files)
+		}
+	})
+
+	t.Run("should re-upload from the original url without contents", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		target := fv.addBucket()
+		file1 := fv.addFile(bucket, "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		file2 := fv.addFile(bucket, "secret.txt", []byte("secret"), mod.WaifuResponseOptions{}, "pass")
+		api := NewWaifuvaltApi(http.Client{})
+
+		var out bytes.Buffer
+		if _, err := ExportBucket(ctx, api, bucket, &out, mod.ExportBucketOpts{}); err != nil {
+			t.Fatalf("ExportBucket failed: %v", err)
+		}
+		report, err := ImportBucket(ctx, api, &out, mod.ImportBucketOpts{BucketToken: target})
+		if err != nil {
+			t.Fatalf("ImportBucket failed: %v", err)
+		}
+
+		if report.BucketToken != target {
+			t.Errorf("Expected bucket %s, got %s", target, report.BucketToken)
+		}
+		if fv.file(report.Files[file1]).response.Bucket != target {
+			t.Errorf("Expected file to be uploaded into %s", target)
+		}
+		if report.Failures[file2] == nil {
+			t.Error("Expected protected file without contents to fail")
+		}
+	})
+
+	t.Run("should not re-upload one time download files from their url", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		target := fv.addBucket()
+		once := fv.addFile(bucket, "once.txt", []byte("once"), mod.WaifuResponseOptions{OneTimeDownload: true}, "")
+		api := NewWaifuvaltApi(http.Client{})
+
+		var out bytes.Buffer
+		if _, err := ExportBucket(ctx, api, bucket, &out, mod.ExportBucketOpts{IncludeContents: true}); err != nil {
+			t.Fatalf("ExportBucket failed: %v", err)
+		}
+		report, err := ImportBucket(ctx, api, &out, mod.ImportBucketOpts{BucketToken: target})
+		if err != nil {
+			t.Fatalf("ImportBucket failed: %v", err)
+		}
+		if report.Failures[once] == nil || fv.requestCount(http.MethodPut, "/rest") != 0 {
+			t.Errorf("Expected the one time download file not to be uploaded, got %v", report.Failures)
+		}
+		if fv.file(once) == nil {
+			t.Errorf("Expected the original to be kept")
+		}
+	})
+
+	t.Run("should reject unknown manifest versions", func(t *testing.T) {
+		newFakeVault(t)
+
+		_, err := ImportBucket(ctx, NewWaifuvaltApi(http.Client{}), strings.NewReader(`{"version": 99}`), mod.ImportBucketOpts{})
+		if err == nil {
+			t.Fatal("Expected error but got none")
+		}
+	})
+}
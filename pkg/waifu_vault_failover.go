@@ -0,0 +1,474 @@
+package waifuVault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+const defaultHealthInterval = 30 * time.Second
+
+// ErrNoHealthyInstance is returned when every instance of a failover client failed its health check
+var ErrNoHealthyInstance = errors.New("no WaifuVault instance is healthy")
+
+// FailoverApi sends requests to the first healthy of several WaifuVault instances and remembers which instance each
+// file, bucket and album token belongs to, so later calls with the token go to the same instance
+type FailoverApi struct {
+	instances []*failoverInstance
+	opts      mod.FailoverOpts
+
+	mu     sync.Mutex
+	tokens map[string]*failoverInstance
+}
+
+type failoverInstance struct {
+	url string
+	api *api
+
+	mu      sync.Mutex
+	healthy bool
+	checked time.Time
+}
+
+// NewFailoverApi creates a client for the instances in opts
+func NewFailoverApi(client http.Client, opts mod.FailoverOpts) (*FailoverApi, error) {
+	if len(opts.Instances) == 0 {
+		return nil, errors.New("at least one instance is required")
+	}
+	if opts.HealthInterval <= 0 {
+		opts.HealthInterval = defaultHealthInterval
+	}
+	re := &FailoverApi{opts: opts, tokens: map[string]*failoverInstance{}}
+	for _, instance := range opts.Instances {
+		clientOpts := opts.Client
+		clientOpts.BaseURL = strings.TrimSuffix(instance, "/")
+		re.instances = append(re.instances, &failoverInstance{
+			url: clientOpts.BaseURL,
+			api: &api{client: client, opts: clientOpts},
+		})
+	}
+	for token, instance := range opts.Tokens {
+		if found := re.instanceByUrl(instance); found != nil {
+			re.tokens[token] = found
+		}
+	}
+	return re, nil
+}
+
+// Instance returns the base URL of the instance a token was recorded for
+func (re *FailoverApi) Instance(token string) (string, bool) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	instance, ok := re.tokens[token]
+	if !ok {
+		return "", false
+	}
+	return instance.url, true
+}
+
+func (re *FailoverApi) UploadFile(ctx context.Context, options mod.WaifuvaultPutOpts) (*mod.WaifuResponse[string], error) {
+	var resp *mod.WaifuResponse[string]
+	var uploadedTo *failoverInstance
+	upload := func(instance *failoverInstance) (err error) {
+		resp, err = instance.api.UploadFile(ctx, options)
+		uploadedTo = instance
+		return err
+	}
+	var err error
+	switch {
+	case options.File != nil || options.Reader != nil:
+		// files and readers are consumed by the first attempt, so they can not be sent to another instance
+		err = re.once(ctx, options.BucketToken, upload)
+	case options.BucketToken != "":
+		err = re.route(ctx, options.BucketToken, upload)
+	default:
+		_, err = re.first(ctx, upload)
+	}
+	if err != nil {
+		return nil, err
+	}
+	re.record(resp.Token, uploadedTo)
+	return resp, nil
+}
+
+func (re *FailoverApi) FileInfo(ctx context.Context, token string) (resp *mod.WaifuResponse[int], err error) {
+	err = re.route(ctx, token, func(instance *failoverInstance) error {
+		resp, err = instance.api.FileInfo(ctx, token)
+		return err
+	})
+	return resp, err
+}
+
+func (re *FailoverApi) FileInfoFormatted(ctx context.Context, token string) (resp *mod.WaifuResponse[string], err error) {
+	err = re.route(ctx, token, func(instance *failoverInstance) error {
+		resp, err = instance.api.FileInfoFormatted(ctx, token)
+		return err
+	})
+	return resp, err
+}
+
+func (re *FailoverApi) DeleteFile(ctx context.Context, token string) (deleted bool, err error) {
+	err = re.route(ctx, token, func(instance *failoverInstance) error {
+		deleted, err = instance.api.DeleteFile(ctx, token)
+		return err
+	})
+	if deleted {
+		re.forget(token)
+	}
+	return deleted, err
+}
+
+func (re *FailoverApi) GetFile(ctx context.Context, options mod.GetFileInfo) (content []byte, err error) {
+	err = re.routeFile(ctx, options, func(instance *failoverInstance) error {
+		content, err = instance.api.GetFile(ctx, options)
+		return err
+	})
+	return content, err
+}
+
+func (re *FailoverApi) GetFileStream(ctx context.Context, options mod.GetFileInfo) (stream io.ReadCloser, err error) {
+	err = re.routeFile(ctx, options, func(instance *failoverInstance) error {
+		stream, err = instance.api.GetFileStream(ctx, options)
+		return err
+	})
+	return stream, err
+}
+
+func (re *FailoverApi) ModifyFile(ctx context.Context, token string, options mod.ModifyEntryPayload) (resp *mod.WaifuResponse[int], err error) {
+	err = re.route(ctx, token, func(instance *failoverInstance) error {
+		resp, err = instance.api.ModifyFile(ctx, token, options)
+		return err
+	})
+	return resp, err
+}
+
+func (re *FailoverApi) CreateBucket(ctx context.Context) (*mod.WaifuBucket, error) {
+	var bucket *mod.WaifuBucket
+	instance, err := re.first(ctx, func(instance *failoverInstance) (err error) {
+		bucket, err = instance.api.CreateBucket(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	re.record(bucket.Token, instance)
+	return bucket, nil
+}
+
+func (re *FailoverApi) GetBucket(ctx context.Context, token string) (bucket *mod.WaifuBucket, err error) {
+	err = re.route(ctx, token, func(instance *failoverInstance) error {
+		bucket, err = instance.api.GetBucket(ctx, token)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range bucket.Files {
+		re.recordFrom(token, file.Token)
+	}
+	for _, album := range bucket.Albums {
+		re.recordFrom(token, album.Token)
+	}
+	return bucket, nil
+}
+
+func (re *FailoverApi) DeleteBucket(ctx context.Context, token string) (deleted bool, err error) {
+	err = re.route(ctx, token, func(instance *failoverInstance) error {
+		deleted, err = instance.api.DeleteBucket(ctx, token)
+		return err
+	})
+	if deleted {
+		re.forget(token)
+	}
+	return deleted, err
+}
+
+func (re *FailoverApi) CreateAlbum(ctx context.Context, body mod.WaifuAlbumCreateBody) (album *mod.WaifuAlbum, err error) {
+	err = re.route(ctx, body.BucketToken, func(instance *failoverInstance) error {
+		album, err = instance.api.CreateAlbum(ctx, body)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	re.recordFrom(body.BucketToken, album.Token)
+	return album, nil
+}
+
+func (re *FailoverApi) AssociateFiles(ctx context.Context, albumToken string, filesToAssociate []string) (album *mod.WaifuAlbum, err error) {
+	err = re.route(ctx, albumToken, func(instance *failoverInstance) error {
+		album, err = instance.api.AssociateFiles(ctx, albumToken, filesToAssociate)
+		return err
+	})
+	return album, err
+}
+
+func (re *FailoverApi) DisassociateFiles(ctx context.Context, albumToken string, filesToDisassociate []string) (album *mod.WaifuAlbum, err error) {
+	err = re.route(ctx, albumToken, func(instance *failoverInstance) error {
+		album, err = instance.api.DisassociateFiles(ctx, albumToken, filesToDisassociate)
+		return err
+	})
+	return album, err
+}
+
+func (re *FailoverApi) GetAlbum(ctx context.Context, albumToken string) (album *mod.WaifuAlbum, err error) {
+	err = re.route(ctx, albumToken, func(instance *failoverInstance) error {
+		album, err = instance.api.GetAlbum(ctx, albumToken)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range album.Files {
+		re.recordFrom(albumToken, file.Token)
+	}
+	return album, nil
+}
+
+func (re *FailoverApi) DeleteAlbum(ctx context.Context, albumToken string, deleteFiles bool) (resp *mod.GenericSuccess, err error) {
+	err = re.route(ctx, albumToken, func(instance *failoverInstance) error {
+		resp, err = instance.api.DeleteAlbum(ctx, albumToken, deleteFiles)
+		return err
+	})
+	if err == nil && resp.Success {
+		re.forget(albumToken)
+	}
+	return resp, err
+}
+
+func (re *FailoverApi) ShareAlbum(ctx context.Context, albumToken string) (publicUrl string, err error) {
+	err = re.route(ctx, albumToken, func(instance *failoverInstance) error {
+		publicUrl, err = instance.api.ShareAlbum(ctx, albumToken)
+		return err
+	})
+	return publicUrl, err
+}
+
+func (re *FailoverApi) RevokeAlbum(ctx context.Context, albumToken string) (resp *mod.GenericSuccess, err error) {
+	err = re.route(ctx, albumToken, func(instance *failoverInstance) error {
+		resp, err = instance.api.RevokeAlbum(ctx, albumToken)
+		return err
+	})
+	return resp, err
+}
+
+func (re *FailoverApi) DownloadAlbum(ctx context.Context, albumToken string, files []int) (content []byte, err error) {
+	err = re.route(ctx, albumToken, func(instance *failoverInstance) error {
+		content, err = instance.api.DownloadAlbum(ctx, albumToken, files)
+		return err
+	})
+	return content, err
+}
+
+func (re *FailoverApi) DownloadAlbumStream(ctx context.Context, albumToken string, files []int) (stream io.ReadCloser, err error) {
+	err = re.route(ctx, albumToken, func(instance *failoverInstance) error {
+		stream, err = instance.api.DownloadAlbumStream(ctx, albumToken, files)
+		return err
+	})
+	return stream, err
+}
+
+// GetFileSize asks the instance that serves the file for its size
+func (re *FailoverApi) GetFileSize(ctx context.Context, options mod.GetFileInfo) (size int64, err error) {
+	err = re.routeFile(ctx, options, func(instance *failoverInstance) error {
+		size, err = instance.api.GetFileSize(ctx, options)
+		return err
+	})
+	return size, err
+}
+
+// route calls fn on the instance token belongs to. Unknown tokens are tried on every healthy instance in order,
+// and recorded for the first instance that accepts them
+func (re *FailoverApi) route(ctx context.Context, token string, fn func(instance *failoverInstance) error) error {
+	re.mu.Lock()
+	instance, ok := re.tokens[token]
+	re.mu.Unlock()
+	if ok {
+		err := fn(instance)
+		re.checkReachable(instance, err)
+		return err
+	}
+	instance, err := re.tryEach(ctx, fn, true)
+	if err != nil {
+		return err
+	}
+	re.record(token, instance)
+	return nil
+}
+
+// once calls fn a single time, on the instance token belongs to or else on the first healthy instance.
+// An unknown token is recorded for the instance if fn succeeds
+func (re *FailoverApi) once(ctx context.Context, token string, fn func(instance *failoverInstance) error) error {
+	re.mu.Lock()
+	instance, ok := re.tokens[token]
+	re.mu.Unlock()
+	if !ok {
+		var err error
+		if instance, err = re.healthy(ctx); err != nil {
+			return err
+		}
+	}
+	err := fn(instance)
+	re.checkReachable(instance, err)
+	if err == nil && !ok {
+		re.record(token, instance)
+	}
+	return err
+}
+
+// routeFile sends downloads by URL to the instance that serves the URL, and the rest like route
+func (re *FailoverApi) routeFile(ctx context.Context, options mod.GetFileInfo, fn func(instance *failoverInstance) error) error {
+	switch {
+	case options.Token != "":
+		return re.route(ctx, options.Token, fn)
+	case options.Url != "":
+		if instance := re.instanceByUrl(options.Url); instance != nil {
+			err := fn(instance)
+			re.checkReachable(instance, err)
+			return err
+		}
+	}
+	_, err := re.tryEach(ctx, fn, true)
+	return err
+}
+
+// first calls fn on the first healthy instance, moving on to the next one if the instance can not be reached
+func (re *FailoverApi) first(ctx context.Context, fn func(instance *failoverInstance) error) (*failoverInstance, error) {
+	return re.tryEach(ctx, fn, false)
+}
+
+// tryEach calls fn on the healthy instances in order until it succeeds. Instances that can not be reached are
+// always skipped, other errors are only skipped when all is set
+func (re *FailoverApi) tryEach(ctx context.Context, fn func(instance *failoverInstance) error, all bool) (*failoverInstance, error) {
+	var lastErr error
+	for _, instance := range re.instances {
+		if !re.isHealthy(ctx, instance) {
+			continue
+		}
+		err := fn(instance)
+		if err == nil {
+			return instance, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		if !re.checkReachable(instance, err) && !all {
+			return nil, err
+		}
+	}
+	if lastErr == nil {
+		return nil, ErrNoHealthyInstance
+	}
+	return nil, lastErr
+}
+
+// healthy returns the first healthy instance
+func (re *FailoverApi) healthy(ctx context.Context) (*failoverInstance, error) {
+	for _, instance := range re.instances {
+		if re.isHealthy(ctx, instance) {
+			return instance, nil
+		}
+	}
+	return nil, ErrNoHealthyInstance
+}
+
+// isHealthy returns the last result of the health check of instance, checking it again once it is too old.
+// The lock is not held during the check, so a slow instance does not block calls that only read its state
+func (re *FailoverApi) isHealthy(ctx context.Context, instance *failoverInstance) bool {
+	instance.mu.Lock()
+	healthy, checked := instance.healthy, instance.checked
+	instance.mu.Unlock()
+	if time.Since(checked) < re.opts.HealthInterval {
+		return healthy
+	}
+
+	if re.opts.HealthCheck != nil {
+		healthy = re.opts.HealthCheck(ctx, instance.url) == nil
+	} else {
+		healthy = instance.api.checkRestrictions(ctx) == nil
+	}
+	instance.mu.Lock()
+	instance.healthy, instance.checked = healthy, time.Now()
+	instance.mu.Unlock()
+	return healthy
+}
+
+// checkReachable marks the instance unhealthy until its next health check if err shows it can not be reached
+func (re *FailoverApi) checkReachable(instance *failoverInstance, err error) bool {
+	var urlErr *url.Error
+	unreachable := (errors.As(err, &urlErr) || errors.Is(err, ErrCircuitOpen)) &&
+		!errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	if unreachable {
+		instance.mu.Lock()
+		instance.healthy = false
+		instance.checked = time.Now()
+		instance.mu.Unlock()
+	}
+	return unreachable
+}
+
+func (re *FailoverApi) instanceByUrl(rawUrl string) *failoverInstance {
+	for _, instance := range re.instances {
+		if rawUrl == instance.url || strings.HasPrefix(rawUrl, instance.url+"/") {
+			return instance
+		}
+	}
+	return nil
+}
+
+// recordFrom records token for the instance of parent
+func (re *FailoverApi) recordFrom(parent, token string) {
+	re.mu.Lock()
+	instance, ok := re.tokens[parent]
+	re.mu.Unlock()
+	if ok {
+		re.record(token, instance)
+	}
+}
+
+func (re *FailoverApi) record(token string, instance *failoverInstance) {
+	if token == "" {
+		return
+	}
+	re.mu.Lock()
+	previous, ok := re.tokens[token]
+	re.tokens[token] = instance
+	re.mu.Unlock()
+	if (!ok || previous != instance) && re.opts.OnRecord != nil {
+		re.opts.OnRecord(token, instance.url)
+	}
+}
+
+func (re *FailoverApi) forget(token string) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	delete(re.tokens, token)
+}
+
+// checkRestrictions reports an instance healthy if it returns its upload restrictions.
+// The request goes through the middleware of the client like every other request
+func (re *api) checkRestrictions(ctx context.Context) error {
+	r, err := re.createRequest(ctx, http.MethodGet, re.getBaseUrl()+"/rest/resources/restrictions", nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := re.do(mod.OperationHealthCheck, r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check of %s returned %d", re.getBaseUrl(), resp.StatusCode)
+	}
+	return nil
+}
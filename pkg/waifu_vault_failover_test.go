@@ -0,0 +1,206 @@
+package waifuVault
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+// unreachableInstance is a base URL nothing listens on
+const unreachableInstance = "http://127.0.0.1:1"
+
+func TestFailoverApi(t *testing.T) {
+	ctx := context.Background()
+
+	newInstances := func(t *testing.T) (*fakeVault, *fakeVault) {
+		primary, mirror := newFakeVault(t), newFakeVault(t)
+		// tokens must not collide between instances
+		mirror.nextID = 1000
+		return primary, mirror
+	}
+
+	t.Run("should find and record the instance of a token", func(t *testing.T) {
+		primary, mirror := newInstances(t)
+		token := mirror.addFile("", "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+
+		recorded := map[string]string{}
+		api, err := NewFailoverApi(http.Client{}, mod.FailoverOpts{
+			Instances: []string{primary.server.URL, mirror.server.URL + "/"},
+			OnRecord:  func(token, baseURL string) { recorded[token] = baseURL },
+		})
+		if err != nil {
+			t.Fatalf("NewFailoverApi failed: %v", err)
+		}
+		if _, err = api.FileInfo(ctx, token); err != nil {
+			t.Fatalf("FileInfo failed: %v", err)
+		}
+		if instance, _ := api.Instance(token); instance != mirror.server.URL || recorded[token] != mirror.server.URL {
+			t.Errorf("Expected the token to be recorded for the mirror, got %s", instance)
+		}
+
+		if _, err = api.DeleteFile(ctx, token); err != nil {
+			t.Fatalf("DeleteFile failed: %v", err)
+		}
+		if primary.requestCount(http.MethodGet, "/rest/"+token) != 1 || primary.requestCount(http.MethodDelete, "/rest/") != 0 {
+			t.Errorf("Expected the primary to be asked once, got %v", primary.requests)
+		}
+		if mirror.file(token) != nil {
+			t.Errorf("Expected the file to be deleted from the mirror")
+		}
+	})
+
+	t.Run("should skip unhealthy instances", func(t *testing.T) {
+		_, mirror := newInstances(t)
+		api, _ := NewFailoverApi(http.Client{}, mod.FailoverOpts{Instances: []string{unreachableInstance, mirror.server.URL}})
+
+		bucket, err := api.CreateBucket(ctx)
+		if err != nil {
+			t.Fatalf("CreateBucket failed: %v", err)
+		}
+		content := []byte("image")
+		resp, err := api.UploadFile(ctx, mod.WaifuvaultPutOpts{Bytes: &content, FileName: "08.png", BucketToken: bucket.Token})
+		if err != nil {
+			t.Fatalf("UploadFile failed: %v", err)
+		}
+		if len(mirror.bucketFiles(bucket.Token)) != 1 {
+			t.Errorf("Expected the upload to go to the mirror")
+		}
+		if instance, _ := api.Instance(resp.Token); instance != mirror.server.URL {
+			t.Errorf("Expected the file to be recorded for the mirror, got %s", instance)
+		}
+	})
+
+	t.Run("should fail over when an instance can not be reached", func(t *testing.T) {
+		_, mirror := newInstances(t)
+		api, _ := NewFailoverApi(http.Client{}, mod.FailoverOpts{
+			Instances:   []string{unreachableInstance, mirror.server.URL},
+			HealthCheck: func(ctx context.Context, baseURL string) error { return nil },
+		})
+
+		content := []byte("image")
+		resp, err := api.UploadFile(ctx, mod.WaifuvaultPutOpts{Bytes: &content, FileName: "08.png"})
+		if err != nil {
+			t.Fatalf("UploadFile failed: %v", err)
+		}
+		if mirror.file(resp.Token) == nil {
+			t.Errorf("Expected the upload to go to the mirror")
+		}
+		if instance, _ := api.Instance(resp.Token); instance != mirror.server.URL {
+			t.Errorf("Expected the file to be recorded for the mirror, got %s", instance)
+		}
+	})
+
+	t.Run("should use recorded tokens", func(t *testing.T) {
+		primary, mirror := newInstances(t)
+		bucket := mirror.addBucket()
+		album := mirror.addAlbum(bucket, "album", mirror.addFile(bucket, "08.png", []byte("image"), mod.WaifuResponseOptions{}, ""))
+		api, _ := NewFailoverApi(http.Client{}, mod.FailoverOpts{
+			Instances: []string{primary.server.URL, mirror.server.URL},
+			Tokens:    map[string]string{album: mirror.server.URL},
+		})
+
+		resp, err := api.GetAlbum(ctx, album)
+		if err != nil {
+			t.Fatalf("GetAlbum failed: %v", err)
+		}
+		if primary.requestCount(http.MethodGet, "/rest/album/") != 0 {
+			t.Errorf("Expected the primary not to be asked, got %v", primary.requests)
+		}
+		if instance, _ := api.Instance(resp.Files[0].Token); instance != mirror.server.URL {
+			t.Errorf("Expected the files of the album to be recorded for the mirror, got %s", instance)
+		}
+	})
+
+	t.Run("should send uploads from a reader once", func(t *testing.T) {
+		_, mirror := newInstances(t)
+		bucket := mirror.addBucket()
+		api, _ := NewFailoverApi(http.Client{}, mod.FailoverOpts{
+			Instances:   []string{unreachableInstance, mirror.server.URL},
+			HealthCheck: func(ctx context.Context, baseURL string) error { return nil },
+		})
+
+		_, err := api.UploadFile(ctx, mod.WaifuvaultPutOpts{Reader: strings.NewReader("image"), FileName: "08.png", BucketToken: bucket})
+		if err == nil {
+			t.Fatalf("Expected the upload to the unreachable instance to fail")
+		}
+		if count := mirror.requestCount(http.MethodPut, "/rest"); count != 0 {
+			t.Errorf("Expected the reader not to be sent to the mirror, got %d uploads", count)
+		}
+
+		api, _ = NewFailoverApi(http.Client{}, mod.FailoverOpts{
+			Instances: []string{unreachableInstance, mirror.server.URL},
+			Tokens:    map[string]string{bucket: mirror.server.URL},
+		})
+		if _, err = api.UploadFile(ctx, mod.WaifuvaultPutOpts{Reader: strings.NewReader("image"), FileName: "08.png", BucketToken: bucket}); err != nil {
+			t.Fatalf("UploadFile failed: %v", err)
+		}
+		if len(mirror.bucketFiles(bucket)) != 1 {
+			t.Errorf("Expected the upload to go to the recorded instance of the bucket")
+		}
+	})
+
+	t.Run("should send the health check through the middleware", func(t *testing.T) {
+		primary, _ := newInstances(t)
+		var operations []mod.Operation
+		api, _ := NewFailoverApi(http.Client{}, mod.FailoverOpts{
+			Instances: []string{primary.server.URL},
+			Client: mod.ClientOpts{Middleware: []mod.Middleware{
+				mod.MiddlewareFunc(func(operation mod.Operation, r *http.Request, next mod.RequestHandler) (*http.Response, error) {
+					operations = append(operations, operation)
+					return next(r)
+				}),
+			}},
+		})
+
+		if _, err := api.CreateBucket(ctx); err != nil {
+			t.Fatalf("CreateBucket failed: %v", err)
+		}
+		if len(operations) != 2 || operations[0] != mod.OperationHealthCheck || operations[1] != mod.OperationCreateBucket {
+			t.Errorf("Expected a health check and CreateBucket, got %v", operations)
+		}
+	})
+
+	t.Run("should not block other calls during a health check", func(t *testing.T) {
+		primary, _ := newInstances(t)
+		release := make(chan struct{})
+		var checks atomic.Int32
+		api, _ := NewFailoverApi(http.Client{}, mod.FailoverOpts{
+			Instances: []string{primary.server.URL},
+			HealthCheck: func(ctx context.Context, baseURL string) error {
+				if checks.Add(1) == 1 {
+					<-release
+				}
+				return nil
+			},
+		})
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := api.CreateBucket(ctx)
+			done <- err
+		}()
+		for checks.Load() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		if _, err := api.CreateBucket(ctx); err != nil {
+			t.Fatalf("CreateBucket failed: %v", err)
+		}
+		close(release)
+		if err := <-done; err != nil {
+			t.Fatalf("CreateBucket failed: %v", err)
+		}
+	})
+
+	t.Run("should fail when no instance is healthy", func(t *testing.T) {
+		api, _ := NewFailoverApi(http.Client{}, mod.FailoverOpts{Instances: []string{unreachableInstance}})
+		if _, err := api.CreateBucket(ctx); !errors.Is(err, ErrNoHealthyInstance) {
+			t.Errorf("Expected ErrNoHealthyInstance, got %v", err)
+		}
+	})
+}
@@ -10,39 +10,37 @@ import (
 	"mime/multipart"
 	"net/http"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
 )
 
 func (re *api) UploadFile(ctx context.Context, options mod.WaifuvaultPutOpts) (*mod.WaifuResponse[string], error) {
-	if options.File != nil && options.Bytes != nil && options.Url != "" || options.File == nil && options.Bytes == nil && options.Url == "" {
-		return nil, errors.New("you can only supply buffer, file or url")
+	if countUploadSources(options) != 1 {
+		return nil, errors.New("you can only supply buffer, file, reader or url")
 	}
-	body := bytes.Buffer{}
+	var body io.Reader
 	var writer *multipart.Writer
-	if options.File != nil || options.Bytes != nil {
-
-		var fileBytes *bytes.Buffer
-		var fileFormWriter io.Writer
-		var err error
-
-		writer = multipart.NewWriter(&body)
+	if options.File != nil || options.Reader != nil {
+		fileName := options.FileName
 		if options.File != nil {
-			fileBytes = bytes.NewBuffer(nil)
-			_, err = io.Copy(fileBytes, options.File)
-			if err != nil {
-				return nil, err
-			}
-			fileFormWriter, err = writer.CreateFormFile("file", filepath.Base(options.File.Name()))
-
-		} else {
-			fileBytes = bytes.NewBuffer(*options.Bytes)
-			if options.FileName == "" {
-				return nil, errors.New("FileName must be set if bytes is used")
-			}
-			fileFormWriter, err = writer.CreateFormFile("file", options.FileName)
+			fileName = filepath.Base(options.File.Name())
+		} else if fileName == "" {
+			return nil, errors.New("FileName must be set if reader is used")
 		}
+		var content io.Reader = options.File
+		if options.Reader != nil {
+			content = options.Reader
+		}
+		body, writer = streamMultipart(options.Password, fileName, content)
+	} else if options.Bytes != nil {
+		buffer := bytes.Buffer{}
+		var fileFormWriter io.Writer
+		var err error
 
+		writer = multipart.NewWriter(&buffer)
 		if options.Password != "" {
 			passwordFormWriter, err := writer.CreateFormField("password")
 			if err != nil {
@@ -53,17 +51,22 @@ func (re *api) UploadFile(ctx context.Context, options mod.WaifuvaultPutOpts) (*
 			}
 		}
 
+		if options.FileName == "" {
+			return nil, errors.New("FileName must be set if bytes is used")
+		}
+		fileFormWriter, err = writer.CreateFormFile("file", options.FileName)
 		if err != nil {
 			return nil, err
 		}
 
-		if _, err = fileFormWriter.Write(fileBytes.Bytes()); err != nil {
+		if _, err = fileFormWriter.Write(*options.Bytes); err != nil {
 			return nil, err
 		}
 		err = writer.Close()
 		if err != nil {
 			return nil, err
 		}
+		body = &buffer
 	} else if options.Url != "" {
 		var bodyUrl string
 		if options.Password != "" {
@@ -71,26 +74,65 @@ func (re *api) UploadFile(ctx context.Context, options mod.WaifuvaultPutOpts) (*
 		} else {
 			bodyUrl = fmt.Sprintf(`{"url": "%s"}`, options.Url)
 		}
-		body = *bytes.NewBuffer([]byte(bodyUrl))
+		body = bytes.NewBuffer([]byte(bodyUrl))
 	}
 
-	uploadUrl := getUrl(map[string]any{
+	uploadUrl := re.getUrl(map[string]any{
 		"expires":           options.Expires,
 		"hide_filename":     options.HideFilename,
 		"one_time_download": options.OneTimeDownload,
 	}, options.BucketToken)
 
-	r, err := re.createRequest(ctx, http.MethodPut, uploadUrl, &body, writer)
+	r, err := re.createRequest(ctx, http.MethodPut, uploadUrl, body, writer)
 	if err != nil {
+		if closer, ok := body.(io.Closer); ok {
+			closer.Close()
+		}
 		return nil, err
 	}
-	resp, err := re.client.Do(r)
+	resp, err := re.do(mod.OperationUploadFile, r)
 	if err != nil {
 		return nil, err
 	}
 	return getResponse[string](resp)
 }
 
+// countUploadSources counts how many of the mutually exclusive file sources are set
+func countUploadSources(options mod.WaifuvaultPutOpts) int {
+	sources := 0
+	for _, set := range []bool{options.File != nil, options.Bytes != nil, options.Reader != nil, options.Url != ""} {
+		if set {
+			sources++
+		}
+	}
+	return sources
+}
+
+// streamMultipart encodes the upload form while it is being sent, so the content is never fully buffered
+func streamMultipart(password, fileName string, content io.Reader) (io.Reader, *multipart.Writer) {
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	go func() {
+		if password != "" {
+			if err := writer.WriteField("password", password); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		fileFormWriter, err := writer.CreateFormFile("file", fileName)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		if _, err = io.Copy(fileFormWriter, content); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.CloseWithError(writer.Close())
+	}()
+	return pr, writer
+}
+
 func (re *api) FileInfo(ctx context.Context, token string) (*mod.WaifuResponse[int], error) {
 	resp, err := re.createGetRequestForFileInfo(ctx, token, false)
 	if err != nil {
@@ -108,22 +150,28 @@ func (re *api) FileInfoFormatted(ctx context.Context, token string) (*mod.WaifuR
 }
 
 func (re *api) createGetRequestForFileInfo(ctx context.Context, token string, isFormatted bool) (*http.Response, error) {
-	getUrl := getUrl(map[string]any{"formatted": isFormatted}, token)
+	getUrl := re.getUrl(map[string]any{"formatted": isFormatted}, token)
 	r, err := re.createRequest(ctx, http.MethodGet, getUrl, nil, nil)
 	if err != nil {
 		return nil, err
 	}
-	return re.client.Do(r)
+	if isFormatted {
+		return re.do(mod.OperationFileInfoFormatted, r)
+	}
+	return re.do(mod.OperationFileInfo, r)
 }
 
 func (re *api) DeleteFile(ctx context.Context, token string) (bool, error) {
-	deleteUrl := getUrl(nil, token)
+	deleteUrl := re.getUrl(nil, token)
 
 	r, err := re.createRequest(ctx, http.MethodDelete, deleteUrl, nil, nil)
 	if err != nil {
 		return false, err
 	}
-	resp, err := re.client.Do(r)
+	resp, err := re.do(mod.OperationDeleteFile, r)
+	if err != nil {
+		return false, err
+	}
 	defer resp.Body.Close()
 
 	err = checkError(resp)
@@ -137,19 +185,44 @@ func (re *api) DeleteFile(ctx context.Context, token string) (bool, error) {
 }
 
 func (re *api) GetFile(ctx context.Context, options mod.GetFileInfo) ([]byte, error) {
+	body, err := re.getFileStream(ctx, mod.OperationGetFile, options)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+func (re *api) GetFileStream(ctx context.Context, options mod.GetFileInfo) (io.ReadCloser, error) {
+	return re.getFileStream(ctx, mod.OperationGetFileStream, options)
+}
+
+func (re *api) getFileStream(ctx context.Context, operation mod.Operation, options mod.GetFileInfo) (io.ReadCloser, error) {
 
-	if options.Filename == "" && options.Token == "" {
-		return nil, errors.New("please supply a token or a filename")
+	if options.Url == "" && options.Filename == "" && options.Token == "" {
+		return nil, errors.New("please supply a token, a filename or a url")
 	}
 	var fileUrl string
-	if options.Filename != "" {
-		fileUrl = fmt.Sprintf("%s/f/%s", baseUrl, options.Filename)
+	// files downloaded by Url or Filename can not be checked, so they may be one time downloads
+	oneTimeDownload := true
+	if options.Url != "" {
+		parsed, err := ParseFileURL(options.Url)
+		if err != nil {
+			return nil, err
+		}
+		fileUrl = parsed.String()
+	} else if options.Filename != "" {
+		fileUrl = fmt.Sprintf("%s/f/%s", re.getBaseUrl(), options.Filename)
 	} else {
 		fileInfo, err := re.FileInfo(ctx, options.Token)
 		if err != nil {
 			return nil, err
 		}
 		fileUrl = fileInfo.URL
+		oneTimeDownload = fileInfo.Options.OneTimeDownload
+	}
+	if oneTimeDownload {
+		ctx = withoutRetry(ctx)
 	}
 
 	r, err := re.createRequest(ctx, http.MethodGet, fileUrl, nil, nil)
@@ -161,27 +234,92 @@ func (re *api) GetFile(ctx context.Context, options mod.GetFileInfo) ([]byte, er
 		r.Header.Set("x-password", options.Password)
 	}
 
-	resp, err := re.client.Do(r)
-	defer resp.Body.Close()
-
+	resp, err := re.do(operation, r)
 	if err != nil {
 		return nil, err
 	}
 
 	if resp.StatusCode == http.StatusForbidden {
+		resp.Body.Close()
 		return nil, errors.New("password is incorrect")
 	}
 
 	err = checkError(resp)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	return &fileStream{ReadCloser: resp.Body, size: resp.ContentLength, contentType: resp.Header.Get("Content-Type")}, nil
+}
+
+// StreamFile downloads a file as a stream with GetFileStream if client is a mod.FileStreamer, as every client of this
+// package is. Other clients download it with GetFile, and the stream is read from memory
+func StreamFile(ctx context.Context, client mod.Waifuvalt, options mod.GetFileInfo) (io.ReadCloser, error) {
+	if streamer, ok := client.(mod.FileStreamer); ok {
+		return streamer.GetFileStream(ctx, options)
+	}
+	content, err := client.GetFile(ctx, options)
 	if err != nil {
 		return nil, err
 	}
+	return &fileStream{
+		ReadCloser:  io.NopCloser(bytes.NewReader(content)),
+		size:        int64(len(content)),
+		contentType: http.DetectContentType(content),
+	}, nil
+}
+
+// fileStream is the body of a download returned by GetFileStream
+type fileStream struct {
+	io.ReadCloser
+	size        int64
+	contentType string
+}
+
+// Size is the length of the file, -1 if the server did not send it
+func (re *fileStream) Size() int64 {
+	return re.size
+}
+
+// ContentType is the content type sent by the server
+func (re *fileStream) ContentType() string {
+	return re.contentType
+}
+
+// FileSize requests the size of a file with GetFileSize if client is a mod.FileSizer, as every client of this package
+// is. Other clients can not request sizes and return an error
+func FileSize(ctx context.Context, client mod.Waifuvalt, options mod.GetFileInfo) (int64, error) {
+	sizer, ok := client.(mod.FileSizer)
+	if !ok {
+		return 0, errors.New("the client can not request file sizes")
+	}
+	return sizer.GetFileSize(ctx, options)
+}
 
-	return io.ReadAll(resp.Body)
+// GetFileSize gets the size of a file from the Content-Length of a HEAD request on options.Url,
+// sent with options.Password for protected files
+func (re *api) GetFileSize(ctx context.Context, options mod.GetFileInfo) (int64, error) {
+	r, err := http.NewRequestWithContext(ctx, http.MethodHead, options.Url, nil)
+	if err != nil {
+		return 0, err
+	}
+	if options.Password != "" {
+		r.Header.Set("x-password", options.Password)
+	}
+	resp, err := re.do(mod.OperationFileSize, r)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ContentLength < 0 {
+		return 0, fmt.Errorf("the server returned %d", resp.StatusCode)
+	}
+	return resp.ContentLength, nil
 }
 
 func (re *api) ModifyFile(ctx context.Context, token string, options mod.ModifyEntryPayload) (*mod.WaifuResponse[int], error) {
-	uploadUrl := getUrl(nil, token)
+	uploadUrl := re.getUrl(nil, token)
 
 	jsonData, err := json.Marshal(options)
 	if err != nil {
@@ -191,9 +329,49 @@ func (re *api) ModifyFile(ctx context.Context, token string, options mod.ModifyE
 	if err != nil {
 		return nil, err
 	}
-	resp, err := re.client.Do(r)
+	resp, err := re.do(mod.OperationModifyFile, r)
 	if err != nil {
 		return nil, err
 	}
 	return getResponse[int](resp)
 }
+
+// retentionDuration converts an unformatted retention period (milliseconds until the file expires) to a duration
+func retentionDuration(period int) time.Duration {
+	return time.Duration(period) * time.Millisecond
+}
+
+// parseRetention converts a retention period returned as a string, either a number of milliseconds or
+// a human-readable string such as "332 days 7 hours 18 minutes 8 seconds", to a duration
+func parseRetention(period string) (time.Duration, error) {
+	if ms, err := strconv.Atoi(period); err == nil {
+		return retentionDuration(ms), nil
+	}
+	fields := strings.Fields(period)
+	if len(fields) == 0 || len(fields)%2 != 0 {
+		return 0, fmt.Errorf("invalid retention period %q", period)
+	}
+	var total time.Duration
+	for i := 0; i < len(fields); i += 2 {
+		value, err := strconv.Atoi(fields[i])
+		if err != nil {
+			return 0, fmt.Errorf("invalid retention period %q", period)
+		}
+		unit, found := retentionUnits[strings.TrimSuffix(strings.ToLower(fields[i+1]), "s")]
+		if !found {
+			return 0, fmt.Errorf("invalid retention period %q", period)
+		}
+		total += time.Duration(value) * unit
+	}
+	return total, nil
+}
+
+var retentionUnits = map[string]time.Duration{
+	"year":   365 * 24 * time.Hour,
+	"month":  30 * 24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+	"day":    24 * time.Hour,
+	"hour":   time.Hour,
+	"minute": time.Minute,
+	"second": time.Second,
+}
@@ -7,8 +7,10 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
 )
@@ -46,6 +48,39 @@ func TestUploadFile(t *testing.T) {
 		}
 	})
 
+	t.Run("should stream a file from a reader", func(t *testing.T) {
+		fv := newFakeVault(t)
+
+		api := NewWaifuvaltApi(http.Client{})
+		result, err := api.UploadFile(ctx, mod.WaifuvaultPutOpts{
+			Reader:   strings.NewReader("streamed content"),
+			FileName: "stream.txt",
+			Password: "foo",
+		})
+
+		if err != nil {
+			t.Fatalf("UploadFile failed: %v", err)
+		}
+		file := fv.file(result.Token)
+		if string(file.content) != "streamed content" || file.name != "stream.txt" || file.password != "foo" {
+			t.Errorf("Unexpected uploaded file %+v", file)
+		}
+	})
+
+	t.Run("should reject multiple sources", func(t *testing.T) {
+		fileBytes := []byte("test content")
+		api := NewWaifuvaltApi(http.Client{})
+		_, err := api.UploadFile(ctx, mod.WaifuvaultPutOpts{
+			Bytes:    &fileBytes,
+			Reader:   strings.NewReader("test content"),
+			FileName: "test.txt",
+		})
+
+		if err == nil {
+			t.Fatal("Expected error but got none")
+		}
+	})
+
 	t.Run("should upload a file from URL", func(t *testing.T) {
 		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if r.Method != http.MethodPut {
@@ -333,6 +368,41 @@ func TestGetFile(t *testing.T) {
 		}
 	})
 
+	t.Run("should get a file from a full url", func(t *testing.T) {
+		fileContent := []byte("file from url")
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/f/1710111505084/08.png" {
+				t.Errorf("Expected path /f/1710111505084/08.png, got %s", r.URL.Path)
+			}
+			w.WriteHeader(http.StatusOK)
+			w.Write(fileContent)
+		}))
+		defer server.Close()
+
+		api := NewWaifuvaltApi(http.Client{})
+		result, err := api.GetFile(ctx, mod.GetFileInfo{
+			Url: server.URL + "/f/1710111505084/08.png",
+		})
+
+		if err != nil {
+			t.Fatalf("GetFile failed: %v", err)
+		}
+		if !bytes.Equal(result, fileContent) {
+			t.Errorf("Expected file content %s, got %s", string(fileContent), string(result))
+		}
+	})
+
+	t.Run("should reject an invalid url", func(t *testing.T) {
+		api := NewWaifuvaltApi(http.Client{})
+		_, err := api.GetFile(ctx, mod.GetFileInfo{
+			Url: "https://waifuvault.moe/assets/08.png",
+		})
+
+		if err == nil {
+			t.Fatal("Expected error but got none")
+		}
+	})
+
 	t.Run("should handle incorrect password", func(t *testing.T) {
 		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusForbidden)
@@ -359,6 +429,87 @@ func TestGetFile(t *testing.T) {
 	})
 }
 
+func TestGetFileStream(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should stream a file", func(t *testing.T) {
+		fv := newFakeVault(t)
+		token := fv.addFile("", "08.png", []byte("streamed"), mod.WaifuResponseOptions{}, "")
+
+		api := NewWaifuvaltApi(http.Client{})
+		body, err := api.(mod.FileStreamer).GetFileStream(ctx, mod.GetFileInfo{Token: token})
+		if err != nil {
+			t.Fatalf("GetFileStream failed: %v", err)
+		}
+		defer body.Close()
+		result, _ := io.ReadAll(body)
+
+		if string(result) != "streamed" {
+			t.Errorf("Expected file content streamed, got %s", string(result))
+		}
+	})
+
+	t.Run("should handle incorrect password", func(t *testing.T) {
+		fv := newFakeVault(t)
+		token := fv.addFile("", "08.png", []byte("streamed"), mod.WaifuResponseOptions{}, "secret")
+
+		api := NewWaifuvaltApi(http.Client{})
+		_, err := api.(mod.FileStreamer).GetFileStream(ctx, mod.GetFileInfo{Token: token, Password: "wrong"})
+
+		if err == nil || !strings.Contains(err.Error(), "password is incorrect") {
+			t.Errorf("Expected password error, got: %v", err)
+		}
+	})
+
+	t.Run("should stream through the decorators", func(t *testing.T) {
+		api := NewWaifuvaltApi(http.Client{})
+		diskCache, err := NewDiskCacheApi(api, mod.DiskCacheOpts{Dir: t.TempDir()})
+		if err != nil {
+			t.Fatalf("NewDiskCacheApi failed: %v", err)
+		}
+		store, err := NewFileTokenStore(filepath.Join(t.TempDir(), "tokens.json"), "passphrase")
+		if err != nil {
+			t.Fatalf("NewFileTokenStore failed: %v", err)
+		}
+		decorated := map[string]mod.Waifuvalt{
+			"caching":    NewCachingApi(api, mod.CachingOpts{}),
+			"disk cache": diskCache,
+			"recording":  NewRecordingApi(api, store, mod.RecordingOpts{}),
+			"tracing":    NewTracingApi(api, NewSpanRecorder()),
+		}
+		for name, client := range decorated {
+			if _, ok := client.(mod.FileStreamer); !ok {
+				t.Errorf("Expected the %s client to stream files", name)
+			}
+			if _, ok := client.(mod.AlbumStreamer); !ok {
+				t.Errorf("Expected the %s client to stream albums", name)
+			}
+			if _, ok := client.(mod.FileSizer); !ok {
+				t.Errorf("Expected the %s client to request file sizes", name)
+			}
+		}
+	})
+
+	t.Run("should download with GetFile for other clients", func(t *testing.T) {
+		fv := newFakeVault(t)
+		token := fv.addFile("", "08.png", []byte("streamed"), mod.WaifuResponseOptions{}, "")
+		other := struct{ mod.Waifuvalt }{NewWaifuvaltApi(http.Client{})}
+
+		body, err := StreamFile(ctx, other, mod.GetFileInfo{Token: token})
+		if err != nil {
+			t.Fatalf("StreamFile failed: %v", err)
+		}
+		defer body.Close()
+		result, _ := io.ReadAll(body)
+		if string(result) != "streamed" || body.(interface{ Size() int64 }).Size() != int64(len("streamed")) {
+			t.Errorf("Expected file content streamed with its size, got %s", string(result))
+		}
+		if count := fv.requestCount(http.MethodGet, "/f/"); count != 1 {
+			t.Errorf("Expected 1 download, got %d", count)
+		}
+	})
+}
+
 func TestModifyFile(t *testing.T) {
 	ctx := context.Background()
 
@@ -422,3 +573,30 @@ func TestModifyFile(t *testing.T) {
 		}
 	})
 }
+
+func TestParseRetention(t *testing.T) {
+	t.Run("should parse milliseconds and formatted periods", func(t *testing.T) {
+		cases := map[string]time.Duration{
+			"1234":                                  1234 * time.Millisecond,
+			"332 days 7 hours 18 minutes 8 seconds": 332*24*time.Hour + 7*time.Hour + 18*time.Minute + 8*time.Second,
+			"1 day 1 hour 1 minute 1 second":        25*time.Hour + time.Minute + time.Second,
+		}
+		for period, expected := range cases {
+			result, err := parseRetention(period)
+			if err != nil {
+				t.Fatalf("parseRetention failed: %v", err)
+			}
+			if result != expected {
+				t.Errorf("Expected %s for %q, got %s", expected, period, result)
+			}
+		}
+	})
+
+	t.Run("should reject invalid periods", func(t *testing.T) {
+		for _, period := range []string{"", "soon", "3 fortnights"} {
+			if _, err := parseRetention(period); err == nil {
+				t.Errorf("Expected error for %q but got none", period)
+			}
+		}
+	})
+}
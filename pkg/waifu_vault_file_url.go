@@ -0,0 +1,71 @@
+package waifuVault
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+// ParseFileURL splits a file URL such as https://waifuvault.moe/f/1710111505084/08.png into its components.
+// URLs of files with hidden filenames (https://waifuvault.moe/f/1710111505084.png) and of self hosted instances under
+// a path prefix (https://example.com/vault/f/1710111505084/08.png) are also supported
+func ParseFileURL(fileUrl string) (mod.FileURL, error) {
+	u, err := url.Parse(fileUrl)
+	if err != nil {
+		return mod.FileURL{}, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return mod.FileURL{}, fmt.Errorf("%q is not an absolute URL", fileUrl)
+	}
+	// the identifier starts with the epoch, so the last /f/ is the one of the file even if the prefix contains one
+	escapedPath := u.EscapedPath()
+	index := strings.LastIndex(escapedPath, "/f/")
+	if index < 0 || index+len("/f/") == len(escapedPath) {
+		return mod.FileURL{}, fmt.Errorf("%q is not a file URL", fileUrl)
+	}
+	identifier := escapedPath[index+len("/f/"):]
+
+	result := mod.FileURL{
+		Host: fmt.Sprintf("%s://%s%s", u.Scheme, u.Host, escapedPath[:index]),
+	}
+	epochStr, escapedName, hasName := strings.Cut(identifier, "/")
+	if hasName {
+		if escapedName == "" || strings.Contains(escapedName, "/") {
+			return mod.FileURL{}, fmt.Errorf("%q is not a file URL", fileUrl)
+		}
+		result.Filename, err = url.PathUnescape(escapedName)
+		if err != nil {
+			return mod.FileURL{}, err
+		}
+		result.Extension = path.Ext(result.Filename)
+	} else {
+		result.Hidden = true
+		result.Extension = path.Ext(epochStr)
+		epochStr = strings.TrimSuffix(epochStr, result.Extension)
+	}
+
+	epoch, err := strconv.ParseInt(epochStr, 10, 64)
+	if err != nil {
+		return mod.FileURL{}, errors.New("file URL does not contain a valid upload epoch")
+	}
+	result.Epoch = time.UnixMilli(epoch)
+	return result, nil
+}
+
+// uploadFilename is the name used to upload an existing file again, the epoch and extension if the filename is hidden
+func uploadFilename(file mod.WaifuResponse[int]) string {
+	parsed, err := ParseFileURL(file.URL)
+	if err != nil {
+		return file.Token
+	}
+	if parsed.Hidden {
+		return parsed.Path()
+	}
+	return parsed.Filename
+}
@@ -0,0 +1,137 @@
+package waifuVault
+
+import (
+	"testing"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+func TestParseFileURL(t *testing.T) {
+	t.Run("should parse a file url", func(t *testing.T) {
+		result, err := ParseFileURL("https://waifuvault.moe/f/1710111505084/08.png")
+
+		if err != nil {
+			t.Fatalf("ParseFileURL failed: %v", err)
+		}
+		if result.Host != "https://waifuvault.moe" {
+			t.Errorf("Expected host https://waifuvault.moe, got %s", result.Host)
+		}
+		if !result.Epoch.Equal(time.UnixMilli(1710111505084)) {
+			t.Errorf("Expected epoch 1710111505084, got %d", result.Epoch.UnixMilli())
+		}
+		if result.Filename != "08.png" {
+			t.Errorf("Expected filename 08.png, got %s", result.Filename)
+		}
+		if result.Extension != ".png" {
+			t.Errorf("Expected extension .png, got %s", result.Extension)
+		}
+		if result.Hidden {
+			t.Error("Expected filename not to be hidden")
+		}
+	})
+
+	t.Run("should parse a file url with a hidden filename", func(t *testing.T) {
+		result, err := ParseFileURL("https://waifuvault.moe/f/1710111505084.png")
+
+		if err != nil {
+			t.Fatalf("ParseFileURL failed: %v", err)
+		}
+		if !result.Hidden {
+			t.Error("Expected filename to be hidden")
+		}
+		if result.Filename != "" {
+			t.Errorf("Expected no filename, got %s", result.Filename)
+		}
+		if result.Extension != ".png" {
+			t.Errorf("Expected extension .png, got %s", result.Extension)
+		}
+		if result.Epoch.UnixMilli() != 1710111505084 {
+			t.Errorf("Expected epoch 1710111505084, got %d", result.Epoch.UnixMilli())
+		}
+	})
+
+	t.Run("should unescape the filename", func(t *testing.T) {
+		result, err := ParseFileURL("http://localhost:8080/f/1710111505084/my%20file.tar.gz")
+
+		if err != nil {
+			t.Fatalf("ParseFileURL failed: %v", err)
+		}
+		if result.Host != "http://localhost:8080" {
+			t.Errorf("Expected host http://localhost:8080, got %s", result.Host)
+		}
+		if result.Filename != "my file.tar.gz" {
+			t.Errorf("Expected filename my file.tar.gz, got %s", result.Filename)
+		}
+		if result.Extension != ".gz" {
+			t.Errorf("Expected extension .gz, got %s", result.Extension)
+		}
+	})
+
+	t.Run("should parse a file url under a path prefix", func(t *testing.T) {
+		result, err := ParseFileURL("https://example.com/f/vault/f/1710111505084.png")
+
+		if err != nil {
+			t.Fatalf("ParseFileURL failed: %v", err)
+		}
+		if result.Host != "https://example.com/f/vault" {
+			t.Errorf("Expected host https://example.com/f/vault, got %s", result.Host)
+		}
+		if !result.Hidden || result.Path() != "1710111505084.png" {
+			t.Errorf("Expected hidden file 1710111505084.png, got %s", result.Path())
+		}
+	})
+
+	t.Run("should reject invalid urls", func(t *testing.T) {
+		invalid := []string{
+			"1710111505084/08.png",
+			"https://waifuvault.moe/assets/08.png",
+			"https://waifuvault.moe/f/",
+			"https://waifuvault.moe",
+			"https://waifuvault.moe/vault/f/",
+			"https://waifuvault.moe/f/notanepoch/08.png",
+			"https://waifuvault.moe/f/1710111505084/a/08.png",
+		}
+		for _, u := range invalid {
+			if _, err := ParseFileURL(u); err == nil {
+				t.Errorf("Expected error for %s but got none", u)
+			}
+		}
+	})
+}
+
+func TestFileURLString(t *testing.T) {
+	t.Run("should round trip file urls", func(t *testing.T) {
+		urls := []string{
+			"https://waifuvault.moe/f/1710111505084/08.png",
+			"https://waifuvault.moe/f/1710111505084.png",
+			"https://waifuvault.moe/f/1710111505084/my%20file.txt",
+			"https://waifuvault.moe/f/1710111505084",
+			"https://example.com/vault/f/1710111505084/08.png",
+		}
+		for _, u := range urls {
+			parsed, err := ParseFileURL(u)
+			if err != nil {
+				t.Fatalf("ParseFileURL failed: %v", err)
+			}
+			if result := parsed.String(); result != u {
+				t.Errorf("Expected %s, got %s", u, result)
+			}
+		}
+	})
+
+	t.Run("should build the unique identifier", func(t *testing.T) {
+		fileUrl := mod.FileURL{
+			Host:      "https://waifuvault.moe/",
+			Epoch:     time.UnixMilli(1710111505084),
+			Filename:  "08.png",
+			Extension: ".png",
+		}
+		if fileUrl.Path() != "1710111505084/08.png" {
+			t.Errorf("Expected 1710111505084/08.png, got %s", fileUrl.Path())
+		}
+		if fileUrl.String() != "https://waifuvault.moe/f/1710111505084/08.png" {
+			t.Errorf("Unexpected url %s", fileUrl.String())
+		}
+	})
+}
@@ -0,0 +1,511 @@
+package waifuVault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+const defaultBucketFSTTL = time.Minute
+
+// bucketFileHead is how much of the start of a file is kept, so content sniffing can seek back without downloading again
+const bucketFileHead = 512
+
+// BucketFS is a read-only fs.FS over the contents of a bucket. Albums are directories and files that are not
+// in an album are in the root. Hidden filenames appear as the epoch and extension. One time download files are not
+// listed, as any request for them would use them up.
+// The bucket does not report file sizes, so they are requested with a HEAD request once and remembered, a file whose
+// size can not be requested is listed with a size of 0. Opening a file does not download it, the download starts on
+// the first Read
+type BucketFS struct {
+	ctx    context.Context
+	client mod.Waifuvalt
+	token  string
+	opts   mod.BucketFSOpts
+
+	mu         sync.Mutex
+	root       *bucketEntry
+	fetched    time.Time
+	generation int
+	sizes      map[string]int64
+}
+
+var (
+	_ fs.ReadDirFS = (*BucketFS)(nil)
+	_ fs.StatFS    = (*BucketFS)(nil)
+)
+
+// bucketEntry is a file or directory in a BucketFS
+type bucketEntry struct {
+	name     string
+	modTime  time.Time
+	file     *mod.WaifuResponse[int]
+	album    *mod.AlbumStub
+	children map[string]*bucketEntry
+}
+
+// NewBucketFS creates a fs.FS over a bucket. ctx is used for every request made by the file system
+func NewBucketFS(ctx context.Context, client mod.Waifuvalt, token string, opts mod.BucketFSOpts) *BucketFS {
+	if opts.TTL <= 0 {
+		opts.TTL = defaultBucketFSTTL
+	}
+	return &BucketFS{
+		ctx:    ctx,
+		client: client,
+		token:  token,
+		opts:   opts,
+		sizes:  map[string]int64{},
+	}
+}
+
+func (re *BucketFS) Open(name string) (fs.File, error) {
+	entry, err := re.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if entry.file == nil {
+		return &bucketDirFile{fs: re, entry: entry}, nil
+	}
+	return &bucketFile{fs: re, entry: entry, name: name, size: -1}, nil
+}
+
+func (re *BucketFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entry, err := re.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if entry.file != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return re.dirEntries(entry), nil
+}
+
+func (re *BucketFS) Stat(name string) (fs.FileInfo, error) {
+	entry, err := re.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	info := re.info(entry)
+	if entry.file != nil {
+		if info.size, err = re.sizeOf(entry.file); err != nil {
+			return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+		}
+	}
+	return info, nil
+}
+
+// Invalidate discards the cached bucket listing
+func (re *BucketFS) Invalidate() {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	re.root = nil
+	re.generation++
+}
+
+func (re *BucketFS) lookup(op, name string) (*bucketEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	root, err := re.listing()
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	if name == "." {
+		return root, nil
+	}
+	entry := root
+	for _, part := range strings.Split(name, "/") {
+		child, found := entry.children[part]
+		if !found {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		entry = child
+	}
+	return entry, nil
+}
+
+// listing returns the cached bucket tree, fetching it again once the TTL has elapsed.
+// The lock is not held while fetching, so a slow bucket does not block calls that use the cached sizes
+func (re *BucketFS) listing() (*bucketEntry, error) {
+	re.mu.Lock()
+	root, fetched, generation := re.root, re.fetched, re.generation
+	re.mu.Unlock()
+	if root != nil && time.Since(fetched) < re.opts.TTL {
+		return root, nil
+	}
+
+	root, err := re.fetch()
+	if err != nil {
+		return nil, err
+	}
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	// a listing that was invalidated while it was fetched may be out of date, so it is used once but not cached
+	if re.generation == generation {
+		re.root = root
+		re.fetched = time.Now()
+	}
+	return root, nil
+}
+
+// fetch lists the bucket and its albums as a tree
+func (re *BucketFS) fetch() (*bucketEntry, error) {
+	bucket, err := re.client.GetBucket(re.ctx, re.token)
+	if err != nil {
+		return nil, err
+	}
+	root := &bucketEntry{name: ".", children: map[string]*bucketEntry{}}
+	inAlbum := map[string]bool{}
+	for _, stub := range bucket.Albums {
+		album, err := re.client.GetAlbum(re.ctx, stub.Token)
+		if err != nil {
+			return nil, err
+		}
+		dir := &bucketEntry{
+			name:     uniqueEntryName(root, strings.ReplaceAll(stub.Name, "/", "_"), stub.Token),
+			modTime:  time.UnixMilli(stub.DateCreated),
+			album:    &stub,
+			children: map[string]*bucketEntry{},
+		}
+		root.children[dir.name] = dir
+		for _, file := range album.Files {
+			inAlbum[file.Token] = true
+			addFileEntry(dir, file)
+		}
+	}
+	for _, file := range bucket.Files {
+		if !inAlbum[file.Token] {
+			addFileEntry(root, file)
+		}
+	}
+	return root, nil
+}
+
+func addFileEntry(dir *bucketEntry, file mod.WaifuResponse[int]) {
+	if file.Options.OneTimeDownload {
+		return
+	}
+	entry := &bucketEntry{file: &file}
+	if parsed, err := ParseFileURL(file.URL); err == nil {
+		entry.modTime = parsed.Epoch
+	}
+	entry.name = uniqueEntryName(dir, uploadFilename(file), fmt.Sprint(file.ID))
+	dir.children[entry.name] = entry
+}
+
+// uniqueEntryName suffixes name with id if the directory already contains an entry with that name
+func uniqueEntryName(dir *bucketEntry, name, id string) string {
+	if name == "" || name == "." || name == ".." {
+		name = id
+	}
+	if _, taken := dir.children[name]; !taken {
+		return name
+	}
+	ext := path.Ext(name)
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(name, ext), id, ext)
+}
+
+func (re *BucketFS) dirEntries(dir *bucketEntry) []fs.DirEntry {
+	entries := make([]fs.DirEntry, 0, len(dir.children))
+	for _, child := range dir.children {
+		info := re.info(child)
+		if child.file != nil {
+			// a file whose size can not be requested is still listed
+			info.size, _ = re.sizeOf(child.file)
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries
+}
+
+func (re *BucketFS) info(entry *bucketEntry) *bucketFileInfo {
+	info := &bucketFileInfo{entry: entry}
+	if entry.file != nil {
+		re.mu.Lock()
+		info.size = re.sizes[entry.file.Token]
+		re.mu.Unlock()
+	}
+	return info
+}
+
+// sizeOf returns the remembered size of a file, or requests it with a HEAD request.
+// Clients that can not request sizes report 0 until the file has been read
+func (re *BucketFS) sizeOf(file *mod.WaifuResponse[int]) (int64, error) {
+	re.mu.Lock()
+	size, known := re.sizes[file.Token]
+	re.mu.Unlock()
+	if known {
+		return size, nil
+	}
+	if _, ok := re.client.(mod.FileSizer); !ok {
+		return 0, nil
+	}
+	size, err := FileSize(re.ctx, re.client, mod.GetFileInfo{Url: file.URL, Password: re.opts.Passwords[file.Token]})
+	if err != nil {
+		return 0, err
+	}
+	re.rememberSize(file.Token, size)
+	return size, nil
+}
+
+// knownSize returns the size of a file that was read before, or -1
+func (re *BucketFS) knownSize(token string) int64 {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	if size, ok := re.sizes[token]; ok {
+		return size
+	}
+	return -1
+}
+
+func (re *BucketFS) rememberSize(token string, size int64) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	re.sizes[token] = size
+}
+
+type bucketFileInfo struct {
+	entry *bucketEntry
+	size  int64
+}
+
+func (re *bucketFileInfo) Name() string {
+	return path.Base(re.entry.name)
+}
+
+func (re *bucketFileInfo) Size() int64 {
+	return re.size
+}
+
+func (re *bucketFileInfo) Mode() fs.FileMode {
+	if re.IsDir() {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+func (re *bucketFileInfo) ModTime() time.Time {
+	return re.entry.modTime
+}
+
+func (re *bucketFileInfo) IsDir() bool {
+	return re.entry.file == nil
+}
+
+// Sys returns the *mod.WaifuResponse[int] of files, the *mod.AlbumStub of albums and nil for the root
+func (re *bucketFileInfo) Sys() any {
+	switch {
+	case re.entry.file != nil:
+		return re.entry.file
+	case re.entry.album != nil:
+		return re.entry.album
+	default:
+		return nil
+	}
+}
+
+// bucketFile is an open file in a BucketFS, its contents are streamed from the download once they are needed.
+// Seeking only moves the position, the download is skipped forward or started again on the next read,
+// unless the position is in the start of the file that was kept
+type bucketFile struct {
+	fs     *BucketFS
+	entry  *bucketEntry
+	name   string
+	body   io.ReadCloser
+	head   []byte
+	offset int64
+	pos    int64
+	size   int64
+	closed bool
+}
+
+// open starts a new download and skips to offset
+func (re *bucketFile) open(offset int64) error {
+	if re.body != nil {
+		re.body.Close()
+		re.body = nil
+	}
+	file := re.entry.file
+	body, err := StreamFile(re.fs.ctx, re.fs.client, mod.GetFileInfo{Url: file.URL, Password: re.fs.opts.Passwords[file.Token]})
+	if err != nil {
+		return err
+	}
+	re.body = body
+	re.offset = 0
+	re.size = -1
+	if sized, ok := body.(interface{ Size() int64 }); ok && sized.Size() >= 0 {
+		re.size = sized.Size()
+		re.fs.rememberSize(file.Token, re.size)
+	}
+	return re.skip(offset)
+}
+
+// skip discards the download up to offset, keeping the start of the file. Reaching the end of the file first is not an error
+func (re *bucketFile) skip(offset int64) error {
+	if keep := min(offset, bucketFileHead) - re.offset; keep > 0 && re.offset == int64(len(re.head)) {
+		kept := make([]byte, keep)
+		n, err := io.ReadFull(re.body, kept)
+		re.head = append(re.head, kept[:n]...)
+		re.offset += int64(n)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	skipped, err := io.CopyN(io.Discard, re.body, offset-re.offset)
+	re.offset += skipped
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+func (re *bucketFile) Read(p []byte) (int, error) {
+	if re.closed {
+		return 0, &fs.PathError{Op: "read", Path: re.name, Err: fs.ErrClosed}
+	}
+	if re.pos < re.offset && re.pos < int64(len(re.head)) {
+		n := copy(p, re.head[re.pos:])
+		re.pos += int64(n)
+		return n, nil
+	}
+	var err error
+	switch {
+	case re.body == nil || re.pos < re.offset:
+		err = re.open(re.pos)
+	case re.pos > re.offset:
+		err = re.skip(re.pos)
+	}
+	if err != nil {
+		return 0, &fs.PathError{Op: "read", Path: re.name, Err: err}
+	}
+	n, err := re.body.Read(p)
+	if re.offset == int64(len(re.head)) && len(re.head) < bucketFileHead {
+		re.head = append(re.head, p[:min(n, bucketFileHead-len(re.head))]...)
+	}
+	re.offset += int64(n)
+	re.pos += int64(n)
+	return n, err
+}
+
+// Seek is supported by downloading the file again when seeking backwards past the kept start of the file
+func (re *bucketFile) Seek(offset int64, whence int) (int64, error) {
+	if re.closed {
+		return 0, &fs.PathError{Op: "seek", Path: re.name, Err: fs.ErrClosed}
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += re.pos
+	case io.SeekEnd:
+		if err := re.learnSize(); err != nil {
+			return 0, &fs.PathError{Op: "seek", Path: re.name, Err: err}
+		}
+		if re.size < 0 {
+			return 0, &fs.PathError{Op: "seek", Path: re.name, Err: errors.New("file size is unknown")}
+		}
+		offset += re.size
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: re.name, Err: fs.ErrInvalid}
+	}
+	re.pos = offset
+	return offset, nil
+}
+
+// learnSize requests the size of the file if it is not known yet. Clients that can not request sizes start the
+// download instead, as it reports the size
+func (re *bucketFile) learnSize() error {
+	if re.size >= 0 || re.body != nil {
+		return nil
+	}
+	if re.size = re.fs.knownSize(re.entry.file.Token); re.size >= 0 {
+		return nil
+	}
+	if _, ok := re.fs.client.(mod.FileSizer); !ok {
+		return re.open(re.offset)
+	}
+	size, err := re.fs.sizeOf(re.entry.file)
+	if err != nil {
+		return err
+	}
+	re.size = size
+	return nil
+}
+
+func (re *bucketFile) Stat() (fs.FileInfo, error) {
+	if re.closed {
+		return nil, &fs.PathError{Op: "stat", Path: re.name, Err: fs.ErrClosed}
+	}
+	if err := re.learnSize(); err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: re.name, Err: err}
+	}
+	info := re.fs.info(re.entry)
+	if re.size >= 0 {
+		info.size = re.size
+	}
+	return info, nil
+}
+
+func (re *bucketFile) Close() error {
+	if re.closed {
+		return &fs.PathError{Op: "close", Path: re.name, Err: fs.ErrClosed}
+	}
+	re.closed = true
+	if re.body == nil {
+		return nil
+	}
+	err := re.body.Close()
+	re.body = nil
+	return err
+}
+
+// bucketDirFile is an open directory in a BucketFS
+type bucketDirFile struct {
+	fs      *BucketFS
+	entry   *bucketEntry
+	entries []fs.DirEntry
+	read    int
+}
+
+func (re *bucketDirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: re.entry.name, Err: errors.New("is a directory")}
+}
+
+func (re *bucketDirFile) Stat() (fs.FileInfo, error) {
+	return re.fs.info(re.entry), nil
+}
+
+func (re *bucketDirFile) Close() error {
+	return nil
+}
+
+func (re *bucketDirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if re.entries == nil {
+		re.entries = re.fs.dirEntries(re.entry)
+	}
+	remaining := re.entries[re.read:]
+	if n <= 0 {
+		re.read = len(re.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(remaining))
+	re.read += n
+	return remaining[:n], nil
+}
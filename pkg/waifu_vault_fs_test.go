@@ -0,0 +1,263 @@
+package waifuVault
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+func TestBucketFS(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should list albums as directories", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		fv.addFile(bucket, "readme.txt", []byte("readme"), mod.WaifuResponseOptions{}, "")
+		image := fv.addFile(bucket, "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		hidden := fv.addFile(bucket, "hidden.png", []byte("hidden"), mod.WaifuResponseOptions{HideFilename: true}, "")
+		fv.addAlbum(bucket, "images", image, hidden)
+
+		fsys := NewBucketFS(ctx, NewWaifuvaltApi(http.Client{}), bucket, mod.BucketFSOpts{})
+		var paths []string
+		err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			paths = append(paths, path)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("WalkDir failed: %v", err)
+		}
+
+		hiddenName := "images/" + uploadFilename(fv.file(hidden).response)
+		expected := []string{".", "images", "images/08.png", hiddenName, "readme.txt"}
+		slices.Sort(expected)
+		if !slices.Equal(paths, expected) {
+			t.Errorf("Expected %v, got %v", expected, paths)
+		}
+		info, err := fsys.Stat("images")
+		if err != nil || !info.IsDir() {
+			t.Errorf("Expected images to be a directory, got %v", err)
+		}
+	})
+
+	t.Run("should read files", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		token := fv.addFile(bucket, "secret.txt", []byte("secret content"), mod.WaifuResponseOptions{}, "pass")
+
+		fsys := NewBucketFS(ctx, NewWaifuvaltApi(http.Client{}), bucket, mod.BucketFSOpts{
+			Passwords: map[string]string{token: "pass"},
+		})
+		content, err := fs.ReadFile(fsys, "secret.txt")
+		if err != nil {
+			t.Fatalf("ReadFile failed: %v", err)
+		}
+		if string(content) != "secret content" {
+			t.Errorf("Expected secret content, got %s", string(content))
+		}
+
+		info, err := fsys.Stat("secret.txt")
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		if info.Size() != int64(len("secret content")) {
+			t.Errorf("Expected the size to be known after opening, got %d", info.Size())
+		}
+		if info.ModTime().UnixMilli() != fv.epoch {
+			t.Errorf("Expected mod time from the upload epoch, got %s", info.ModTime())
+		}
+		if info.Sys().(*mod.WaifuResponse[int]).Token != token {
+			t.Errorf("Expected Sys to return the file")
+		}
+	})
+
+	t.Run("should seek by downloading again", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		fv.addFile(bucket, "data.bin", append(bytes.Repeat([]byte("-"), bucketFileHead), "0123456789"...), mod.WaifuResponseOptions{}, "")
+
+		fsys := NewBucketFS(ctx, NewWaifuvaltApi(http.Client{}), bucket, mod.BucketFSOpts{})
+		file, err := fsys.Open("data.bin")
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		defer file.Close()
+		seeker := file.(io.ReadSeeker)
+		head := make([]byte, 4)
+		io.ReadFull(seeker, head)
+		seeker.Seek(-3, io.SeekEnd)
+		tail, _ := io.ReadAll(seeker)
+		seeker.Seek(1, io.SeekStart)
+		io.ReadFull(seeker, head)
+		if string(tail) != "789" || string(head) != "----" || fv.requestCount(http.MethodGet, "/f/") != 1 {
+			t.Errorf("Expected the start of the file to be kept, got %s and %s", tail, head)
+		}
+
+		seeker.Seek(bucketFileHead+1, io.SeekStart)
+		io.ReadFull(seeker, head)
+		if string(head) != "1234" || fv.requestCount(http.MethodGet, "/f/") != 2 {
+			t.Errorf("Expected seeking backwards to download again, got %s", head)
+		}
+	})
+
+	t.Run("should only download files once they are read", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		fv.addFile(bucket, "data.bin", []byte("0123456789"), mod.WaifuResponseOptions{}, "")
+
+		fsys := NewBucketFS(ctx, NewWaifuvaltApi(http.Client{}), bucket, mod.BucketFSOpts{})
+		file, err := fsys.Open("data.bin")
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		file.Close()
+		if count := fv.requestCount(http.MethodGet, "/f/"); count != 0 {
+			t.Errorf("Expected opening not to download the file, got %d downloads", count)
+		}
+
+		file, _ = fsys.Open("data.bin")
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		if info.Size() != 10 || fv.requestCount(http.MethodGet, "/f/") != 0 || fv.requestCount(http.MethodHead, "/f/") != 1 {
+			t.Errorf("Expected Stat to request the size without downloading, got %d", info.Size())
+		}
+	})
+
+	t.Run("should list files with their sizes", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		fv.addFile(bucket, "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		fv.addFile(bucket, "once.png", []byte("image"), mod.WaifuResponseOptions{OneTimeDownload: true}, "")
+
+		fsys := NewBucketFS(ctx, NewWaifuvaltApi(http.Client{}), bucket, mod.BucketFSOpts{})
+		entries, err := fsys.ReadDir(".")
+		if err != nil {
+			t.Fatalf("ReadDir failed: %v", err)
+		}
+		if len(entries) != 1 || entries[0].Name() != "08.png" {
+			t.Fatalf("Expected only 08.png to be listed, got %v", entries)
+		}
+		info, _ := entries[0].Info()
+		if info.Size() != int64(len("image")) {
+			t.Errorf("Expected the size of 08.png, got %d", info.Size())
+		}
+		fsys.ReadDir(".")
+		if count := fv.requestCount(http.MethodHead, "/f/"); count != 1 {
+			t.Errorf("Expected the size to be requested once, got %d", count)
+		}
+		if _, err = fsys.Stat("once.png"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Expected the one time download file to be hidden, got %v", err)
+		}
+	})
+
+	t.Run("should not hold the lock while listing the bucket", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		fv.addFile(bucket, "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+
+		var blocking atomic.Bool
+		blocked, release := make(chan struct{}), make(chan struct{})
+		client := NewWaifuvaltApiWithOpts(http.Client{}, mod.ClientOpts{Middleware: []mod.Middleware{
+			mod.MiddlewareFunc(func(operation mod.Operation, r *http.Request, next mod.RequestHandler) (*http.Response, error) {
+				if operation == mod.OperationGetBucket && blocking.Load() {
+					close(blocked)
+					<-release
+				}
+				return next(r)
+			}),
+		}})
+		fsys := NewBucketFS(ctx, client, bucket, mod.BucketFSOpts{})
+		dir, err := fsys.Open(".")
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+
+		blocking.Store(true)
+		fsys.Invalidate()
+		done := make(chan error, 1)
+		go func() {
+			_, err := fsys.ReadDir(".")
+			done <- err
+		}()
+		<-blocked
+		if entries, err := dir.(fs.ReadDirFile).ReadDir(-1); err != nil || len(entries) != 1 {
+			t.Errorf("Expected the open directory to be read during the listing, got %d entries and %v", len(entries), err)
+		}
+		close(release)
+		if err = <-done; err != nil {
+			t.Fatalf("ReadDir failed: %v", err)
+		}
+	})
+
+	t.Run("should serve files over http", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		fv.addFile(bucket, "page.html", []byte("<p>hello</p>"), mod.WaifuResponseOptions{}, "")
+
+		fsys := NewBucketFS(ctx, NewWaifuvaltApi(http.Client{}), bucket, mod.BucketFSOpts{})
+		server := httptest.NewServer(http.FileServerFS(fsys))
+		defer server.Close()
+		resp, err := http.Get(server.URL + "/page.html")
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+
+		if resp.StatusCode != http.StatusOK || string(body) != "<p>hello</p>" {
+			t.Errorf("Unexpected response %d: %s", resp.StatusCode, body)
+		}
+		if count := fv.requestCount(http.MethodGet, "/f/"); count != 1 {
+			t.Errorf("Expected the file to be downloaded once, got %d", count)
+		}
+	})
+
+	t.Run("should cache the listing", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		fv.addFile(bucket, "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+
+		fsys := NewBucketFS(ctx, NewWaifuvaltApi(http.Client{}), bucket, mod.BucketFSOpts{TTL: time.Hour})
+		fsys.ReadDir(".")
+		fv.addFile(bucket, "09.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		entries, _ := fsys.ReadDir(".")
+
+		if len(entries) != 1 || fv.requestCount(http.MethodPost, "/rest/bucket/get") != 1 {
+			t.Errorf("Expected the listing to be cached, got %d entries", len(entries))
+		}
+		fsys.Invalidate()
+		if entries, _ = fsys.ReadDir("."); len(entries) != 2 {
+			t.Errorf("Expected the listing to be fetched again, got %d entries", len(entries))
+		}
+	})
+
+	t.Run("should return not exist errors", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+
+		fsys := NewBucketFS(ctx, NewWaifuvaltApi(http.Client{}), bucket, mod.BucketFSOpts{})
+		_, err := fsys.Open("missing.txt")
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Expected not exist error, got %v", err)
+		}
+		_, err = fsys.Open("../escape")
+		if !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("Expected invalid error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,180 @@
+package waifuVault
+
+import (
+	"context"
+	"iter"
+	"path"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+// BucketFiles returns an iterator over the files of a bucket that pass every filter. The bucket is fetched when the
+// loop starts, if that fails the error is yielded once and the loop ends
+func BucketFiles(ctx context.Context, client mod.Waifuvalt, token string, filters ...mod.FileFilter) iter.Seq2[mod.WaifuResponse[int], error] {
+	return func(yield func(mod.WaifuResponse[int], error) bool) {
+		bucket, err := client.GetBucket(ctx, token)
+		if err != nil {
+			yield(mod.WaifuResponse[int]{}, err)
+			return
+		}
+		for file := range FilterFiles(bucket.Files, filters...) {
+			if !yield(file, nil) {
+				return
+			}
+		}
+	}
+}
+
+// AlbumFiles returns an iterator over the files of an album that pass every filter. The album is fetched when the
+// loop starts, if that fails the error is yielded once and the loop ends
+func AlbumFiles(ctx context.Context, client mod.Waifuvalt, albumToken string, filters ...mod.FileFilter) iter.Seq2[mod.WaifuResponse[int], error] {
+	return func(yield func(mod.WaifuResponse[int], error) bool) {
+		album, err := client.GetAlbum(ctx, albumToken)
+		if err != nil {
+			yield(mod.WaifuResponse[int]{}, err)
+			return
+		}
+		for file := range FilterFiles(album.Files, filters...) {
+			if !yield(file, nil) {
+				return
+			}
+		}
+	}
+}
+
+// BucketAlbums returns an iterator over the albums of a bucket that pass every filter. The bucket is fetched when the
+// loop starts, if that fails the error is yielded once and the loop ends
+func BucketAlbums(ctx context.Context, client mod.Waifuvalt, token string, filters ...mod.AlbumFilter) iter.Seq2[mod.AlbumStub, error] {
+	return func(yield func(mod.AlbumStub, error) bool) {
+		bucket, err := client.GetBucket(ctx, token)
+		if err != nil {
+			yield(mod.AlbumStub{}, err)
+			return
+		}
+		for _, album := range bucket.Albums {
+			if keepAlbum(album, filters) && !yield(album, nil) {
+				return
+			}
+		}
+	}
+}
+
+// FilterFiles returns an iterator over the files that pass every filter
+func FilterFiles(files []mod.WaifuResponse[int], filters ...mod.FileFilter) iter.Seq[mod.WaifuResponse[int]] {
+	keep := FilterAll(filters...)
+	return func(yield func(mod.WaifuResponse[int]) bool) {
+		for _, file := range files {
+			if keep(file) && !yield(file) {
+				return
+			}
+		}
+	}
+}
+
+// FilterProtected keeps files that are protected with a password
+func FilterProtected() mod.FileFilter {
+	return func(file mod.WaifuResponse[int]) bool {
+		return file.Options.Protected
+	}
+}
+
+// FilterOneTimeDownload keeps files that are deleted once they are downloaded
+func FilterOneTimeDownload() mod.FileFilter {
+	return func(file mod.WaifuResponse[int]) bool {
+		return file.Options.OneTimeDownload
+	}
+}
+
+// FilterExpiringBefore keeps files that expire before t
+func FilterExpiringBefore(t time.Time) mod.FileFilter {
+	return func(file mod.WaifuResponse[int]) bool {
+		return time.Now().Add(retentionDuration(file.RetentionPeriod)).Before(t)
+	}
+}
+
+// FilterFilenameGlob keeps files whose filename matches pattern, using the syntax of path.Match.
+// Files with a hidden filename are matched by the path of their URL. An invalid pattern keeps nothing
+func FilterFilenameGlob(pattern string) mod.FileFilter {
+	return func(file mod.WaifuResponse[int]) bool {
+		matched, err := path.Match(pattern, uploadFilename(file))
+		return err == nil && matched
+	}
+}
+
+// FilterMinViews keeps files that were downloaded at least views times
+func FilterMinViews(views int) mod.FileFilter {
+	return func(file mod.WaifuResponse[int]) bool {
+		return file.Views >= views
+	}
+}
+
+// FilterMaxViews keeps files that were downloaded at most views times
+func FilterMaxViews(views int) mod.FileFilter {
+	return func(file mod.WaifuResponse[int]) bool {
+		return file.Views <= views
+	}
+}
+
+// FilterOlderThan keeps files that were uploaded more than age ago, files whose URL has no upload time are not kept
+func FilterOlderThan(age time.Duration) mod.FileFilter {
+	return func(file mod.WaifuResponse[int]) bool {
+		parsed, err := ParseFileURL(file.URL)
+		return err == nil && time.Since(parsed.Epoch) > age
+	}
+}
+
+// FilterAll keeps files that pass every filter
+func FilterAll(filters ...mod.FileFilter) mod.FileFilter {
+	return func(file mod.WaifuResponse[int]) bool {
+		for _, filter := range filters {
+			if !filter(file) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// FilterAny keeps files that pass at least one filter
+func FilterAny(filters ...mod.FileFilter) mod.FileFilter {
+	return func(file mod.WaifuResponse[int]) bool {
+		for _, filter := range filters {
+			if filter(file) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// FilterNot keeps files that do not pass filter
+func FilterNot(filter mod.FileFilter) mod.FileFilter {
+	return func(file mod.WaifuResponse[int]) bool {
+		return !filter(file)
+	}
+}
+
+// FilterAlbumNameGlob keeps albums whose name matches pattern, using the syntax of path.Match
+func FilterAlbumNameGlob(pattern string) mod.AlbumFilter {
+	return func(album mod.AlbumStub) bool {
+		matched, err := path.Match(pattern, album.Name)
+		return err == nil && matched
+	}
+}
+
+// FilterAlbumShared keeps albums that are shared with a public token
+func FilterAlbumShared() mod.AlbumFilter {
+	return func(album mod.AlbumStub) bool {
+		return album.PublicToken != nil
+	}
+}
+
+func keepAlbum(album mod.AlbumStub, filters []mod.AlbumFilter) bool {
+	for _, filter := range filters {
+		if !filter(album) {
+			return false
+		}
+	}
+	return true
+}
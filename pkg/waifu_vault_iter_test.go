@@ -0,0 +1,118 @@
+package waifuVault
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+func TestIterators(t *testing.T) {
+	ctx := context.Background()
+	api := NewWaifuvaltApi(http.Client{})
+
+	filenames := func(t *testing.T, files iter.Seq2[mod.WaifuResponse[int], error]) []string {
+		var names []string
+		for file, err := range files {
+			if err != nil {
+				t.Fatalf("Iterating failed: %v", err)
+			}
+			names = append(names, uploadFilename(file))
+		}
+		return names
+	}
+
+	fv := newFakeVault(t)
+	bucket := fv.addBucket()
+	fv.addFile(bucket, "notes.txt", []byte("notes"), mod.WaifuResponseOptions{}, "")
+	secret := fv.addFile(bucket, "secret.png", []byte("secret"), mod.WaifuResponseOptions{Protected: true}, "hunter2")
+	once := fv.addFile(bucket, "once.png", []byte("once"), mod.WaifuResponseOptions{OneTimeDownload: true}, "")
+	popular := fv.addFile(bucket, "popular.png", []byte("popular"), mod.WaifuResponseOptions{}, "")
+	fv.file(popular).response.Views = 10
+	fv.file(secret).response.RetentionPeriod = int(time.Hour / time.Millisecond)
+	album := fv.addAlbum(bucket, "holiday", once, popular)
+	fv.addAlbum(bucket, "work")
+	if _, err := api.ShareAlbum(ctx, album); err != nil {
+		t.Fatalf("ShareAlbum failed: %v", err)
+	}
+
+	t.Run("should filter bucket files", func(t *testing.T) {
+		tests := []struct {
+			filters  []mod.FileFilter
+			expected []string
+		}{
+			{nil, []string{"notes.txt", "secret.png", "once.png", "popular.png"}},
+			{[]mod.FileFilter{FilterProtected()}, []string{"secret.png"}},
+			{[]mod.FileFilter{FilterOneTimeDownload()}, []string{"once.png"}},
+			{[]mod.FileFilter{FilterExpiringBefore(time.Now().Add(24 * time.Hour))}, []string{"secret.png"}},
+			{[]mod.FileFilter{FilterFilenameGlob("*.png"), FilterNot(FilterProtected())}, []string{"once.png", "popular.png"}},
+			{[]mod.FileFilter{FilterAny(FilterMinViews(5), FilterFilenameGlob("*.txt"))}, []string{"notes.txt", "popular.png"}},
+			{[]mod.FileFilter{FilterFilenameGlob("[")}, nil},
+		}
+		for _, test := range tests {
+			if names := filenames(t, BucketFiles(ctx, api, bucket, test.filters...)); !slices.Equal(names, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, names)
+			}
+		}
+	})
+
+	t.Run("should filter album files and albums", func(t *testing.T) {
+		if names := filenames(t, AlbumFiles(ctx, api, album, FilterMinViews(1))); !slices.Equal(names, []string{"popular.png"}) {
+			t.Errorf("Expected popular.png, got %v", names)
+		}
+
+		var names []string
+		for stub, err := range BucketAlbums(ctx, api, bucket, FilterAlbumShared()) {
+			if err != nil {
+				t.Fatalf("BucketAlbums failed: %v", err)
+			}
+			names = append(names, stub.Name)
+		}
+		if !slices.Equal(names, []string{"holiday"}) {
+			t.Errorf("Expected the shared album, got %v", names)
+		}
+	})
+
+	t.Run("should stop when the loop breaks", func(t *testing.T) {
+		count := 0
+		for range BucketFiles(ctx, api, bucket) {
+			count++
+			break
+		}
+		if count != 1 {
+			t.Errorf("Expected 1 file, got %d", count)
+		}
+	})
+
+	t.Run("should yield the error of the request", func(t *testing.T) {
+		var errs []error
+		for file, err := range BucketFiles(ctx, api, "missing") {
+			if file.Token != "" {
+				t.Errorf("Expected no file, got %s", file.Token)
+			}
+			errs = append(errs, err)
+		}
+		if len(errs) != 1 || errs[0] == nil {
+			t.Errorf("Expected a single error, got %v", errs)
+		}
+	})
+
+	t.Run("should only fetch when iterating", func(t *testing.T) {
+		before := fv.requestCount(http.MethodPost, "/rest/bucket/get")
+		files := BucketFiles(ctx, api, bucket)
+		if count := fv.requestCount(http.MethodPost, "/rest/bucket/get"); count != before {
+			t.Errorf("Expected no request before the loop, got %d", count-before)
+		}
+		for range files {
+		}
+		for range files {
+		}
+		if count := fv.requestCount(http.MethodPost, "/rest/bucket/get"); count != before+2 {
+			t.Errorf("Expected a request per loop, got %d", count-before)
+		}
+	})
+}
@@ -0,0 +1,198 @@
+package waifuVault
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+const redacted = "REDACTED"
+
+// restPathWords are the parts of REST paths that are not tokens
+var restPathWords = []string{"rest", "bucket", "album", "get", "create", "associate", "disassociate", "share", "revoke", "download"}
+
+// redactedHeaders are the request headers that are never logged
+var redactedHeaders = []string{"X-Password", "Authorization", "Cookie", "Proxy-Authorization"}
+
+// redactedFields are the JSON request body fields that are never logged
+var redactedFields = []string{"password", "previousPassword", "bucket_token", "bucketToken", "fileTokens", "token"}
+
+// LoggingMiddleware logs every request with its operation, method, path, status, duration and the bytes sent and received.
+// Requests are logged once their response body is closed. Tokens in the path and in errors are always redacted
+func LoggingMiddleware(logger *slog.Logger, opts mod.LoggingOpts) mod.Middleware {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if opts.Level == nil {
+		opts.Level = slog.LevelDebug
+	}
+	if opts.ErrorLevel == nil {
+		opts.ErrorLevel = slog.LevelWarn
+	}
+	return mod.MiddlewareFunc(func(operation mod.Operation, r *http.Request, next mod.RequestHandler) (*http.Response, error) {
+		start := time.Now()
+		attrs := []slog.Attr{
+			slog.String("operation", string(operation)),
+			slog.String("method", r.Method),
+			slog.String("path", redactPath(r.URL)),
+		}
+		if opts.Headers {
+			attrs = append(attrs, slog.Any("headers", redactHeaders(r.Header)))
+		}
+		if opts.Bodies {
+			if body, ok := redactBody(r); ok {
+				attrs = append(attrs, slog.String("body", body))
+			}
+		}
+		sent := &countingReadCloser{}
+		if r.Body != nil && r.Body != http.NoBody {
+			sent.ReadCloser = r.Body
+			r.Body = sent
+		}
+
+		resp, err := next(r)
+		if err != nil {
+			attrs = append(attrs, slog.Duration("duration", time.Since(start)), slog.Int64("bytes_sent", sent.count()), slog.String("error", redactError(err, r.URL)))
+			logger.LogAttrs(r.Context(), opts.ErrorLevel.Level(), "request failed", attrs...)
+			return nil, err
+		}
+		level := opts.Level
+		if resp.StatusCode >= http.StatusBadRequest {
+			level = opts.ErrorLevel
+		}
+		received := &countingReadCloser{ReadCloser: resp.Body}
+		received.onClose = func() {
+			attrs = append(attrs,
+				slog.Int("status", resp.StatusCode),
+				slog.Duration("duration", time.Since(start)),
+				slog.Int64("bytes_sent", sent.count()),
+				slog.Int64("bytes_received", received.count()),
+			)
+			logger.LogAttrs(r.Context(), level.Level(), "request", attrs...)
+		}
+		resp.Body = received
+		return resp, nil
+	})
+}
+
+// redactPath returns the path of a request with every token after the rest segment replaced,
+// the segment does not have to come first so instances hosted under a path prefix are redacted too
+func redactPath(u *url.URL) string {
+	parts := strings.Split(u.Path, "/")
+	rest := slices.Index(parts, "rest")
+	if rest < 0 {
+		return u.Path
+	}
+	for i, part := range parts[rest+1:] {
+		if part != "" && !slices.Contains(restPathWords, part) {
+			parts[rest+1+i] = redacted
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// redactError returns the text of the error of a failed request, the *url.Error returned by the transport contains
+// the full URL with its tokens and query, so it is replaced by the redacted path
+func redactError(err error, u *url.URL) string {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err.Error()
+	}
+	return strings.ReplaceAll(err.Error(), urlErr.URL, redactPath(u))
+}
+
+func redactHeaders(header http.Header) http.Header {
+	result := header.Clone()
+	for _, name := range redactedHeaders {
+		if result.Get(name) != "" {
+			result.Set(name, redacted)
+		}
+	}
+	return result
+}
+
+// redactBody returns a JSON request body with passwords and tokens replaced, bodies that can not be replayed are not read
+func redactBody(r *http.Request) (string, bool) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if r.GetBody == nil || mediaType != "application/json" {
+		return "", false
+	}
+	body, err := r.GetBody()
+	if err != nil {
+		return "", false
+	}
+	defer body.Close()
+	var value any
+	if err = json.NewDecoder(body).Decode(&value); err != nil {
+		return "", false
+	}
+	redacted, err := json.Marshal(redactValue(value))
+	if err != nil {
+		return "", false
+	}
+	return string(redacted), true
+}
+
+func redactValue(value any) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		for key, field := range typed {
+			if slices.ContainsFunc(redactedFields, func(name string) bool { return strings.EqualFold(name, key) }) {
+				typed[key] = redacted
+			} else {
+				typed[key] = redactValue(field)
+			}
+		}
+	case []any:
+		for i, item := range typed {
+			typed[i] = redactValue(item)
+		}
+	}
+	return value
+}
+
+// countingReadCloser counts the bytes read and calls onClose the first time it is closed
+type countingReadCloser struct {
+	io.ReadCloser
+	onClose func()
+
+	mu     sync.Mutex
+	read   int64
+	closed bool
+}
+
+func (re *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := re.ReadCloser.Read(p)
+	re.mu.Lock()
+	re.read += int64(n)
+	re.mu.Unlock()
+	return n, err
+}
+
+func (re *countingReadCloser) Close() error {
+	err := re.ReadCloser.Close()
+	re.mu.Lock()
+	first := !re.closed
+	re.closed = true
+	re.mu.Unlock()
+	if first && re.onClose != nil {
+		re.onClose()
+	}
+	return err
+}
+
+func (re *countingReadCloser) count() int64 {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	return re.read
+}
@@ -0,0 +1,120 @@
+package waifuVault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+func TestLoggingMiddleware(t *testing.T) {
+	ctx := context.Background()
+
+	newLoggingApi := func(opts mod.LoggingOpts) (mod.Waifuvalt, *bytes.Buffer) {
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		return NewWaifuvaltApiWithOpts(http.Client{}, mod.ClientOpts{Middleware: []mod.Middleware{LoggingMiddleware(logger, opts)}}), &buf
+	}
+	readEntries := func(t *testing.T, buf *bytes.Buffer) []map[string]any {
+		var entries []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			entry := map[string]any{}
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			entries = append(entries, entry)
+		}
+		return entries
+	}
+
+	t.Run("should log requests", func(t *testing.T) {
+		fv := newFakeVault(t)
+		token := fv.addFile("", "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+
+		api, buf := newLoggingApi(mod.LoggingOpts{})
+		if _, err := api.GetFile(ctx, mod.GetFileInfo{Token: token}); err != nil {
+			t.Fatalf("GetFile failed: %v", err)
+		}
+
+		entries := readEntries(t, buf)
+		if len(entries) != 2 {
+			t.Fatalf("Expected 2 entries, got %d", len(entries))
+		}
+		download := entries[1]
+		if download["operation"] != string(mod.OperationGetFile) || download["level"] != "DEBUG" || download["status"] != float64(http.StatusOK) {
+			t.Errorf("Expected a debug GetFile entry with status 200, got %v", download)
+		}
+		if download["bytes_received"] != float64(len("image")) {
+			t.Errorf("Expected 5 bytes received, got %v", download["bytes_received"])
+		}
+		if strings.Contains(buf.String(), token) {
+			t.Errorf("Expected the token to be redacted, got %s", buf.String())
+		}
+	})
+
+	t.Run("should log errors at the error level", func(t *testing.T) {
+		newFakeVault(t)
+
+		api, buf := newLoggingApi(mod.LoggingOpts{})
+		if _, err := api.FileInfo(ctx, "missing"); err == nil {
+			t.Fatalf("Expected FileInfo to fail")
+		}
+
+		entries := readEntries(t, buf)
+		if entries[0]["level"] != "WARN" || entries[0]["status"] != float64(http.StatusBadRequest) {
+			t.Errorf("Expected a warning with status 400, got %v", entries[0])
+		}
+	})
+
+	t.Run("should redact tokens from failed requests", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		api := NewWaifuvaltApiWithOpts(http.Client{}, mod.ClientOpts{
+			BaseURL:    unreachableInstance + "/vault",
+			Middleware: []mod.Middleware{LoggingMiddleware(logger, mod.LoggingOpts{})},
+		})
+
+		if _, err := api.FileInfo(ctx, "SECRET-FILE-TOKEN"); err == nil {
+			t.Fatalf("Expected FileInfo to fail")
+		}
+		if _, err := api.DeleteBucket(ctx, "SECRET-BUCKET-TOKEN"); err == nil {
+			t.Fatalf("Expected DeleteBucket to fail")
+		}
+
+		entries := readEntries(t, &buf)
+		if len(entries) != 2 || entries[0]["error"] == nil || entries[0]["path"] != "/vault/rest/REDACTED" {
+			t.Errorf("Expected two failed requests with redacted paths, got %v", entries)
+		}
+		for _, secret := range []string{"SECRET-FILE-TOKEN", "SECRET-BUCKET-TOKEN"} {
+			if strings.Contains(buf.String(), secret) {
+				t.Errorf("Expected %q to be redacted, got %s", secret, buf.String())
+			}
+		}
+	})
+
+	t.Run("should redact secrets", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		token := fv.addFile(bucket, "08.png", []byte("image"), mod.WaifuResponseOptions{Protected: true}, "hunter2")
+
+		api, buf := newLoggingApi(mod.LoggingOpts{Headers: true, Bodies: true})
+		api.GetBucket(ctx, bucket)
+		api.GetFile(ctx, mod.GetFileInfo{Token: token, Password: "hunter2"})
+		password, previous := "swordfish", "hunter2"
+		api.ModifyFile(ctx, token, mod.ModifyEntryPayload{Password: &password, PreviousPassword: &previous})
+
+		for _, secret := range []string{bucket, token, "hunter2", "swordfish"} {
+			if strings.Contains(buf.String(), secret) {
+				t.Errorf("Expected %q to be redacted, got %s", secret, buf.String())
+			}
+		}
+		if !strings.Contains(buf.String(), `"path":"/rest/bucket/get"`) || !strings.Contains(buf.String(), "customExpiry") {
+			t.Errorf("Expected paths and bodies to be logged, got %s", buf.String())
+		}
+	})
+}
@@ -0,0 +1,210 @@
+package waifuVault
+
+import (
+	"bufio"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+const defaultMetricsNamespace = "waifuvault"
+
+var defaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+// MetricsMiddleware reports every request to collector once its response body is closed.
+// Put it before RetryMiddleware to measure calls, or after it to measure every attempt
+func MetricsMiddleware(collector mod.MetricsCollector) mod.Middleware {
+	return mod.MiddlewareFunc(func(operation mod.Operation, r *http.Request, next mod.RequestHandler) (*http.Response, error) {
+		start := time.Now()
+		sent := &countingReadCloser{}
+		if r.Body != nil && r.Body != http.NoBody {
+			sent.ReadCloser = r.Body
+			r.Body = sent
+		}
+		resp, err := next(r)
+		if err != nil {
+			collector.ObserveRequest(operation, 0, time.Since(start))
+			collector.ObserveBytes(operation, sent.count(), 0)
+			return nil, err
+		}
+		received := &countingReadCloser{ReadCloser: resp.Body}
+		received.onClose = func() {
+			collector.ObserveRequest(operation, resp.StatusCode, time.Since(start))
+			collector.ObserveBytes(operation, sent.count(), received.count())
+		}
+		resp.Body = received
+		return resp, nil
+	})
+}
+
+// PrometheusMetrics is a MetricsCollector that serves its metrics in the Prometheus text format
+type PrometheusMetrics struct {
+	namespace string
+	buckets   []float64
+
+	mu        sync.Mutex
+	requests  map[requestKey]float64
+	latencies map[mod.Operation]*histogram
+	sent      map[mod.Operation]float64
+	received  map[mod.Operation]float64
+	retries   map[mod.Operation]float64
+}
+
+type requestKey struct {
+	operation   mod.Operation
+	statusClass string
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewPrometheusMetrics creates a collector, use it with MetricsMiddleware and serve it on a /metrics endpoint
+func NewPrometheusMetrics(opts mod.PrometheusOpts) *PrometheusMetrics {
+	if opts.Namespace == "" {
+		opts.Namespace = defaultMetricsNamespace
+	}
+	if len(opts.Buckets) == 0 {
+		opts.Buckets = defaultLatencyBuckets
+	}
+	buckets := slices.Clone(opts.Buckets)
+	slices.Sort(buckets)
+	return &PrometheusMetrics{
+		namespace: opts.Namespace,
+		buckets:   slices.Compact(buckets),
+		requests:  map[requestKey]float64{},
+		latencies: map[mod.Operation]*histogram{},
+		sent:      map[mod.Operation]float64{},
+		received:  map[mod.Operation]float64{},
+		retries:   map[mod.Operation]float64{},
+	}
+}
+
+func (re *PrometheusMetrics) ObserveRequest(operation mod.Operation, status int, duration time.Duration) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	re.requests[requestKey{operation, statusClass(status)}]++
+	h, ok := re.latencies[operation]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(re.buckets))}
+		re.latencies[operation] = h
+	}
+	seconds := duration.Seconds()
+	for i, bound := range re.buckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+func (re *PrometheusMetrics) ObserveBytes(operation mod.Operation, sent, received int64) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	re.sent[operation] += float64(sent)
+	re.received[operation] += float64(received)
+}
+
+func (re *PrometheusMetrics) ObserveRetry(operation mod.Operation, attempt int) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	re.retries[operation]++
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format
+func (re *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	out := bufio.NewWriter(w)
+	defer out.Flush()
+
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	name := re.namespace + "_requests_total"
+	writeMetricHeader(out, name, "counter", "Requests made by the client by operation and status class.")
+	for _, key := range slices.SortedFunc(maps.Keys(re.requests), compareRequestKeys) {
+		writeSample(out, name, re.requests[key], "operation", string(key.operation), "status_class", key.statusClass)
+	}
+
+	name = re.namespace + "_request_duration_seconds"
+	writeMetricHeader(out, name, "histogram", "Request latency by operation.")
+	for _, operation := range slices.Sorted(maps.Keys(re.latencies)) {
+		h := re.latencies[operation]
+		for i, bound := range re.buckets {
+			writeSample(out, name+"_bucket", float64(h.counts[i]), "operation", string(operation), "le", formatFloat(bound))
+		}
+		writeSample(out, name+"_bucket", float64(h.count), "operation", string(operation), "le", "+Inf")
+		writeSample(out, name+"_sum", h.sum, "operation", string(operation))
+		writeSample(out, name+"_count", float64(h.count), "operation", string(operation))
+	}
+
+	re.writeCounter(out, "_bytes_sent_total", "Bytes uploaded by operation.", re.sent)
+	re.writeCounter(out, "_bytes_received_total", "Bytes downloaded by operation.", re.received)
+	re.writeCounter(out, "_retries_total", "Requests sent again by operation.", re.retries)
+}
+
+func (re *PrometheusMetrics) writeCounter(out *bufio.Writer, suffix, help string, values map[mod.Operation]float64) {
+	name := re.namespace + suffix
+	writeMetricHeader(out, name, "counter", help)
+	for _, operation := range slices.Sorted(maps.Keys(values)) {
+		writeSample(out, name, values[operation], "operation", string(operation))
+	}
+}
+
+func writeMetricHeader(out *bufio.Writer, name, kind, help string) {
+	fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// writeSample writes one sample, labels are pairs of names and values
+func writeSample(out *bufio.Writer, name string, value float64, labels ...string) {
+	out.WriteString(name)
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i == 0 {
+			out.WriteByte('{')
+		} else {
+			out.WriteByte(',')
+		}
+		fmt.Fprintf(out, `%s="%s"`, labels[i], escapeLabelValue(labels[i+1]))
+	}
+	if len(labels) > 0 {
+		out.WriteByte('}')
+	}
+	out.WriteByte(' ')
+	out.WriteString(formatFloat(value))
+	out.WriteByte('\n')
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// statusClass groups a status code as 2xx, 4xx and so on, failed requests without a response are "error"
+func statusClass(status int) string {
+	if status <= 0 {
+		return "error"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
+
+func compareRequestKeys(a, b requestKey) int {
+	if c := strings.Compare(string(a.operation), string(b.operation)); c != 0 {
+		return c
+	}
+	return strings.Compare(a.statusClass, b.statusClass)
+}
@@ -0,0 +1,82 @@
+package waifuVault
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+
+	scrape := func(metrics *PrometheusMetrics) string {
+		rec := httptest.NewRecorder()
+		metrics.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		return rec.Body.String()
+	}
+
+	t.Run("should count requests and bytes", func(t *testing.T) {
+		fv := newFakeVault(t)
+		token := fv.addFile("", "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+
+		metrics := NewPrometheusMetrics(mod.PrometheusOpts{})
+		api := NewWaifuvaltApiWithOpts(http.Client{}, mod.ClientOpts{Middleware: []mod.Middleware{MetricsMiddleware(metrics)}})
+		if _, err := api.GetFile(ctx, mod.GetFileInfo{Token: token}); err != nil {
+			t.Fatalf("GetFile failed: %v", err)
+		}
+		api.FileInfo(ctx, "missing")
+
+		body := scrape(metrics)
+		for _, line := range []string{
+			"# TYPE waifuvault_requests_total counter",
+			`waifuvault_requests_total{operation="FileInfo",status_class="2xx"} 1`,
+			`waifuvault_requests_total{operation="FileInfo",status_class="4xx"} 1`,
+			`waifuvault_requests_total{operation="GetFile",status_class="2xx"} 1`,
+			`waifuvault_request_duration_seconds_bucket{operation="FileInfo",le="+Inf"} 2`,
+			`waifuvault_request_duration_seconds_count{operation="GetFile"} 1`,
+			`waifuvault_bytes_received_total{operation="GetFile"} 5`,
+		} {
+			if !strings.Contains(body, line+"\n") {
+				t.Errorf("Expected %q in\n%s", line, body)
+			}
+		}
+	})
+
+	t.Run("should count retries", func(t *testing.T) {
+		statuses := []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK}
+		attempt := 0
+		next := func(r *http.Request) (*http.Response, error) {
+			attempt++
+			return statusResponse(statuses[attempt-1]), nil
+		}
+		metrics := NewPrometheusMetrics(mod.PrometheusOpts{Namespace: "uploader"})
+		retry := RetryMiddleware(mod.RetryOpts{Backoff: time.Millisecond, OnRetry: metrics.ObserveRetry})
+
+		req, _ := http.NewRequest(http.MethodGet, "https://waifuvault.moe/rest/token", nil)
+		retry.Handle(mod.OperationFileInfo, req, next)
+
+		if body := scrape(metrics); !strings.Contains(body, `uploader_retries_total{operation="FileInfo"} 2`) {
+			t.Errorf("Expected 2 retries, got\n%s", body)
+		}
+	})
+
+	t.Run("should count failed requests", func(t *testing.T) {
+		metrics := NewPrometheusMetrics(mod.PrometheusOpts{Buckets: []float64{1}})
+		next := func(r *http.Request) (*http.Response, error) {
+			return nil, context.DeadlineExceeded
+		}
+		req, _ := http.NewRequest(http.MethodGet, "https://waifuvault.moe/rest/token", nil)
+		MetricsMiddleware(metrics).Handle(mod.OperationFileInfo, req, next)
+
+		body := scrape(metrics)
+		if !strings.Contains(body, `waifuvault_requests_total{operation="FileInfo",status_class="error"} 1`) ||
+			!strings.Contains(body, `waifuvault_request_duration_seconds_bucket{operation="FileInfo",le="1"} 1`) {
+			t.Errorf("Expected the failed request to be counted, got\n%s", body)
+		}
+	})
+}
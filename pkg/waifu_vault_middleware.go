@@ -0,0 +1,143 @@
+package waifuVault
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+const (
+	defaultRetryAttempts = 3
+	defaultRetryBackoff  = 500 * time.Millisecond
+)
+
+// readOperations are the operations that do not change anything, so they are safe to send again
+var readOperations = []mod.Operation{
+	mod.OperationFileInfo,
+	mod.OperationFileInfoFormatted,
+	mod.OperationGetFile,
+	mod.OperationGetFileStream,
+	mod.OperationGetBucket,
+	mod.OperationGetAlbum,
+	mod.OperationDownloadAlbum,
+	mod.OperationDownloadAlbumStream,
+	mod.OperationHealthCheck,
+	mod.OperationFileSize,
+}
+
+// noRetryKey marks the context of a request that must not be sent twice
+type noRetryKey struct{}
+
+// withoutRetry marks ctx so RetryMiddleware never sends its requests again
+func withoutRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetryKey{}, true)
+}
+
+// HeaderMiddleware sets headers on every request, for example an Authorization header for an instance behind a proxy
+func HeaderMiddleware(header http.Header) mod.Middleware {
+	return mod.MiddlewareFunc(func(operation mod.Operation, r *http.Request, next mod.RequestHandler) (*http.Response, error) {
+		for key, values := range header {
+			r.Header[http.CanonicalHeaderKey(key)] = slices.Clone(values)
+		}
+		return next(r)
+	})
+}
+
+// RequestIDMiddleware sets a random request ID in header on every request that does not already have one.
+// header defaults to X-Request-ID
+func RequestIDMiddleware(header string) mod.Middleware {
+	if header == "" {
+		header = "X-Request-ID"
+	}
+	return mod.MiddlewareFunc(func(operation mod.Operation, r *http.Request, next mod.RequestHandler) (*http.Response, error) {
+		if r.Header.Get(header) == "" {
+			r.Header.Set(header, randomHex(16))
+		}
+		return next(r)
+	})
+}
+
+// RetryMiddleware sends failed requests again with an exponential backoff.
+// Requests with a body that can not be replayed, such as file and reader uploads, are never retried.
+// Downloads of one time download files are never retried either, as a failed attempt may already have deleted the file
+func RetryMiddleware(opts mod.RetryOpts) mod.Middleware {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultRetryAttempts
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = defaultRetryBackoff
+	}
+	if opts.Retryable == nil {
+		opts.Retryable = defaultRetryable
+	}
+	return mod.MiddlewareFunc(func(operation mod.Operation, r *http.Request, next mod.RequestHandler) (*http.Response, error) {
+		if r.Context().Value(noRetryKey{}) != nil {
+			return next(r)
+		}
+		delay := opts.Backoff
+		for attempt := 1; ; attempt++ {
+			resp, err := next(r)
+			if attempt == opts.MaxAttempts || !opts.Retryable(operation, resp, err) || (r.Body != nil && r.GetBody == nil) {
+				return resp, err
+			}
+			if resp != nil {
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+			if err = sleepContext(r.Context(), delay); err != nil {
+				return nil, err
+			}
+			delay *= 2
+			if r, err = replayRequest(r); err != nil {
+				return nil, err
+			}
+			if opts.OnRetry != nil {
+				opts.OnRetry(operation, attempt+1)
+			}
+		}
+	})
+}
+
+func defaultRetryable(operation mod.Operation, resp *http.Response, err error) bool {
+	if !slices.Contains(readOperations, operation) {
+		return false
+	}
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// replayRequest clones a request with a fresh body so it can be sent again
+func replayRequest(r *http.Request) (*http.Request, error) {
+	clone := r.Clone(r.Context())
+	if r.GetBody != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		clone.Body = body
+	}
+	return clone, nil
+}
+
+// sleepContext waits for d, returning early with the error of ctx if it is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
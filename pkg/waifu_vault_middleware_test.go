@@ -0,0 +1,193 @@
+package waifuVault
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+// recordingMiddleware appends its name to calls before and after the request
+func recordingMiddleware(name string, calls *[]string) mod.Middleware {
+	return mod.MiddlewareFunc(func(operation mod.Operation, r *http.Request, next mod.RequestHandler) (*http.Response, error) {
+		*calls = append(*calls, name+" "+string(operation))
+		resp, err := next(r)
+		*calls = append(*calls, name+" done")
+		return resp, err
+	})
+}
+
+func statusResponse(status int) *http.Response {
+	return &http.Response{StatusCode: status, Body: io.NopCloser(bytes.NewReader(nil))}
+}
+
+// checkGoroutines fails the test if more goroutines than before are still running after a short wait
+func checkGoroutines(t *testing.T, before int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("Expected %d goroutines, got %d", before, after)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should run middleware in order", func(t *testing.T) {
+		fv := newFakeVault(t)
+		token := fv.addFile("", "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+
+		var calls []string
+		api := NewWaifuvaltApiWithOpts(http.Client{}, mod.ClientOpts{Middleware: []mod.Middleware{
+			recordingMiddleware("outer", &calls),
+			recordingMiddleware("inner", &calls),
+		}})
+		if _, err := api.FileInfo(ctx, token); err != nil {
+			t.Fatalf("FileInfo failed: %v", err)
+		}
+
+		expected := []string{"outer FileInfo", "inner FileInfo", "inner done", "outer done"}
+		if !slices.Equal(calls, expected) {
+			t.Errorf("Expected %v, got %v", expected, calls)
+		}
+	})
+
+	t.Run("should name every operation", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		token := fv.addFile(bucket, "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+
+		var operations []mod.Operation
+		api := NewWaifuvaltApiWithOpts(http.Client{}, mod.ClientOpts{Middleware: []mod.Middleware{
+			mod.MiddlewareFunc(func(operation mod.Operation, r *http.Request, next mod.RequestHandler) (*http.Response, error) {
+				operations = append(operations, operation)
+				return next(r)
+			}),
+		}})
+		api.GetBucket(ctx, bucket)
+		api.GetFile(ctx, mod.GetFileInfo{Token: token})
+		api.DeleteFile(ctx, token)
+
+		expected := []mod.Operation{mod.OperationGetBucket, mod.OperationFileInfo, mod.OperationGetFile, mod.OperationDeleteFile}
+		if !slices.Equal(operations, expected) {
+			t.Errorf("Expected %v, got %v", expected, operations)
+		}
+	})
+
+	t.Run("should set headers", func(t *testing.T) {
+		var r *http.Request
+		next := func(req *http.Request) (*http.Response, error) {
+			r = req
+			return statusResponse(http.StatusOK), nil
+		}
+		req, _ := http.NewRequest(http.MethodGet, "https://waifuvault.moe/rest/token", nil)
+		HeaderMiddleware(http.Header{"authorization": {"Bearer secret"}}).Handle(mod.OperationFileInfo, req, next)
+		RequestIDMiddleware("").Handle(mod.OperationFileInfo, req, next)
+
+		if r.Header.Get("Authorization") != "Bearer secret" || len(r.Header.Get("X-Request-ID")) != 32 {
+			t.Errorf("Expected the headers to be set, got %v", r.Header)
+		}
+	})
+
+	t.Run("should retry reads", func(t *testing.T) {
+		var bodies []string
+		statuses := []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK}
+		next := func(r *http.Request) (*http.Response, error) {
+			body := []byte{}
+			if r.Body != nil {
+				body, _ = io.ReadAll(r.Body)
+			}
+			bodies = append(bodies, string(body))
+			return statusResponse(statuses[len(bodies)-1]), nil
+		}
+		retry := RetryMiddleware(mod.RetryOpts{Backoff: time.Millisecond})
+
+		req, _ := http.NewRequest(http.MethodPost, "https://waifuvault.moe/rest/bucket/get", bytes.NewBufferString("token"))
+		resp, err := retry.Handle(mod.OperationGetBucket, req, next)
+		if err != nil || resp.StatusCode != http.StatusOK || !slices.Equal(bodies, []string{"token", "token", "token"}) {
+			t.Errorf("Expected 3 attempts with the same body, got %v", bodies)
+		}
+
+		bodies = nil
+		req, _ = http.NewRequest(http.MethodDelete, "https://waifuvault.moe/rest/token", nil)
+		resp, _ = retry.Handle(mod.OperationDeleteFile, req, next)
+		if len(bodies) != 1 || resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("Expected changes not to be retried, got %d attempts", len(bodies))
+		}
+	})
+
+	t.Run("should stop retrying when the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		next := func(r *http.Request) (*http.Response, error) {
+			cancel()
+			return nil, errors.New("connection refused")
+		}
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://waifuvault.moe/rest/token", nil)
+		_, err := RetryMiddleware(mod.RetryOpts{Backoff: time.Hour}).Handle(mod.OperationFileInfo, req, next)
+
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected the context error, got %v", err)
+		}
+	})
+	t.Run("should close the body of requests that are not sent", func(t *testing.T) {
+		newFakeVault(t)
+		rejected := errors.New("rejected")
+		api := NewWaifuvaltApiWithOpts(http.Client{}, mod.ClientOpts{Middleware: []mod.Middleware{
+			mod.MiddlewareFunc(func(operation mod.Operation, r *http.Request, next mod.RequestHandler) (*http.Response, error) {
+				return nil, rejected
+			}),
+		}})
+
+		before := runtime.NumGoroutine()
+		for range 50 {
+			_, err := api.UploadFile(ctx, mod.WaifuvaultPutOpts{Reader: bytes.NewReader([]byte("image")), FileName: "08.png"})
+			if !errors.Is(err, rejected) {
+				t.Fatalf("Expected the upload to be rejected, got %v", err)
+			}
+		}
+		checkGoroutines(t, before)
+	})
+
+	t.Run("should not retry one time downloads", func(t *testing.T) {
+		fv := newFakeVault(t)
+		oneTime := fv.addFile("", "08.png", []byte("image"), mod.WaifuResponseOptions{OneTimeDownload: true}, "")
+		normal := fv.addFile("", "09.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+
+		attempts := 0
+		api := NewWaifuvaltApiWithOpts(http.Client{}, mod.ClientOpts{Middleware: []mod.Middleware{
+			RetryMiddleware(mod.RetryOpts{Backoff: time.Millisecond}),
+			mod.MiddlewareFunc(func(operation mod.Operation, r *http.Request, next mod.RequestHandler) (*http.Response, error) {
+				if operation != mod.OperationGetFile {
+					return next(r)
+				}
+				attempts++
+				return nil, errors.New("connection reset")
+			}),
+		}})
+
+		api.GetFile(ctx, mod.GetFileInfo{Token: oneTime})
+		if attempts != 1 {
+			t.Errorf("Expected a one time download to be sent once, got %d attempts", attempts)
+		}
+		attempts = 0
+		api.GetFile(ctx, mod.GetFileInfo{Url: fv.file(normal).response.URL})
+		if attempts != 1 {
+			t.Errorf("Expected a download by URL to be sent once, got %d attempts", attempts)
+		}
+		attempts = 0
+		api.GetFile(ctx, mod.GetFileInfo{Token: normal})
+		if attempts != 3 {
+			t.Errorf("Expected a download by token to be retried, got %d attempts", attempts)
+		}
+	})
+}
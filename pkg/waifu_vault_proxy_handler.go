@@ -0,0 +1,258 @@
+package waifuVault
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+const (
+	defaultProxyTTL          = 5 * time.Minute
+	defaultProxyCacheControl = "public, max-age=3600"
+
+	// protectedCacheControl is sent with protected files, so shared caches never serve them without the password
+	protectedCacheControl = "private, no-store"
+
+	// proxyMissTTL is how long a name that was not found is remembered, so repeated requests for it do not all go upstream
+	proxyMissTTL = 10 * time.Second
+
+	// proxyBucketKey is the flight key of the bucket listing, names never contain a slash so it can not clash with them
+	proxyBucketKey = "/bucket"
+)
+
+// proxiedHeaders are the upstream response headers sent to the client, everything else is dropped so the origin is not exposed
+var proxiedHeaders = []string{
+	"Accept-Ranges",
+	"Content-Disposition",
+	"Content-Encoding",
+	"Content-Length",
+	"Content-Range",
+	"Content-Type",
+	"ETag",
+	"Last-Modified",
+}
+
+type proxyHandler struct {
+	client mod.Waifuvalt
+	opts   mod.ProxyHandlerOpts
+	proxy  *httputil.ReverseProxy
+	tokens map[string]bool
+
+	mu            sync.Mutex
+	files         map[string]proxyEntry
+	bucketFetched time.Time
+	missesSwept   time.Time
+	flight        flightGroup
+}
+
+// proxyEntry is a cached file lookup, file is nil if the name was not found
+type proxyEntry struct {
+	file    *mod.WaifuResponse[int]
+	fetched time.Time
+}
+
+// proxyFileKey is the context key of the file being proxied
+type proxyFileKey struct{}
+
+// NewProxyHandler creates an http.Handler that serves files from WaifuVault, the request path is a file token
+// or filename in opts.BucketToken, or a token in opts.Tokens. Any other token is only served with opts.AllowAnyToken,
+// otherwise it is not found without asking upstream. Mount it with http.StripPrefix to serve files under a prefix.
+// Passwords of protected files are added upstream, Range requests are passed through and only content headers are returned
+func NewProxyHandler(client mod.Waifuvalt, opts mod.ProxyHandlerOpts) http.Handler {
+	if opts.TTL <= 0 {
+		opts.TTL = defaultProxyTTL
+	}
+	if opts.CacheControl == "" {
+		opts.CacheControl = defaultProxyCacheControl
+	}
+	handler := &proxyHandler{
+		client: client,
+		opts:   opts,
+		tokens: map[string]bool{},
+		files:  map[string]proxyEntry{},
+	}
+	for _, token := range opts.Tokens {
+		handler.tokens[token] = true
+	}
+	handler.proxy = &httputil.ReverseProxy{
+		Rewrite:        handler.rewrite,
+		ModifyResponse: handler.modifyResponse,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		},
+		Transport: opts.Transport,
+	}
+	return handler
+}
+
+func (re *proxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	if name == "" || strings.Contains(name, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	file := re.resolve(r.Context(), name)
+	if file == nil {
+		http.NotFound(w, r)
+		return
+	}
+	re.proxy.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), proxyFileKey{}, file)))
+}
+
+// resolve looks up a file by token or filename, returning nil if it does not exist.
+// Upstream is asked without holding the lock, and concurrent lookups of the same name share one request
+func (re *proxyHandler) resolve(ctx context.Context, name string) *mod.WaifuResponse[int] {
+	if file, found := re.cached(name); found {
+		return file
+	}
+	value, _ := re.flight.do(ctx, name, func(ctx context.Context) (any, error) {
+		return re.lookup(ctx, name), nil
+	})
+	// value is nil if the request went away before the lookup finished
+	file, _ := value.(*mod.WaifuResponse[int])
+	return file
+}
+
+// cached returns the cached lookup of name, found is false if there is none or it is out of date
+func (re *proxyHandler) cached(name string) (file *mod.WaifuResponse[int], found bool) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	entry, found := re.files[name]
+	ttl := re.opts.TTL
+	if entry.file == nil {
+		ttl = min(ttl, proxyMissTTL)
+	}
+	if !found || time.Since(entry.fetched) >= ttl {
+		return nil, false
+	}
+	return entry.file, true
+}
+
+// lookup asks upstream for a file, listing the bucket first if its listing is out of date.
+// Names outside the bucket are only looked up if they are allowed tokens
+func (re *proxyHandler) lookup(ctx context.Context, name string) *mod.WaifuResponse[int] {
+	if re.opts.BucketToken != "" {
+		re.mu.Lock()
+		stale := time.Since(re.bucketFetched) >= re.opts.TTL
+		re.mu.Unlock()
+		if stale {
+			re.flight.do(ctx, proxyBucketKey, func(ctx context.Context) (any, error) {
+				re.listBucket(ctx)
+				return nil, nil
+			})
+			if file, found := re.cached(name); found && file != nil {
+				return file
+			}
+		}
+	}
+
+	var file *mod.WaifuResponse[int]
+	if re.opts.AllowAnyToken || re.tokens[name] {
+		if info, err := re.client.FileInfo(ctx, name); err == nil {
+			file = info
+		}
+	}
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	now := time.Now()
+	if file == nil && now.Sub(re.missesSwept) >= proxyMissTTL {
+		// misses are never requested again if they were probes, so they are dropped once they expire
+		re.missesSwept = now
+		for missed, entry := range re.files {
+			if entry.file == nil && now.Sub(entry.fetched) >= proxyMissTTL {
+				delete(re.files, missed)
+			}
+		}
+	}
+	re.files[name] = proxyEntry{file: file, fetched: now}
+	return file
+}
+
+// listBucket caches every file of the bucket by token and filename
+func (re *proxyHandler) listBucket(ctx context.Context) {
+	bucket, err := re.client.GetBucket(ctx, re.opts.BucketToken)
+	if err != nil {
+		return
+	}
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	now := time.Now()
+	re.bucketFetched = now
+	for _, file := range bucket.Files {
+		entry := proxyEntry{file: &file, fetched: now}
+		re.files[file.Token] = entry
+		re.files[uploadFilename(file)] = entry
+	}
+}
+
+// forget discards the cached lookups of a file that no longer exists upstream
+func (re *proxyHandler) forget(file *mod.WaifuResponse[int]) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	for name, entry := range re.files {
+		if entry.file != nil && entry.file.Token == file.Token {
+			delete(re.files, name)
+		}
+	}
+}
+
+func (re *proxyHandler) rewrite(pr *httputil.ProxyRequest) {
+	file := pr.In.Context().Value(proxyFileKey{}).(*mod.WaifuResponse[int])
+	upstream, err := url.Parse(file.URL)
+	if err != nil {
+		upstream = &url.URL{}
+	}
+	pr.Out.URL = upstream
+	pr.Out.Host = ""
+	for _, header := range []string{"Authorization", "Cookie", "Referer", "Origin", "x-password"} {
+		pr.Out.Header.Del(header)
+	}
+	if password := re.opts.Passwords[file.Token]; password != "" {
+		pr.Out.Header.Set("x-password", password)
+	}
+}
+
+func (re *proxyHandler) modifyResponse(resp *http.Response) error {
+	file := resp.Request.Context().Value(proxyFileKey{}).(*mod.WaifuResponse[int])
+	header := http.Header{}
+	ok := resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusPartialContent || resp.StatusCode == http.StatusNotModified
+	if ok {
+		for _, name := range proxiedHeaders {
+			if value := resp.Header.Values(name); len(value) > 0 {
+				header[name] = value
+			}
+		}
+		if file.Options.OneTimeDownload {
+			header.Set("Cache-Control", "no-store")
+		} else if file.Options.Protected {
+			header.Set("Cache-Control", protectedCacheControl)
+		} else {
+			header.Set("Cache-Control", re.opts.CacheControl)
+		}
+	} else {
+		if resp.StatusCode == http.StatusNotFound {
+			re.forget(file)
+		}
+		text := http.StatusText(resp.StatusCode) + "\n"
+		resp.Body.Close()
+		resp.Body = io.NopCloser(strings.NewReader(text))
+		resp.ContentLength = int64(len(text))
+		header.Set("Content-Type", "text/plain; charset=utf-8")
+		header.Set("Content-Length", strconv.Itoa(len(text)))
+	}
+	resp.Header = header
+	return nil
+}
@@ -0,0 +1,195 @@
+package waifuVault
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+func proxyGet(t *testing.T, handler http.Handler, path string, header map[string]string) (*http.Response, string) {
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	r, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+	for key, value := range header {
+		r.Header.Set(key, value)
+	}
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp, string(body)
+}
+
+func TestProxyHandler(t *testing.T) {
+	t.Run("should serve files by token", func(t *testing.T) {
+		fv := newFakeVault(t)
+		token := fv.addFile("", "page.html", []byte("<p>hello</p>"), mod.WaifuResponseOptions{}, "")
+
+		handler := NewProxyHandler(NewWaifuvaltApi(http.Client{}), mod.ProxyHandlerOpts{Tokens: []string{token}})
+		resp, body := proxyGet(t, handler, "/"+token, map[string]string{"Cookie": "session=secret"})
+
+		if resp.StatusCode != http.StatusOK || body != "<p>hello</p>" {
+			t.Fatalf("Unexpected response %d: %s", resp.StatusCode, body)
+		}
+		if resp.Header.Get("Content-Type") != "text/html; charset=utf-8" || resp.Header.Get("Content-Length") != "12" {
+			t.Errorf("Expected content headers, got %v", resp.Header)
+		}
+		if resp.Header.Get("Cache-Control") != "public, max-age=3600" {
+			t.Errorf("Expected the default cache control, got %s", resp.Header.Get("Cache-Control"))
+		}
+	})
+
+	t.Run("should add the password upstream", func(t *testing.T) {
+		fv := newFakeVault(t)
+		token := fv.addFile("", "secret.txt", []byte("secret"), mod.WaifuResponseOptions{Protected: true}, "pass")
+
+		resp, _ := proxyGet(t, NewProxyHandler(NewWaifuvaltApi(http.Client{}), mod.ProxyHandlerOpts{AllowAnyToken: true}), "/"+token, nil)
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected %d without a password, got %d", http.StatusForbidden, resp.StatusCode)
+		}
+		handler := NewProxyHandler(NewWaifuvaltApi(http.Client{}), mod.ProxyHandlerOpts{
+			AllowAnyToken: true,
+			Passwords:     map[string]string{token: "pass"},
+		})
+		resp, body := proxyGet(t, handler, "/"+token, nil)
+		if resp.StatusCode != http.StatusOK || body != "secret" {
+			t.Errorf("Unexpected response %d: %s", resp.StatusCode, body)
+		}
+		if resp.Header.Get("Cache-Control") != "private, no-store" {
+			t.Errorf("Expected private, no-store, got %s", resp.Header.Get("Cache-Control"))
+		}
+	})
+
+	t.Run("should pass range requests through", func(t *testing.T) {
+		fv := newFakeVault(t)
+		token := fv.addFile("", "data.bin", []byte("0123456789"), mod.WaifuResponseOptions{}, "")
+
+		handler := NewProxyHandler(NewWaifuvaltApi(http.Client{}), mod.ProxyHandlerOpts{AllowAnyToken: true})
+		resp, body := proxyGet(t, handler, "/"+token, map[string]string{"Range": "bytes=2-4"})
+
+		if resp.StatusCode != http.StatusPartialContent || body != "234" {
+			t.Errorf("Unexpected response %d: %s", resp.StatusCode, body)
+		}
+		if resp.Header.Get("Content-Range") != "bytes 2-4/10" {
+			t.Errorf("Expected the content range, got %s", resp.Header.Get("Content-Range"))
+		}
+	})
+
+	t.Run("should serve files by filename and cache the lookup", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		fv.addFile(bucket, "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+
+		handler := NewProxyHandler(NewWaifuvaltApi(http.Client{}), mod.ProxyHandlerOpts{BucketToken: bucket})
+		for range 3 {
+			if resp, body := proxyGet(t, handler, "/08.png", nil); body != "image" {
+				t.Errorf("Unexpected response %d: %s", resp.StatusCode, body)
+			}
+		}
+		if fv.requestCount(http.MethodPost, "/rest/bucket/get") != 1 {
+			t.Errorf("Expected the bucket to be fetched once, got %d", fv.requestCount(http.MethodPost, "/rest/bucket/get"))
+		}
+	})
+
+	t.Run("should not cache one time downloads", func(t *testing.T) {
+		fv := newFakeVault(t)
+		token := fv.addFile("", "once.txt", []byte("once"), mod.WaifuResponseOptions{OneTimeDownload: true}, "")
+
+		handler := NewProxyHandler(NewWaifuvaltApi(http.Client{}), mod.ProxyHandlerOpts{AllowAnyToken: true})
+		resp, _ := proxyGet(t, handler, "/"+token, nil)
+		if resp.Header.Get("Cache-Control") != "no-store" {
+			t.Errorf("Expected no-store, got %s", resp.Header.Get("Cache-Control"))
+		}
+		if resp, _ = proxyGet(t, handler, "/"+token, nil); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected %d once downloaded, got %d", http.StatusNotFound, resp.StatusCode)
+		}
+	})
+
+	t.Run("should return not found for unknown files", func(t *testing.T) {
+		newFakeVault(t)
+		handler := NewProxyHandler(NewWaifuvaltApi(http.Client{}), mod.ProxyHandlerOpts{})
+		for _, path := range []string{"/missing", "/", "/a/b"} {
+			if resp, _ := proxyGet(t, handler, path, nil); resp.StatusCode != http.StatusNotFound {
+				t.Errorf("Expected %d for %s, got %d", http.StatusNotFound, path, resp.StatusCode)
+			}
+		}
+	})
+
+	t.Run("should not look up tokens that are not allowed", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		fv.addFile(bucket, "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		other := fv.addFile(fv.addBucket(), "09.png", []byte("other"), mod.WaifuResponseOptions{}, "")
+
+		for _, opts := range []mod.ProxyHandlerOpts{{}, {BucketToken: bucket}} {
+			handler := NewProxyHandler(NewWaifuvaltApi(http.Client{}), opts)
+			if resp, _ := proxyGet(t, handler, "/"+other, nil); resp.StatusCode != http.StatusNotFound {
+				t.Errorf("Expected %d, got %d", http.StatusNotFound, resp.StatusCode)
+			}
+		}
+		if count := fv.requestCount(http.MethodGet, "/rest/"+other); count != 0 {
+			t.Errorf("Expected no lookups upstream, got %d", count)
+		}
+	})
+
+	t.Run("should cache misses briefly", func(t *testing.T) {
+		fv := newFakeVault(t)
+		handler := NewProxyHandler(NewWaifuvaltApi(http.Client{}), mod.ProxyHandlerOpts{AllowAnyToken: true})
+		for range 3 {
+			if resp, _ := proxyGet(t, handler, "/missing", nil); resp.StatusCode != http.StatusNotFound {
+				t.Errorf("Expected %d, got %d", http.StatusNotFound, resp.StatusCode)
+			}
+		}
+		if count := fv.requestCount(http.MethodGet, "/rest/missing"); count != 1 {
+			t.Errorf("Expected the miss to be looked up once, got %d", count)
+		}
+	})
+
+	t.Run("should look up files without blocking other requests", func(t *testing.T) {
+		fv := newFakeVault(t)
+		cached := fv.addFile("", "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		slow := fv.addFile("", "09.png", []byte("slow"), mod.WaifuResponseOptions{}, "")
+
+		var lookups atomic.Int32
+		blocked, release := make(chan struct{}), make(chan struct{})
+		client := NewWaifuvaltApiWithOpts(http.Client{}, mod.ClientOpts{Middleware: []mod.Middleware{
+			mod.MiddlewareFunc(func(operation mod.Operation, r *http.Request, next mod.RequestHandler) (*http.Response, error) {
+				if operation == mod.OperationFileInfo && strings.Contains(r.URL.Path, slow) && lookups.Add(1) == 1 {
+					close(blocked)
+					<-release
+				}
+				return next(r)
+			}),
+		}})
+		handler := NewProxyHandler(client, mod.ProxyHandlerOpts{AllowAnyToken: true})
+		proxyGet(t, handler, "/"+cached, nil)
+
+		var wg sync.WaitGroup
+		for range 3 {
+			wg.Go(func() {
+				if resp, body := proxyGet(t, handler, "/"+slow, nil); body != "slow" {
+					t.Errorf("Unexpected response %d: %s", resp.StatusCode, body)
+				}
+			})
+		}
+		<-blocked
+		if resp, body := proxyGet(t, handler, "/"+cached, nil); body != "image" {
+			t.Errorf("Expected a cached file to be served during a lookup, got %d: %s", resp.StatusCode, body)
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		if count := lookups.Load(); count != 1 {
+			t.Errorf("Expected concurrent requests to share one lookup, got %d", count)
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package waifuVault
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+// RateLimitMiddleware limits how fast requests are started, how fast bytes are sent and received and how many requests
+// run at once. Requests wait for their turn until their context is cancelled.
+// Put it after RetryMiddleware so every attempt is limited
+func RateLimitMiddleware(opts mod.RateLimitOpts) mod.Middleware {
+	if opts.Burst <= 0 {
+		opts.Burst = 1
+	}
+	var requests *tokenBucket
+	if opts.RequestsPerSecond > 0 {
+		requests = newTokenBucket(opts.RequestsPerSecond, float64(opts.Burst))
+	}
+	bandwidth := NewBandwidthLimiter(mod.BandwidthOpts{
+		UploadBytesPerSecond:   opts.UploadBytesPerSecond,
+		DownloadBytesPerSecond: opts.DownloadBytesPerSecond,
+	})
+	var inFlight chan struct{}
+	if opts.MaxInFlight > 0 {
+		inFlight = make(chan struct{}, opts.MaxInFlight)
+	}
+	return mod.MiddlewareFunc(func(operation mod.Operation, r *http.Request, next mod.RequestHandler) (*http.Response, error) {
+		ctx := r.Context()
+		if requests != nil {
+			if err := requests.wait(ctx, 1); err != nil {
+				return nil, err
+			}
+		}
+		release := func() {}
+		if inFlight != nil {
+			select {
+			case inFlight <- struct{}{}:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			var once sync.Once
+			release = func() { once.Do(func() { <-inFlight }) }
+		}
+
+		resp, err := bandwidth.Handle(operation, r, next)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		resp.Body = &countingReadCloser{ReadCloser: resp.Body, onClose: release}
+		return resp, nil
+	})
+}
@@ -0,0 +1,148 @@
+package waifuVault
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should limit requests per second", func(t *testing.T) {
+		fv := newFakeVault(t)
+		token := fv.addFile("", "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		api := NewWaifuvaltApiWithOpts(http.Client{}, mod.ClientOpts{Middleware: []mod.Middleware{
+			RateLimitMiddleware(mod.RateLimitOpts{RequestsPerSecond: 20}),
+		}})
+
+		start := time.Now()
+		for range 5 {
+			if _, err := api.FileInfo(ctx, token); err != nil {
+				t.Fatalf("FileInfo failed: %v", err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+			t.Errorf("Expected 5 requests at 20 per second to take 200ms, took %s", elapsed)
+		}
+	})
+
+	t.Run("should limit downloads", func(t *testing.T) {
+		fv := newFakeVault(t)
+		token := fv.addFile("", "08.png", bytes.Repeat([]byte("a"), 1500), mod.WaifuResponseOptions{}, "")
+		api := NewWaifuvaltApiWithOpts(http.Client{}, mod.ClientOpts{Middleware: []mod.Middleware{
+			RateLimitMiddleware(mod.RateLimitOpts{DownloadBytesPerSecond: 1000}),
+		}})
+
+		// the file info response uses part of the first second
+		start := time.Now()
+		content, err := api.GetFile(ctx, mod.GetFileInfo{Token: token})
+		if err != nil {
+			t.Fatalf("GetFile failed: %v", err)
+		}
+		if elapsed := time.Since(start); len(content) != 1500 || elapsed < 400*time.Millisecond {
+			t.Errorf("Expected 1500 bytes to be throttled, got %d bytes in %s", len(content), elapsed)
+		}
+	})
+
+	t.Run("should limit uploads", func(t *testing.T) {
+		next := func(r *http.Request) (*http.Response, error) {
+			io.Copy(io.Discard, r.Body)
+			return statusResponse(http.StatusOK), nil
+		}
+		limit := RateLimitMiddleware(mod.RateLimitOpts{UploadBytesPerSecond: 1000})
+
+		start := time.Now()
+		req, _ := http.NewRequest(http.MethodPut, "https://waifuvault.moe/rest", bytes.NewReader(make([]byte, 1500)))
+		limit.Handle(mod.OperationUploadFile, req, next)
+		if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+			t.Errorf("Expected 1500 bytes at 1000 per second to take 500ms, took %s", elapsed)
+		}
+	})
+
+	t.Run("should cap requests in flight", func(t *testing.T) {
+		var running, maxRunning atomic.Int32
+		next := func(r *http.Request) (*http.Response, error) {
+			n := running.Add(1)
+			for {
+				current := maxRunning.Load()
+				if n <= current || maxRunning.CompareAndSwap(current, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			running.Add(-1)
+			return statusResponse(http.StatusOK), nil
+		}
+		limit := RateLimitMiddleware(mod.RateLimitOpts{MaxInFlight: 2})
+
+		var wg sync.WaitGroup
+		for range 6 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				req, _ := http.NewRequest(http.MethodGet, "https://waifuvault.moe/rest/token", nil)
+				resp, err := limit.Handle(mod.OperationFileInfo, req, next)
+				if err == nil {
+					resp.Body.Close()
+				}
+			}()
+		}
+		wg.Wait()
+
+		if maxRunning.Load() != 2 {
+			t.Errorf("Expected at most 2 requests in flight, got %d", maxRunning.Load())
+		}
+	})
+
+	t.Run("should stop waiting when the context is cancelled", func(t *testing.T) {
+		next := func(r *http.Request) (*http.Response, error) {
+			return statusResponse(http.StatusOK), nil
+		}
+		limit := RateLimitMiddleware(mod.RateLimitOpts{RequestsPerSecond: 0.01, MaxInFlight: 1})
+		req, _ := http.NewRequest(http.MethodGet, "https://waifuvault.moe/rest/token", nil)
+		held, err := limit.Handle(mod.OperationFileInfo, req, next)
+		if err != nil {
+			t.Fatalf("Handle failed: %v", err)
+		}
+		defer held.Body.Close()
+
+		ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		req, _ = http.NewRequestWithContext(ctx, http.MethodGet, "https://waifuvault.moe/rest/token", nil)
+		if _, err = limit.Handle(mod.OperationFileInfo, req, next); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected the context error, got %v", err)
+		}
+	})
+	t.Run("should not leak uploads cancelled while waiting", func(t *testing.T) {
+		fv := newFakeVault(t)
+		token := fv.addFile("", "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		api := NewWaifuvaltApiWithOpts(http.Client{}, mod.ClientOpts{Middleware: []mod.Middleware{
+			RateLimitMiddleware(mod.RateLimitOpts{RequestsPerSecond: 0.1}),
+		}})
+		// use up the burst so the uploads have to wait
+		if _, err := api.FileInfo(ctx, token); err != nil {
+			t.Fatalf("FileInfo failed: %v", err)
+		}
+
+		before := runtime.NumGoroutine()
+		for range 20 {
+			ctx, cancel := context.WithTimeout(ctx, time.Millisecond)
+			_, err := api.UploadFile(ctx, mod.WaifuvaultPutOpts{Reader: bytes.NewReader([]byte("image")), FileName: "08.png"})
+			cancel()
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("Expected the upload to time out, got %v", err)
+			}
+		}
+		checkGoroutines(t, before)
+	})
+}
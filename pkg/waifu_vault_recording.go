@@ -0,0 +1,208 @@
+package waifuVault
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+type recordingApi struct {
+	mod.Waifuvalt
+	store mod.TokenStore
+	opts  mod.RecordingOpts
+}
+
+// NewRecordingApi wraps a client so every file, bucket and album token it creates is recorded in store.
+// Records are updated when files are modified or associated with albums, and removed when the entry is deleted.
+// If the entry was created but could not be recorded, both the result and the error are returned
+func NewRecordingApi(client mod.Waifuvalt, store mod.TokenStore, opts mod.RecordingOpts) mod.Waifuvalt {
+	return &recordingApi{
+		Waifuvalt: client,
+		store:     store,
+		opts:      opts,
+	}
+}
+
+func (re *recordingApi) UploadFile(ctx context.Context, options mod.WaifuvaultPutOpts) (*mod.WaifuResponse[string], error) {
+	resp, err := re.Waifuvalt.UploadFile(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	record := mod.TokenRecord{
+		Kind:     mod.TokenFile,
+		Token:    resp.Token,
+		Filename: uploadedFilename(options),
+		URL:      resp.URL,
+		Bucket:   resp.Bucket,
+	}
+	if retention, err := parseRetention(resp.RetentionPeriod); err == nil {
+		record.Expires = time.Now().Add(retention)
+	}
+	if options.Password != "" && re.opts.PasswordHint != nil {
+		record.PasswordHint = re.opts.PasswordHint(options.Password)
+	}
+	return resp, re.record(record)
+}
+
+func (re *recordingApi) ModifyFile(ctx context.Context, token string, options mod.ModifyEntryPayload) (*mod.WaifuResponse[int], error) {
+	resp, err := re.Waifuvalt.ModifyFile(ctx, token, options)
+	if err != nil {
+		return nil, err
+	}
+	return resp, re.update(token, func(record *mod.TokenRecord) {
+		record.URL = resp.URL
+		record.Expires = time.Now().Add(retentionDuration(resp.RetentionPeriod))
+		if options.Password != nil && *options.Password == "" {
+			record.PasswordHint = ""
+		} else if options.Password != nil && re.opts.PasswordHint != nil {
+			record.PasswordHint = re.opts.PasswordHint(*options.Password)
+		}
+	})
+}
+
+func (re *recordingApi) DeleteFile(ctx context.Context, token string) (bool, error) {
+	deleted, err := re.Waifuvalt.DeleteFile(ctx, token)
+	if err != nil || !deleted {
+		return deleted, err
+	}
+	return deleted, re.forget(token)
+}
+
+func (re *recordingApi) CreateBucket(ctx context.Context) (*mod.WaifuBucket, error) {
+	resp, err := re.Waifuvalt.CreateBucket(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return resp, re.record(mod.TokenRecord{Kind: mod.TokenBucket, Token: resp.Token})
+}
+
+func (re *recordingApi) DeleteBucket(ctx context.Context, token string) (bool, error) {
+	deleted, err := re.Waifuvalt.DeleteBucket(ctx, token)
+	if err != nil || !deleted {
+		return deleted, err
+	}
+	contents, err := TokensInBucket(re.store, token)
+	if err != nil {
+		return deleted, err
+	}
+	for _, record := range contents {
+		if err = re.forget(record.Token); err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, re.forget(token)
+}
+
+func (re *recordingApi) CreateAlbum(ctx context.Context, body mod.WaifuAlbumCreateBody) (*mod.WaifuAlbum, error) {
+	resp, err := re.Waifuvalt.CreateAlbum(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+	return resp, re.record(mod.TokenRecord{
+		Kind:     mod.TokenAlbum,
+		Token:    resp.Token,
+		Filename: resp.Name,
+		Bucket:   resp.BucketToken,
+	})
+}
+
+func (re *recordingApi) AssociateFiles(ctx context.Context, albumToken string, filesToAssociate []string) (*mod.WaifuAlbum, error) {
+	resp, err := re.Waifuvalt.AssociateFiles(ctx, albumToken, filesToAssociate)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range filesToAssociate {
+		if err = re.update(token, func(record *mod.TokenRecord) { record.Album = albumToken }); err != nil {
+			return resp, err
+		}
+	}
+	return resp, nil
+}
+
+func (re *recordingApi) DisassociateFiles(ctx context.Context, albumToken string, filesToDisassociate []string) (*mod.WaifuAlbum, error) {
+	resp, err := re.Waifuvalt.DisassociateFiles(ctx, albumToken, filesToDisassociate)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range filesToDisassociate {
+		if err = re.update(token, func(record *mod.TokenRecord) { record.Album = "" }); err != nil {
+			return resp, err
+		}
+	}
+	return resp, nil
+}
+
+func (re *recordingApi) DeleteAlbum(ctx context.Context, albumToken string, deleteFiles bool) (*mod.GenericSuccess, error) {
+	resp, err := re.Waifuvalt.DeleteAlbum(ctx, albumToken, deleteFiles)
+	if err != nil {
+		return nil, err
+	}
+	files, err := TokensInAlbum(re.store, albumToken)
+	if err != nil {
+		return resp, err
+	}
+	for _, record := range files {
+		if deleteFiles {
+			err = re.forget(record.Token)
+		} else {
+			err = re.update(record.Token, func(record *mod.TokenRecord) { record.Album = "" })
+		}
+		if err != nil {
+			return resp, err
+		}
+	}
+	return resp, re.forget(albumToken)
+}
+
+func (re *recordingApi) GetFileStream(ctx context.Context, options mod.GetFileInfo) (io.ReadCloser, error) {
+	return StreamFile(ctx, re.Waifuvalt, options)
+}
+
+func (re *recordingApi) DownloadAlbumStream(ctx context.Context, albumToken string, files []int) (io.ReadCloser, error) {
+	return StreamAlbum(ctx, re.Waifuvalt, albumToken, files)
+}
+
+func (re *recordingApi) GetFileSize(ctx context.Context, options mod.GetFileInfo) (int64, error) {
+	return FileSize(ctx, re.Waifuvalt, options)
+}
+
+func (re *recordingApi) record(record mod.TokenRecord) error {
+	if err := re.store.Put(record); err != nil {
+		return fmt.Errorf("unable to record token %s: %w", record.Token, err)
+	}
+	return nil
+}
+
+// update changes a record if the token is known
+func (re *recordingApi) update(token string, change func(record *mod.TokenRecord)) error {
+	record, err := re.store.Get(token)
+	if err != nil || record == nil {
+		return err
+	}
+	change(record)
+	return re.record(*record)
+}
+
+func (re *recordingApi) forget(token string) error {
+	if err := re.store.Delete(token); err != nil {
+		return fmt.Errorf("unable to forget token %s: %w", token, err)
+	}
+	return nil
+}
+
+// uploadedFilename is the name of the file as it was uploaded
+func uploadedFilename(options mod.WaifuvaultPutOpts) string {
+	switch {
+	case options.File != nil:
+		return filepath.Base(options.File.Name())
+	case options.Url != "":
+		return path.Base(options.Url)
+	default:
+		return options.FileName
+	}
+}
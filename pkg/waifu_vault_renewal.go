@@ -0,0 +1,244 @@
+package waifuVault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+const (
+	defaultRenewalInterval  = time.Hour
+	defaultRenewalThreshold = 24 * time.Hour
+)
+
+// Renewer keeps files alive by extending their expiry before the retention period elapses
+type Renewer struct {
+	client mod.Waifuvalt
+	opts   mod.RenewalOpts
+
+	mu      sync.Mutex
+	targets map[string]mod.RenewalTarget
+}
+
+// NewRenewer creates a renewal service, call Start to run it
+func NewRenewer(client mod.Waifuvalt, opts mod.RenewalOpts) *Renewer {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultRenewalInterval
+	}
+	if opts.Threshold <= 0 {
+		opts.Threshold = defaultRenewalThreshold
+	}
+	targets := make(map[string]mod.RenewalTarget, len(opts.Targets))
+	for _, target := range opts.Targets {
+		targets[target.Token] = target
+	}
+	return &Renewer{
+		client:  client,
+		opts:    opts,
+		targets: targets,
+	}
+}
+
+// Add starts tracking a file
+func (re *Renewer) Add(target mod.RenewalTarget) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	re.targets[target.Token] = target
+}
+
+// Remove stops tracking a file
+func (re *Renewer) Remove(token string) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	delete(re.targets, token)
+}
+
+// Start checks all files immediately and then every interval until ctx is cancelled.
+// The returned channel is closed once the service has stopped
+func (re *Renewer) Start(ctx context.Context) <-chan mod.RenewalEvent {
+	events := make(chan mod.RenewalEvent, 16)
+	go func() {
+		defer close(events)
+		ticker := time.NewTicker(re.opts.Interval)
+		defer ticker.Stop()
+		for {
+			re.check(ctx, events)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return events
+}
+
+// check renews every target, the explicit targets are still renewed if the bucket can not be listed
+func (re *Renewer) check(ctx context.Context, events chan<- mod.RenewalEvent) {
+	targets, err := re.currentTargets(ctx)
+	if err != nil && ctx.Err() == nil {
+		emitRenewal(ctx, events, mod.RenewalEvent{Type: mod.RenewalFailed, Err: err})
+	}
+	for _, target := range targets {
+		event, renewed := re.renew(ctx, target)
+		if ctx.Err() != nil {
+			return
+		}
+		if renewed {
+			emitRenewal(ctx, events, event)
+		}
+	}
+}
+
+func (re *Renewer) currentTargets(ctx context.Context) ([]mod.RenewalTarget, error) {
+	re.mu.Lock()
+	targets := make([]mod.RenewalTarget, 0, len(re.targets))
+	known := make(map[string]bool, len(re.targets))
+	for _, target := range re.targets {
+		targets = append(targets, target)
+		known[target.Token] = true
+	}
+	re.mu.Unlock()
+
+	if re.opts.BucketToken == "" {
+		return targets, nil
+	}
+	bucket, err := re.client.GetBucket(ctx, re.opts.BucketToken)
+	if err != nil {
+		return targets, err
+	}
+	for _, file := range bucket.Files {
+		if !known[file.Token] {
+			targets = append(targets, mod.RenewalTarget{Token: file.Token})
+		}
+	}
+	return targets, nil
+}
+
+// renew extends a single file if needed, the bool is false if the file did not need renewing
+func (re *Renewer) renew(ctx context.Context, target mod.RenewalTarget) (mod.RenewalEvent, bool) {
+	event := mod.RenewalEvent{Token: target.Token, Type: mod.RenewalFailed}
+	info, err := re.client.FileInfo(ctx, target.Token)
+	if err != nil {
+		event.Err = err
+		return event, true
+	}
+	remaining := retentionDuration(info.RetentionPeriod)
+	event.Remaining = remaining
+	if remaining >= re.opts.Threshold {
+		return event, false
+	}
+
+	// without an expiry there is nothing to extend the file with, so it can only be re-uploaded
+	err = errors.New("no expiry is set to extend the file with")
+	if re.opts.Expiry != "" {
+		modified, modifyErr := re.client.ModifyFile(ctx, target.Token, mod.ModifyEntryPayload{CustomExpiry: &re.opts.Expiry})
+		if modifyErr == nil && modified.RetentionPeriod > info.RetentionPeriod {
+			event.Type = mod.RenewalExtended
+			event.Remaining = retentionDuration(modified.RetentionPeriod)
+			return event, true
+		}
+		err = modifyErr
+		if err == nil {
+			err = errors.New("the server did not extend the expiry")
+		}
+	}
+	if target.LocalPath == "" {
+		event.Err = fmt.Errorf("unable to extend file and no local copy is available: %w", err)
+		return event, true
+	}
+
+	uploaded, err := re.reupload(ctx, target, info)
+	if err != nil {
+		event.Err = err
+		return event, true
+	}
+	event.Type = mod.RenewalReuploaded
+	event.NewToken = uploaded.Token
+	if uploadedInfo, err := re.client.FileInfo(ctx, uploaded.Token); err == nil {
+		event.Remaining = retentionDuration(uploadedInfo.RetentionPeriod)
+	}
+
+	re.mu.Lock()
+	if _, tracked := re.targets[target.Token]; tracked {
+		delete(re.targets, target.Token)
+		target.Token = uploaded.Token
+		re.targets[target.Token] = target
+	}
+	re.mu.Unlock()
+	return event, true
+}
+
+// reupload uploads the local copy with the filename and options of the file, and adds it to the album of the file
+func (re *Renewer) reupload(ctx context.Context, target mod.RenewalTarget, info *mod.WaifuResponse[int]) (*mod.WaifuResponse[string], error) {
+	album, err := re.albumOf(ctx, info)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find the album of %s: %w", target.Token, err)
+	}
+	file, err := os.Open(target.LocalPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	uploaded, err := re.client.UploadFile(ctx, mod.WaifuvaultPutOpts{
+		Reader:          file,
+		FileName:        reuploadFilename(*info, target.LocalPath),
+		Password:        target.Password,
+		Expires:         re.opts.Expiry,
+		HideFilename:    info.Options.HideFilename,
+		OneTimeDownload: info.Options.OneTimeDownload,
+		BucketToken:     info.Bucket,
+	})
+	if err != nil || album == "" {
+		return uploaded, err
+	}
+	if _, err = re.client.AssociateFiles(ctx, album, []string{uploaded.Token}); err != nil {
+		return nil, fmt.Errorf("unable to add the re-upload %s to its album: %w", uploaded.Token, err)
+	}
+	return uploaded, nil
+}
+
+// albumOf returns the token of the album a file is in, or an empty string if it is not in one
+func (re *Renewer) albumOf(ctx context.Context, info *mod.WaifuResponse[int]) (string, error) {
+	if info.Bucket == "" {
+		return "", nil
+	}
+	for stub, err := range BucketAlbums(ctx, re.client, info.Bucket) {
+		if err != nil {
+			return "", err
+		}
+		album, err := re.client.GetAlbum(ctx, stub.Token)
+		if err != nil {
+			return "", err
+		}
+		if slices.ContainsFunc(album.Files, func(file mod.WaifuResponse[int]) bool { return file.Token == info.Token }) {
+			return stub.Token, nil
+		}
+	}
+	return "", nil
+}
+
+// reuploadFilename is the filename of the file, or of the local copy if the URL does not contain it
+func reuploadFilename(info mod.WaifuResponse[int], localPath string) string {
+	if parsed, err := ParseFileURL(info.URL); err == nil && !parsed.Hidden {
+		return parsed.Filename
+	}
+	return filepath.Base(localPath)
+}
+
+func emitRenewal(ctx context.Context, events chan<- mod.RenewalEvent, event mod.RenewalEvent) {
+	if ctx.Err() != nil {
+		return
+	}
+	select {
+	case events <- event:
+	case <-ctx.Done():
+	}
+}
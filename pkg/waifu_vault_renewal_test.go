@@ -0,0 +1,225 @@
+package waifuVault
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+func TestRenewer(t *testing.T) {
+	hour := int(time.Hour / time.Millisecond)
+
+	t.Run("should extend a file that is about to expire", func(t *testing.T) {
+		var expiry string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			response := WaifuResponseMock1
+			switch r.Method {
+			case http.MethodGet:
+				response.RetentionPeriod = hour
+			case http.MethodPatch:
+				var payload mod.ModifyEntryPayload
+				json.NewDecoder(r.Body).Decode(&payload)
+				expiry = *payload.CustomExpiry
+				response.RetentionPeriod = 30 * 24 * hour
+			}
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(response)
+		}))
+		defer server.Close()
+
+		origBaseUrl := baseUrl
+		defer func() { baseUrl = origBaseUrl }()
+		baseUrl = server.URL
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		renewer := NewRenewer(NewWaifuvaltApi(http.Client{}), mod.RenewalOpts{
+			Targets:   []mod.RenewalTarget{{Token: WaifuResponseMock1.Token}},
+			Threshold: 2 * time.Hour,
+			Expiry:    "30d",
+		})
+		event := <-renewer.Start(ctx)
+
+		if event.Type != mod.RenewalExtended {
+			t.Fatalf("Expected extended event, got %d (%v)", event.Type, event.Err)
+		}
+		if event.Token != WaifuResponseMock1.Token {
+			t.Errorf("Expected token %s, got %s", WaifuResponseMock1.Token, event.Token)
+		}
+		if event.Remaining != 30*24*time.Hour {
+			t.Errorf("Expected 720h remaining, got %s", event.Remaining)
+		}
+		if expiry != "30d" {
+			t.Errorf("Expected customExpiry 30d, got %s", expiry)
+		}
+	})
+
+	t.Run("should re-upload from the local copy if the expiry can not be extended", func(t *testing.T) {
+		localPath := filepath.Join(t.TempDir(), "08.png")
+		if err := os.WriteFile(localPath, []byte("local copy"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var uploadedBucket string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet:
+				response := WaifuResponseMock1
+				response.RetentionPeriod = hour
+				json.NewEncoder(w).Encode(response)
+			case http.MethodPatch:
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(WaifuErrorMock)
+			case http.MethodPost:
+				json.NewEncoder(w).Encode(mod.WaifuBucket{Token: WaifuResponseMock1.Bucket})
+			case http.MethodPut:
+				uploadedBucket = r.URL.Path
+				json.NewEncoder(w).Encode(WaifuResponseMock2)
+			}
+		}))
+		defer server.Close()
+
+		origBaseUrl := baseUrl
+		defer func() { baseUrl = origBaseUrl }()
+		baseUrl = server.URL
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		renewer := NewRenewer(NewWaifuvaltApi(http.Client{}), mod.RenewalOpts{
+			Targets: []mod.RenewalTarget{{Token: WaifuResponseMock1.Token, LocalPath: localPath}},
+			Expiry:  "30d",
+		})
+		event := <-renewer.Start(ctx)
+
+		if event.Type != mod.RenewalReuploaded {
+			t.Fatalf("Expected re-uploaded event, got %d (%v)", event.Type, event.Err)
+		}
+		if event.NewToken != WaifuResponseMock2.Token {
+			t.Errorf("Expected new token %s, got %s", WaifuResponseMock2.Token, event.NewToken)
+		}
+		if uploadedBucket != "/rest/"+WaifuResponseMock1.Bucket {
+			t.Errorf("Expected upload into bucket %s, got %s", WaifuResponseMock1.Bucket, uploadedBucket)
+		}
+	})
+
+	t.Run("should re-upload with the filename and album of the file when no expiry is set", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		token := fv.addFile(bucket, "holiday.png", []byte("local copy"), mod.WaifuResponseOptions{}, "")
+		album := fv.addAlbum(bucket, "photos", token)
+		localPath := filepath.Join(t.TempDir(), "IMG_0001.png")
+		if err := os.WriteFile(localPath, []byte("local copy"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		renewer := NewRenewer(NewWaifuvaltApi(http.Client{}), mod.RenewalOpts{
+			Targets:   []mod.RenewalTarget{{Token: token, LocalPath: localPath}},
+			Threshold: 2 * fakeVaultRetention,
+		})
+		event := <-renewer.Start(ctx)
+
+		if event.Type != mod.RenewalReuploaded {
+			t.Fatalf("Expected re-uploaded event, got %d (%v)", event.Type, event.Err)
+		}
+		uploaded := fv.file(event.NewToken)
+		if uploaded.name != "holiday.png" {
+			t.Errorf("Expected filename holiday.png, got %s", uploaded.name)
+		}
+		if !slices.Contains(fv.album(album).files, event.NewToken) {
+			t.Errorf("Expected %s to be in album %s, got %v", event.NewToken, album, fv.album(album).files)
+		}
+		if count := fv.requestCount(http.MethodPatch, "/rest/"); count != 0 {
+			t.Errorf("Expected no modify requests, got %d", count)
+		}
+	})
+
+	t.Run("should renew the targets when the bucket can not be listed", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		token := fv.addFile(bucket, "holiday.png", []byte("content"), mod.WaifuResponseOptions{}, "")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		renewer := NewRenewer(NewWaifuvaltApi(http.Client{}), mod.RenewalOpts{
+			Targets:     []mod.RenewalTarget{{Token: token}},
+			BucketToken: "missing-bucket",
+			Threshold:   2 * fakeVaultRetention,
+			Expiry:      "90d",
+		})
+		events := renewer.Start(ctx)
+
+		if event := <-events; event.Type != mod.RenewalFailed || event.Err == nil {
+			t.Fatalf("Expected failed event with an error, got %d (%v)", event.Type, event.Err)
+		}
+		if event := <-events; event.Type != mod.RenewalExtended || event.Token != token {
+			t.Errorf("Expected extended event for %s, got %d for %s (%v)", token, event.Type, event.Token, event.Err)
+		}
+	})
+
+	t.Run("should report files that can not be renewed", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPatch {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(WaifuErrorMock)
+				return
+			}
+			response := WaifuResponseMock1
+			response.RetentionPeriod = hour
+			json.NewEncoder(w).Encode(response)
+		}))
+		defer server.Close()
+
+		origBaseUrl := baseUrl
+		defer func() { baseUrl = origBaseUrl }()
+		baseUrl = server.URL
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		renewer := NewRenewer(NewWaifuvaltApi(http.Client{}), mod.RenewalOpts{
+			Targets: []mod.RenewalTarget{{Token: WaifuResponseMock1.Token}},
+		})
+		event := <-renewer.Start(ctx)
+
+		if event.Type != mod.RenewalFailed {
+			t.Fatalf("Expected failed event, got %d", event.Type)
+		}
+		if event.Err == nil {
+			t.Error("Expected an error but got none")
+		}
+	})
+
+	t.Run("should stop when the context is cancelled", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			response := WaifuResponseMock1
+			response.RetentionPeriod = 30 * 24 * hour
+			json.NewEncoder(w).Encode(response)
+		}))
+		defer server.Close()
+
+		origBaseUrl := baseUrl
+		defer func() { baseUrl = origBaseUrl }()
+		baseUrl = server.URL
+
+		ctx, cancel := context.WithCancel(context.Background())
+		renewer := NewRenewer(NewWaifuvaltApi(http.Client{}), mod.RenewalOpts{
+			Targets:  []mod.RenewalTarget{{Token: WaifuResponseMock1.Token}},
+			Interval: time.Millisecond,
+		})
+		events := renewer.Start(ctx)
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+
+		for event := range events {
+			t.Errorf("Expected no events, got %d", event.Type)
+		}
+	})
+}
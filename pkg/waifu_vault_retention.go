@@ -0,0 +1,292 @@
+package waifuVault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+const defaultRetentionInterval = time.Hour
+
+// RetentionPolicy deletes the files of a bucket selected by declarative rules, such as keeping the last 50 builds
+// or deleting files that were never downloaded after a week
+type RetentionPolicy struct {
+	client mod.Waifuvalt
+	opts   mod.RetentionPolicyOpts
+
+	auditMu sync.Mutex
+}
+
+// retentionCandidate is a bucket file with its upload time and album names, rule and reason are set once it is selected
+type retentionCandidate struct {
+	file     mod.WaifuResponse[int]
+	uploaded time.Time
+	albums   []string
+	rule     string
+	reason   string
+}
+
+// NewRetentionPolicy creates a retention policy engine, call Plan to see what it would delete and Enforce or Start to delete it
+func NewRetentionPolicy(client mod.Waifuvalt, opts mod.RetentionPolicyOpts) (*RetentionPolicy, error) {
+	if opts.BucketToken == "" {
+		return nil, errors.New("a bucket token is required")
+	}
+	for _, rule := range opts.Rules {
+		if rule.KeepLast <= 0 && rule.MaxAge <= 0 && rule.MaxTotalSize <= 0 {
+			return nil, fmt.Errorf("rule %q must set KeepLast, MaxAge or MaxTotalSize", rule.Name)
+		}
+		for _, pattern := range rule.Albums {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("rule %q has an invalid album pattern: %w", rule.Name, err)
+			}
+		}
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaultRetentionInterval
+	}
+	if opts.SizeOf == nil {
+		opts.SizeOf = headSize(client)
+	}
+	return &RetentionPolicy{client: client, opts: opts}, nil
+}
+
+// Plan evaluates the rules against the bucket and returns the files they select, without deleting anything
+func (re *RetentionPolicy) Plan(ctx context.Context) (*mod.RetentionPlan, error) {
+	bucket, err := re.client.GetBucket(ctx, re.opts.BucketToken)
+	if err != nil {
+		return nil, err
+	}
+	plan := &mod.RetentionPlan{BucketToken: re.opts.BucketToken, DryRun: re.opts.DryRun, Errors: map[string]error{}}
+	albums, err := re.albumNames(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []retentionCandidate
+	for _, file := range bucket.Files {
+		parsed, err := ParseFileURL(file.URL)
+		if err != nil {
+			plan.Errors[file.Token] = err
+			continue
+		}
+		candidates = append(candidates, retentionCandidate{file: file, uploaded: parsed.Epoch, albums: albums[file.Token]})
+	}
+	// newest first, so KeepLast and MaxTotalSize keep the newest files
+	slices.SortStableFunc(candidates, func(a, b retentionCandidate) int {
+		return b.uploaded.Compare(a.uploaded)
+	})
+
+	selected := map[string]bool{}
+	var deletions []retentionCandidate
+	sizes := map[string]int64{}
+	now := time.Now()
+	for _, rule := range re.opts.Rules {
+		var total int64
+		overSize := false
+		matched := 0
+		for _, candidate := range candidates {
+			if !ruleMatches(rule, candidate) {
+				continue
+			}
+			matched++
+			var reason string
+			switch {
+			case rule.KeepLast > 0 && matched > rule.KeepLast:
+				reason = fmt.Sprintf("not one of the newest %d files", rule.KeepLast)
+			case rule.MaxAge > 0 && now.Sub(candidate.uploaded) > rule.MaxAge:
+				reason = fmt.Sprintf("uploaded more than %s ago", rule.MaxAge)
+			case rule.MaxTotalSize > 0:
+				// once the limit is reached every older file is deleted without asking for its size
+				if !overSize {
+					total += re.size(ctx, candidate.file, sizes, plan)
+					overSize = total > rule.MaxTotalSize
+				}
+				if overSize {
+					reason = fmt.Sprintf("newer files add up to more than %d bytes", rule.MaxTotalSize)
+				}
+			}
+			if reason == "" || selected[candidate.file.Token] {
+				continue
+			}
+			selected[candidate.file.Token] = true
+			candidate.rule, candidate.reason = rule.Name, reason
+			deletions = append(deletions, candidate)
+		}
+	}
+	slices.SortStableFunc(deletions, func(a, b retentionCandidate) int {
+		return a.uploaded.Compare(b.uploaded)
+	})
+	for _, deletion := range deletions {
+		plan.Deletions = append(plan.Deletions, mod.RetentionDeletion{File: deletion.file, Rule: deletion.rule, Reason: deletion.reason})
+	}
+	return plan, nil
+}
+
+// Enforce plans the deletions and executes them unless DryRun is set. Every planned deletion is written to the audit log
+func (re *RetentionPolicy) Enforce(ctx context.Context) (*mod.RetentionPlan, error) {
+	return re.enforce(ctx, func(mod.RetentionAuditEntry) {})
+}
+
+// Start enforces the rules immediately and then every interval until ctx is cancelled, emitting every audit entry.
+// The returned channel is closed once the engine has stopped
+func (re *RetentionPolicy) Start(ctx context.Context) <-chan mod.RetentionAuditEntry {
+	entries := make(chan mod.RetentionAuditEntry, 16)
+	go func() {
+		defer close(entries)
+		ticker := time.NewTicker(re.opts.Interval)
+		defer ticker.Stop()
+		emit := func(entry mod.RetentionAuditEntry) {
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case entries <- entry:
+			case <-ctx.Done():
+			}
+		}
+		for {
+			re.enforce(ctx, emit)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return entries
+}
+
+func (re *RetentionPolicy) enforce(ctx context.Context, emit func(mod.RetentionAuditEntry)) (*mod.RetentionPlan, error) {
+	plan, err := re.Plan(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		entry := mod.RetentionAuditEntry{Time: time.Now(), BucketToken: re.opts.BucketToken, DryRun: re.opts.DryRun, Error: err.Error()}
+		re.audit(entry)
+		emit(entry)
+		return nil, err
+	}
+	for i := range plan.Deletions {
+		deletion := &plan.Deletions[i]
+		if !plan.DryRun {
+			deletion.Deleted, deletion.Err = re.client.DeleteFile(ctx, deletion.File.Token)
+			if deletion.Err == nil && !deletion.Deleted {
+				deletion.Err = errors.New("the server did not delete the file")
+			}
+		}
+		entry := mod.RetentionAuditEntry{
+			Time:        time.Now(),
+			BucketToken: plan.BucketToken,
+			Token:       deletion.File.Token,
+			URL:         deletion.File.URL,
+			Rule:        deletion.Rule,
+			Reason:      deletion.Reason,
+			DryRun:      plan.DryRun,
+			Deleted:     deletion.Deleted,
+		}
+		if deletion.Err != nil {
+			entry.Error = deletion.Err.Error()
+		}
+		re.audit(entry)
+		emit(entry)
+	}
+	return plan, nil
+}
+
+// audit writes an entry to the audit log, with its tokens redacted unless AuditTokens is set
+func (re *RetentionPolicy) audit(entry mod.RetentionAuditEntry) {
+	if re.opts.Audit == nil {
+		return
+	}
+	if !re.opts.AuditTokens {
+		for _, token := range []string{entry.BucketToken, entry.Token} {
+			if token != "" {
+				entry.Error = strings.ReplaceAll(entry.Error, token, redacted)
+			}
+		}
+		if entry.BucketToken != "" {
+			entry.BucketToken = redacted
+		}
+		if entry.Token != "" {
+			entry.Token = redacted
+		}
+	}
+	re.auditMu.Lock()
+	defer re.auditMu.Unlock()
+	json.NewEncoder(re.opts.Audit).Encode(entry)
+}
+
+// albumNames maps file tokens to the names of their albums, albums are only fetched if a rule needs them
+func (re *RetentionPolicy) albumNames(ctx context.Context, bucket *mod.WaifuBucket) (map[string][]string, error) {
+	names := map[string][]string{}
+	needed := slices.ContainsFunc(re.opts.Rules, func(rule mod.RetentionRule) bool {
+		return len(rule.Albums) > 0 || rule.SkipAlbums
+	})
+	if !needed {
+		return names, nil
+	}
+	for _, stub := range bucket.Albums {
+		album, err := re.client.GetAlbum(ctx, stub.Token)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range album.Files {
+			names[file.Token] = append(names[file.Token], stub.Name)
+		}
+	}
+	return names, nil
+}
+
+// size returns the size of a file once per plan, files whose size can not be found count as 0 bytes
+func (re *RetentionPolicy) size(ctx context.Context, file mod.WaifuResponse[int], sizes map[string]int64, plan *mod.RetentionPlan) int64 {
+	if size, ok := sizes[file.Token]; ok {
+		return size
+	}
+	size, err := re.opts.SizeOf(ctx, file)
+	if err != nil {
+		plan.Errors[file.Token] = err
+		size = 0
+	}
+	sizes[file.Token] = size
+	return size
+}
+
+func ruleMatches(rule mod.RetentionRule, candidate retentionCandidate) bool {
+	if rule.Match != nil && !rule.Match(candidate.file) {
+		return false
+	}
+	if rule.SkipAlbums && len(candidate.albums) > 0 {
+		return false
+	}
+	if len(rule.Albums) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(candidate.albums, func(name string) bool {
+		return slices.ContainsFunc(rule.Albums, func(pattern string) bool {
+			matched, _ := path.Match(pattern, name)
+			return matched
+		})
+	})
+}
+
+// headSize gets the size of a file with a HEAD request sent by client
+func headSize(client mod.Waifuvalt) func(ctx context.Context, file mod.WaifuResponse[int]) (int64, error) {
+	return func(ctx context.Context, file mod.WaifuResponse[int]) (int64, error) {
+		if file.Options.OneTimeDownload {
+			return 0, errors.New("the size of a one time download file is unknown")
+		}
+		size, err := FileSize(ctx, client, mod.GetFileInfo{Url: file.URL})
+		if err != nil {
+			return 0, fmt.Errorf("the size of %s is unknown: %w", file.Token, err)
+		}
+		return size, nil
+	}
+}
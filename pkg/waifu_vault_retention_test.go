@@ -0,0 +1,241 @@
+package waifuVault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+func TestRetentionPolicy(t *testing.T) {
+	ctx := context.Background()
+	api := NewWaifuvaltApi(http.Client{})
+
+	t.Run("should keep the newest files", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		oldest := fv.addFile(bucket, "nightly-1.zip", []byte("build"), mod.WaifuResponseOptions{}, "")
+		older := fv.addFile(bucket, "nightly-2.zip", []byte("build"), mod.WaifuResponseOptions{}, "")
+		newest := fv.addFile(bucket, "nightly-3.zip", []byte("build"), mod.WaifuResponseOptions{}, "")
+		readme := fv.addFile(bucket, "readme.txt", []byte("text"), mod.WaifuResponseOptions{}, "")
+
+		policy, err := NewRetentionPolicy(api, mod.RetentionPolicyOpts{
+			BucketToken: bucket,
+			Rules:       []mod.RetentionRule{{Name: "nightlies", Match: FilterFilenameGlob("nightly-*"), KeepLast: 1}},
+		})
+		if err != nil {
+			t.Fatalf("NewRetentionPolicy failed: %v", err)
+		}
+		plan, err := policy.Enforce(ctx)
+		if err != nil {
+			t.Fatalf("Enforce failed: %v", err)
+		}
+		if len(plan.Deletions) != 2 || plan.Deletions[0].File.Token != oldest || plan.Deletions[1].File.Token != older {
+			t.Fatalf("Expected the two oldest nightlies to be deleted, got %+v", plan.Deletions)
+		}
+		for _, deletion := range plan.Deletions {
+			if !deletion.Deleted || deletion.Rule != "nightlies" || deletion.Err != nil {
+				t.Errorf("Expected the nightlies rule to delete the file, got %+v", deletion)
+			}
+		}
+		if fv.file(oldest) != nil || fv.file(older) != nil || fv.file(newest) == nil || fv.file(readme) == nil {
+			t.Errorf("Expected only the oldest nightlies to be deleted")
+		}
+	})
+
+	t.Run("should delete unviewed files after the max age", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		fv.epoch = time.Now().Add(-8 * 24 * time.Hour).UnixMilli()
+		stale := fv.addFile(bucket, "stale.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		viewed := fv.addFile(bucket, "viewed.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		fv.file(viewed).response.Views = 2
+		fv.epoch = time.Now().Add(-time.Hour).UnixMilli()
+		recent := fv.addFile(bucket, "recent.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+
+		policy, _ := NewRetentionPolicy(api, mod.RetentionPolicyOpts{
+			BucketToken: bucket,
+			Rules:       []mod.RetentionRule{{Name: "unviewed", Match: FilterMaxViews(0), MaxAge: 7 * 24 * time.Hour}},
+		})
+		plan, err := policy.Enforce(ctx)
+		if err != nil {
+			t.Fatalf("Enforce failed: %v", err)
+		}
+		if len(plan.Deletions) != 1 || plan.Deletions[0].File.Token != stale {
+			t.Fatalf("Expected only the stale file to be deleted, got %+v", plan.Deletions)
+		}
+		if fv.file(stale) != nil || fv.file(viewed) == nil || fv.file(recent) == nil {
+			t.Errorf("Expected only the stale file to be deleted")
+		}
+	})
+
+	t.Run("should keep the total size under the limit", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		first := fv.addFile(bucket, "1.bin", bytes.Repeat([]byte("a"), 40), mod.WaifuResponseOptions{}, "")
+		second := fv.addFile(bucket, "2.bin", bytes.Repeat([]byte("a"), 40), mod.WaifuResponseOptions{}, "")
+		third := fv.addFile(bucket, "3.bin", bytes.Repeat([]byte("a"), 40), mod.WaifuResponseOptions{}, "")
+		fourth := fv.addFile(bucket, "4.bin", bytes.Repeat([]byte("a"), 40), mod.WaifuResponseOptions{}, "")
+
+		policy, _ := NewRetentionPolicy(api, mod.RetentionPolicyOpts{
+			BucketToken: bucket,
+			Rules:       []mod.RetentionRule{{Name: "size", MaxTotalSize: 100}},
+		})
+		plan, err := policy.Plan(ctx)
+		if err != nil {
+			t.Fatalf("Plan failed: %v", err)
+		}
+		if len(plan.Deletions) != 2 || plan.Deletions[0].File.Token != first || plan.Deletions[1].File.Token != second {
+			t.Fatalf("Expected the two oldest files to be planned, got %+v", plan.Deletions)
+		}
+		if fv.file(first) == nil || fv.file(third) == nil || fv.file(fourth) == nil || len(plan.Errors) != 0 {
+			t.Errorf("Expected Plan not to delete anything, got errors %v", plan.Errors)
+		}
+		// once the limit is reached the older files are not asked for their size
+		if count := fv.requestCount(http.MethodHead, "/f/"); count != 3 {
+			t.Errorf("Expected 3 HEAD requests, got %d", count)
+		}
+	})
+
+	t.Run("should request sizes through the client", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		fv.addFile(bucket, "1.bin", bytes.Repeat([]byte("a"), 40), mod.WaifuResponseOptions{}, "")
+		fv.addFile(bucket, "2.bin", bytes.Repeat([]byte("a"), 40), mod.WaifuResponseOptions{}, "")
+		sized := 0
+		client := NewCachingApi(NewWaifuvaltApiWithOpts(http.Client{}, mod.ClientOpts{Middleware: []mod.Middleware{
+			mod.MiddlewareFunc(func(operation mod.Operation, r *http.Request, next mod.RequestHandler) (*http.Response, error) {
+				if operation == mod.OperationFileSize {
+					sized++
+				}
+				return next(r)
+			}),
+		}}), mod.CachingOpts{})
+
+		policy, _ := NewRetentionPolicy(client, mod.RetentionPolicyOpts{
+			BucketToken: bucket,
+			Rules:       []mod.RetentionRule{{Name: "size", MaxTotalSize: 100}},
+		})
+		plan, err := policy.Plan(ctx)
+		if err != nil {
+			t.Fatalf("Plan failed: %v", err)
+		}
+		if len(plan.Errors) != 0 {
+			t.Fatalf("Expected no errors, got %v", plan.Errors)
+		}
+		if sized != 2 {
+			t.Errorf("Expected 2 size requests through the middleware, got %d", sized)
+		}
+	})
+
+	t.Run("should only audit in a dry run", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		old := fv.addFile(bucket, "old.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		fv.addFile(bucket, "new.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+
+		var audit bytes.Buffer
+		policy, _ := NewRetentionPolicy(api, mod.RetentionPolicyOpts{
+			BucketToken: bucket,
+			Rules:       []mod.RetentionRule{{Name: "latest", KeepLast: 1}},
+			DryRun:      true,
+			Audit:       &audit,
+		})
+		plan, err := policy.Enforce(ctx)
+		if err != nil {
+			t.Fatalf("Enforce failed: %v", err)
+		}
+		if !plan.DryRun || len(plan.Deletions) != 1 || plan.Deletions[0].Deleted {
+			t.Fatalf("Expected a planned deletion, got %+v", plan)
+		}
+		if fv.file(old) == nil || fv.requestCount(http.MethodDelete, "/rest/") != 0 {
+			t.Errorf("Expected nothing to be deleted")
+		}
+
+		lines := strings.Split(strings.TrimSpace(audit.String()), "\n")
+		if len(lines) != 1 {
+			t.Fatalf("Expected 1 audit line, got %q", audit.String())
+		}
+		var entry mod.RetentionAuditEntry
+		if err = json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		if entry.Token != redacted || entry.BucketToken != redacted || entry.Rule != "latest" || !entry.DryRun || entry.Deleted {
+			t.Errorf("Expected a redacted dry run entry for the old file, got %+v", entry)
+		}
+		if strings.Contains(audit.String(), old) || strings.Contains(audit.String(), bucket) {
+			t.Errorf("Expected the tokens to be redacted, got %s", audit.String())
+		}
+	})
+
+	t.Run("should audit tokens when asked to", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		old := fv.addFile(bucket, "old.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		fv.addFile(bucket, "new.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+
+		var audit bytes.Buffer
+		policy, _ := NewRetentionPolicy(api, mod.RetentionPolicyOpts{
+			BucketToken: bucket,
+			Rules:       []mod.RetentionRule{{Name: "latest", KeepLast: 1}},
+			DryRun:      true,
+			Audit:       &audit,
+			AuditTokens: true,
+		})
+		if _, err := policy.Enforce(ctx); err != nil {
+			t.Fatalf("Enforce failed: %v", err)
+		}
+		var entry mod.RetentionAuditEntry
+		if err := json.Unmarshal(audit.Bytes(), &entry); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		if entry.Token != old || entry.BucketToken != bucket {
+			t.Errorf("Expected the tokens to be audited, got %+v", entry)
+		}
+	})
+
+	t.Run("should respect album membership", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		kept := fv.addFile(bucket, "kept.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		release := fv.addFile(bucket, "release.zip", []byte("build"), mod.WaifuResponseOptions{}, "")
+		loose := fv.addFile(bucket, "loose.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		fv.addAlbum(bucket, "favourites", kept)
+		fv.addAlbum(bucket, "releases-2024", release)
+		fv.addFile(bucket, "newest.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+
+		policy, _ := NewRetentionPolicy(api, mod.RetentionPolicyOpts{
+			BucketToken: bucket,
+			Rules: []mod.RetentionRule{
+				{Name: "loose", SkipAlbums: true, Match: FilterFilenameGlob("*.png"), KeepLast: 1},
+				{Name: "releases", Albums: []string{"releases-*"}, MaxAge: time.Nanosecond},
+			},
+		})
+		plan, err := policy.Plan(ctx)
+		if err != nil {
+			t.Fatalf("Plan failed: %v", err)
+		}
+		planned := map[string]string{}
+		for _, deletion := range plan.Deletions {
+			planned[deletion.File.Token] = deletion.Rule
+		}
+		if len(planned) != 2 || planned[loose] != "loose" || planned[release] != "releases" {
+			t.Errorf("Expected the loose file and the release to be planned, got %v", planned)
+		}
+	})
+
+	t.Run("should reject rules without a criterion", func(t *testing.T) {
+		_, err := NewRetentionPolicy(api, mod.RetentionPolicyOpts{
+			BucketToken: "bucket",
+			Rules:       []mod.RetentionRule{{Name: "empty", Match: FilterProtected()}},
+		})
+		if err == nil {
+			t.Errorf("Expected an error for a rule without a criterion")
+		}
+	})
+}
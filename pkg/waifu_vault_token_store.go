@@ -0,0 +1,230 @@
+package waifuVault
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+const (
+	tokenStoreMagic      = "WVTS1"
+	tokenStoreSaltSize   = 16
+	tokenStoreIterations = 600000
+)
+
+// ErrTokenStorePassphrase is returned when a token store can not be decrypted with the given passphrase
+var ErrTokenStorePassphrase = errors.New("incorrect passphrase or corrupted token store")
+
+// FileTokenStore is a TokenStore persisted to a single file, encrypted at rest with AES-GCM using a key derived from a passphrase
+type FileTokenStore struct {
+	path string
+	salt []byte
+	aead cipher.AEAD
+
+	mu      sync.Mutex
+	records map[string]mod.TokenRecord
+}
+
+// NewFileTokenStore opens the token store at path, creating it if it does not exist
+func NewFileTokenStore(path, passphrase string) (*FileTokenStore, error) {
+	store := &FileTokenStore{
+		path:    path,
+		records: map[string]mod.TokenRecord{},
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		store.salt = make([]byte, tokenStoreSaltSize)
+		if _, err = rand.Read(store.salt); err != nil {
+			return nil, err
+		}
+		if store.aead, err = newTokenStoreCipher(passphrase, store.salt); err != nil {
+			return nil, err
+		}
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	header := len(tokenStoreMagic) + tokenStoreSaltSize
+	if len(data) < header || !bytes.HasPrefix(data, []byte(tokenStoreMagic)) {
+		return nil, ErrTokenStorePassphrase
+	}
+	store.salt = data[len(tokenStoreMagic):header]
+	if store.aead, err = newTokenStoreCipher(passphrase, store.salt); err != nil {
+		return nil, err
+	}
+	nonceSize := store.aead.NonceSize()
+	if len(data) < header+nonceSize {
+		return nil, ErrTokenStorePassphrase
+	}
+	plaintext, err := store.aead.Open(nil, data[header:header+nonceSize], data[header+nonceSize:], []byte(tokenStoreMagic))
+	if err != nil {
+		return nil, ErrTokenStorePassphrase
+	}
+	var records []mod.TokenRecord
+	if err = json.Unmarshal(plaintext, &records); err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		store.records[record.Token] = record
+	}
+	return store, nil
+}
+
+func (re *FileTokenStore) Put(record mod.TokenRecord) error {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+	previous, existed := re.records[record.Token]
+	re.records[record.Token] = record
+	if err := re.save(); err != nil {
+		if existed {
+			re.records[record.Token] = previous
+		} else {
+			delete(re.records, record.Token)
+		}
+		return err
+	}
+	return nil
+}
+
+func (re *FileTokenStore) Get(token string) (*mod.TokenRecord, error) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	record, found := re.records[token]
+	if !found {
+		return nil, nil
+	}
+	return &record, nil
+}
+
+func (re *FileTokenStore) Delete(token string) error {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	record, found := re.records[token]
+	if !found {
+		return nil
+	}
+	delete(re.records, token)
+	if err := re.save(); err != nil {
+		re.records[token] = record
+		return err
+	}
+	return nil
+}
+
+func (re *FileTokenStore) List() ([]mod.TokenRecord, error) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	return re.sortedRecords(), nil
+}
+
+func (re *FileTokenStore) sortedRecords() []mod.TokenRecord {
+	records := make([]mod.TokenRecord, 0, len(re.records))
+	for _, record := range re.records {
+		records = append(records, record)
+	}
+	slices.SortFunc(records, func(a, b mod.TokenRecord) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Token, b.Token)
+	})
+	return records
+}
+
+// save encrypts the records and atomically replaces the store file, callers must hold the lock
+func (re *FileTokenStore) save() error {
+	plaintext, err := json.Marshal(re.sortedRecords())
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, re.aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return err
+	}
+	data := append([]byte(tokenStoreMagic), re.salt...)
+	data = append(data, nonce...)
+	data = re.aead.Seal(data, nonce, plaintext, []byte(tokenStoreMagic))
+
+	tmp, err := os.CreateTemp(filepath.Dir(re.path), filepath.Base(re.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), re.path)
+}
+
+func newTokenStoreCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	if passphrase == "" {
+		return nil, errors.New("a passphrase is required")
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, tokenStoreIterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// TokensExpiringBefore returns the file records that expire before t, for example time.Now().AddDate(0, 0, 7) for all files expiring this week
+func TokensExpiringBefore(store mod.TokenStore, t time.Time) ([]mod.TokenRecord, error) {
+	return filterTokens(store, func(record mod.TokenRecord) bool {
+		return record.Kind == mod.TokenFile && !record.Expires.IsZero() && record.Expires.Before(t)
+	})
+}
+
+// TokensInAlbum returns the file records associated with an album
+func TokensInAlbum(store mod.TokenStore, albumToken string) ([]mod.TokenRecord, error) {
+	return filterTokens(store, func(record mod.TokenRecord) bool {
+		return record.Kind == mod.TokenFile && record.Album == albumToken
+	})
+}
+
+// TokensInBucket returns the file and album records in a bucket
+func TokensInBucket(store mod.TokenStore, bucketToken string) ([]mod.TokenRecord, error) {
+	return filterTokens(store, func(record mod.TokenRecord) bool {
+		return record.Kind != mod.TokenBucket && record.Bucket == bucketToken
+	})
+}
+
+func filterTokens(store mod.TokenStore, keep func(record mod.TokenRecord) bool) ([]mod.TokenRecord, error) {
+	records, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	var result []mod.TokenRecord
+	for _, record := range records {
+		if keep(record) {
+			result = append(result, record)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,172 @@
+package waifuVault
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+func TestFileTokenStore(t *testing.T) {
+	t.Run("should persist records encrypted", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tokens.db")
+		store, err := NewFileTokenStore(path, "passphrase")
+		if err != nil {
+			t.Fatalf("NewFileTokenStore failed: %v", err)
+		}
+		err = store.Put(mod.TokenRecord{Kind: mod.TokenFile, Token: "secret-file-token", Filename: "08.png"})
+		if err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+
+		data, _ := os.ReadFile(path)
+		if strings.Contains(string(data), "secret-file-token") || strings.Contains(string(data), "08.png") {
+			t.Error("Expected the store to be encrypted")
+		}
+
+		reopened, err := NewFileTokenStore(path, "passphrase")
+		if err != nil {
+			t.Fatalf("NewFileTokenStore failed: %v", err)
+		}
+		record, err := reopened.Get("secret-file-token")
+		if err != nil || record == nil {
+			t.Fatalf("Expected record to be persisted, got %v", err)
+		}
+		if record.Filename != "08.png" || record.CreatedAt.IsZero() {
+			t.Errorf("Unexpected record %+v", record)
+		}
+	})
+
+	t.Run("should reject an incorrect passphrase", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tokens.db")
+		store, _ := NewFileTokenStore(path, "passphrase")
+		store.Put(mod.TokenRecord{Kind: mod.TokenBucket, Token: "bucket"})
+
+		_, err := NewFileTokenStore(path, "wrong")
+		if !errors.Is(err, ErrTokenStorePassphrase) {
+			t.Errorf("Expected passphrase error, got %v", err)
+		}
+	})
+
+	t.Run("should delete records", func(t *testing.T) {
+		store, _ := NewFileTokenStore(filepath.Join(t.TempDir(), "tokens.db"), "passphrase")
+		store.Put(mod.TokenRecord{Kind: mod.TokenBucket, Token: "bucket"})
+
+		if err := store.Delete("bucket"); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		records, _ := store.List()
+		if len(records) != 0 {
+			t.Errorf("Expected no records, got %v", records)
+		}
+	})
+}
+
+func TestTokenQueries(t *testing.T) {
+	store, _ := NewFileTokenStore(filepath.Join(t.TempDir(), "tokens.db"), "passphrase")
+	now := time.Now()
+	store.Put(mod.TokenRecord{Kind: mod.TokenBucket, Token: "bucket"})
+	store.Put(mod.TokenRecord{Kind: mod.TokenAlbum, Token: "album", Bucket: "bucket"})
+	store.Put(mod.TokenRecord{Kind: mod.TokenFile, Token: "soon", Bucket: "bucket", Album: "album", Expires: now.Add(time.Hour)})
+	store.Put(mod.TokenRecord{Kind: mod.TokenFile, Token: "later", Bucket: "bucket", Expires: now.Add(30 * 24 * time.Hour)})
+
+	t.Run("should find files expiring this week", func(t *testing.T) {
+		records, err := TokensExpiringBefore(store, now.AddDate(0, 0, 7))
+		if err != nil || len(records) != 1 || records[0].Token != "soon" {
+			t.Errorf("Unexpected records %v (%v)", records, err)
+		}
+	})
+
+	t.Run("should find files in an album", func(t *testing.T) {
+		records, err := TokensInAlbum(store, "album")
+		if err != nil || len(records) != 1 || records[0].Token != "soon" {
+			t.Errorf("Unexpected records %v (%v)", records, err)
+		}
+	})
+
+	t.Run("should find everything in a bucket", func(t *testing.T) {
+		records, err := TokensInBucket(store, "bucket")
+		if err != nil || len(records) != 3 {
+			t.Errorf("Unexpected records %v (%v)", records, err)
+		}
+	})
+}
+
+func TestRecordingApi(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should record created tokens", func(t *testing.T) {
+		newFakeVault(t)
+		store, _ := NewFileTokenStore(filepath.Join(t.TempDir(), "tokens.db"), "passphrase")
+		api := NewRecordingApi(NewWaifuvaltApi(http.Client{}), store, mod.RecordingOpts{
+			PasswordHint: func(password string) string { return "starts with " + password[:1] },
+		})
+
+		bucket, err := api.CreateBucket(ctx)
+		if err != nil {
+			t.Fatalf("CreateBucket failed: %v", err)
+		}
+		fileBytes := []byte("image")
+		file, err := api.UploadFile(ctx, mod.WaifuvaultPutOpts{Bytes: &fileBytes, FileName: "08.png", Password: "foo", BucketToken: bucket.Token})
+		if err != nil {
+			t.Fatalf("UploadFile failed: %v", err)
+		}
+		album, err := api.CreateAlbum(ctx, mod.WaifuAlbumCreateBody{Name: "album1", BucketToken: bucket.Token})
+		if err != nil {
+			t.Fatalf("CreateAlbum failed: %v", err)
+		}
+		if _, err = api.AssociateFiles(ctx, album.Token, []string{file.Token}); err != nil {
+			t.Fatalf("AssociateFiles failed: %v", err)
+		}
+
+		record, _ := store.Get(file.Token)
+		if record == nil {
+			t.Fatal("Expected the file token to be recorded")
+		}
+		if record.Filename != "08.png" || record.URL != file.URL || record.Bucket != bucket.Token || record.Album != album.Token {
+			t.Errorf("Unexpected record %+v", record)
+		}
+		if record.PasswordHint != "starts with f" {
+			t.Errorf("Expected password hint, got %s", record.PasswordHint)
+		}
+		if time.Until(record.Expires) < 29*24*time.Hour {
+			t.Errorf("Expected expiry in 30 days, got %s", record.Expires)
+		}
+		if records, _ := store.List(); len(records) != 3 {
+			t.Errorf("Expected bucket, album and file to be recorded, got %v", records)
+		}
+	})
+
+	t.Run("should forget deleted entries", func(t *testing.T) {
+		newFakeVault(t)
+		store, _ := NewFileTokenStore(filepath.Join(t.TempDir(), "tokens.db"), "passphrase")
+		api := NewRecordingApi(NewWaifuvaltApi(http.Client{}), store, mod.RecordingOpts{})
+
+		bucket, _ := api.CreateBucket(ctx)
+		fileBytes := []byte("image")
+		file, _ := api.UploadFile(ctx, mod.WaifuvaultPutOpts{Bytes: &fileBytes, FileName: "08.png", BucketToken: bucket.Token})
+		other, _ := api.UploadFile(ctx, mod.WaifuvaultPutOpts{Bytes: &fileBytes, FileName: "09.png", BucketToken: bucket.Token})
+
+		if _, err := api.DeleteFile(ctx, file.Token); err != nil {
+			t.Fatalf("DeleteFile failed: %v", err)
+		}
+		if record, _ := store.Get(file.Token); record != nil {
+			t.Error("Expected the deleted file to be forgotten")
+		}
+		if _, err := api.DeleteBucket(ctx, bucket.Token); err != nil {
+			t.Fatalf("DeleteBucket failed: %v", err)
+		}
+		if record, _ := store.Get(other.Token); record != nil {
+			t.Error("Expected files in the deleted bucket to be forgotten")
+		}
+		if records, _ := store.List(); len(records) != 0 {
+			t.Errorf("Expected no records, got %v", records)
+		}
+	})
+}
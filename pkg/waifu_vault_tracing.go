@@ -0,0 +1,410 @@
+package waifuVault
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+// tokenPrefixLength is how much of a token is recorded on spans, enough to find it without leaking it
+const tokenPrefixLength = 8
+
+// TracingMiddleware starts a span for every request and sends its W3C traceparent header.
+// The span ends once the response body is closed. tracer defaults to NoopTracer.
+// Use NewTracingApi as well to group the requests of an operation under one span
+func TracingMiddleware(tracer mod.Tracer) mod.Middleware {
+	if tracer == nil {
+		tracer = NoopTracer{}
+	}
+	return mod.MiddlewareFunc(func(operation mod.Operation, r *http.Request, next mod.RequestHandler) (*http.Response, error) {
+		ctx, span := tracer.Start(r.Context(), operation)
+		r = r.WithContext(ctx)
+		if traceParent := span.TraceParent(); traceParent != "" {
+			r.Header.Set("traceparent", traceParent)
+		}
+		span.SetAttribute(mod.AttributeOperation, string(operation))
+		span.SetAttribute(mod.AttributeMethod, r.Method)
+		token, bucket := requestTokens(operation, r)
+		if token != "" {
+			span.SetAttribute(mod.AttributeTokenPrefix, tokenPrefix(token))
+		}
+		if bucket != "" {
+			span.SetAttribute(mod.AttributeBucketPrefix, tokenPrefix(bucket))
+		}
+		sent := &countingReadCloser{}
+		if r.Body != nil && r.Body != http.NoBody {
+			sent.ReadCloser = r.Body
+			r.Body = sent
+		}
+
+		resp, err := next(r)
+		if err != nil {
+			span.SetAttribute(mod.AttributeBytesSent, sent.count())
+			span.End(err)
+			return nil, err
+		}
+		span.SetAttribute(mod.AttributeStatus, resp.StatusCode)
+		received := &countingReadCloser{ReadCloser: resp.Body}
+		received.onClose = func() {
+			span.SetAttribute(mod.AttributeBytesSent, sent.count())
+			span.SetAttribute(mod.AttributeBytesReceived, received.count())
+			span.End(nil)
+		}
+		resp.Body = received
+		return resp, nil
+	})
+}
+
+type tracingApi struct {
+	mod.Waifuvalt
+	tracer mod.Tracer
+}
+
+// NewTracingApi wraps a client so every operation runs in a span, which ends when the operation returns or, for streams,
+// when the stream is closed. Spans started by TracingMiddleware for the requests of the operation become its children,
+// so an operation that makes several requests, such as GetFile by token, is a single span. tracer defaults to NoopTracer
+func NewTracingApi(client mod.Waifuvalt, tracer mod.Tracer) mod.Waifuvalt {
+	if tracer == nil {
+		tracer = NoopTracer{}
+	}
+	return &tracingApi{Waifuvalt: client, tracer: tracer}
+}
+
+func (re *tracingApi) UploadFile(ctx context.Context, options mod.WaifuvaultPutOpts) (*mod.WaifuResponse[string], error) {
+	return traced(re, ctx, mod.OperationUploadFile, "", options.BucketToken, func(ctx context.Context) (*mod.WaifuResponse[string], error) {
+		return re.Waifuvalt.UploadFile(ctx, options)
+	})
+}
+
+func (re *tracingApi) FileInfo(ctx context.Context, token string) (*mod.WaifuResponse[int], error) {
+	return traced(re, ctx, mod.OperationFileInfo, token, "", func(ctx context.Context) (*mod.WaifuResponse[int], error) {
+		return re.Waifuvalt.FileInfo(ctx, token)
+	})
+}
+
+func (re *tracingApi) FileInfoFormatted(ctx context.Context, token string) (*mod.WaifuResponse[string], error) {
+	return traced(re, ctx, mod.OperationFileInfoFormatted, token, "", func(ctx context.Context) (*mod.WaifuResponse[string], error) {
+		return re.Waifuvalt.FileInfoFormatted(ctx, token)
+	})
+}
+
+func (re *tracingApi) DeleteFile(ctx context.Context, token string) (bool, error) {
+	return traced(re, ctx, mod.OperationDeleteFile, token, "", func(ctx context.Context) (bool, error) {
+		return re.Waifuvalt.DeleteFile(ctx, token)
+	})
+}
+
+func (re *tracingApi) GetFile(ctx context.Context, options mod.GetFileInfo) ([]byte, error) {
+	return traced(re, ctx, mod.OperationGetFile, options.Token, "", func(ctx context.Context) ([]byte, error) {
+		return re.Waifuvalt.GetFile(ctx, options)
+	})
+}
+
+func (re *tracingApi) GetFileStream(ctx context.Context, options mod.GetFileInfo) (io.ReadCloser, error) {
+	return re.tracedStream(ctx, mod.OperationGetFileStream, options.Token, func(ctx context.Context) (io.ReadCloser, error) {
+		return StreamFile(ctx, re.Waifuvalt, options)
+	})
+}
+
+func (re *tracingApi) ModifyFile(ctx context.Context, token string, options mod.ModifyEntryPayload) (*mod.WaifuResponse[int], error) {
+	return traced(re, ctx, mod.OperationModifyFile, token, "", func(ctx context.Context) (*mod.WaifuResponse[int], error) {
+		return re.Waifuvalt.ModifyFile(ctx, token, options)
+	})
+}
+
+func (re *tracingApi) CreateBucket(ctx context.Context) (*mod.WaifuBucket, error) {
+	return traced(re, ctx, mod.OperationCreateBucket, "", "", re.Waifuvalt.CreateBucket)
+}
+
+func (re *tracingApi) GetBucket(ctx context.Context, token string) (*mod.WaifuBucket, error) {
+	return traced(re, ctx, mod.OperationGetBucket, "", token, func(ctx context.Context) (*mod.WaifuBucket, error) {
+		return re.Waifuvalt.GetBucket(ctx, token)
+	})
+}
+
+func (re *tracingApi) DeleteBucket(ctx context.Context, token string) (bool, error) {
+	return traced(re, ctx, mod.OperationDeleteBucket, "", token, func(ctx context.Context) (bool, error) {
+		return re.Waifuvalt.DeleteBucket(ctx, token)
+	})
+}
+
+func (re *tracingApi) CreateAlbum(ctx context.Context, body mod.WaifuAlbumCreateBody) (*mod.WaifuAlbum, error) {
+	return traced(re, ctx, mod.OperationCreateAlbum, "", body.BucketToken, func(ctx context.Context) (*mod.WaifuAlbum, error) {
+		return re.Waifuvalt.CreateAlbum(ctx, body)
+	})
+}
+
+func (re *tracingApi) AssociateFiles(ctx context.Context, albumToken string, filesToAssociate []string) (*mod.WaifuAlbum, error) {
+	return traced(re, ctx, mod.OperationAssociateFiles, albumToken, "", func(ctx context.Context) (*mod.WaifuAlbum, error) {
+		return re.Waifuvalt.AssociateFiles(ctx, albumToken, filesToAssociate)
+	})
+}
+
+func (re *tracingApi) DisassociateFiles(ctx context.Context, albumToken string, filesToDisassociate []string) (*mod.WaifuAlbum, error) {
+	return traced(re, ctx, mod.OperationDisassociateFiles, albumToken, "", func(ctx context.Context) (*mod.WaifuAlbum, error) {
+		return re.Waifuvalt.DisassociateFiles(ctx, albumToken, filesToDisassociate)
+	})
+}
+
+func (re *tracingApi) GetAlbum(ctx context.Context, albumToken string) (*mod.WaifuAlbum, error) {
+	return traced(re, ctx, mod.OperationGetAlbum, albumToken, "", func(ctx context.Context) (*mod.WaifuAlbum, error) {
+		return re.Waifuvalt.GetAlbum(ctx, albumToken)
+	})
+}
+
+func (re *tracingApi) DeleteAlbum(ctx context.Context, albumToken string, deleteFiles bool) (*mod.GenericSuccess, error) {
+	return traced(re, ctx, mod.OperationDeleteAlbum, albumToken, "", func(ctx context.Context) (*mod.GenericSuccess, error) {
+		return re.Waifuvalt.DeleteAlbum(ctx, albumToken, deleteFiles)
+	})
+}
+
+func (re *tracingApi) ShareAlbum(ctx context.Context, albumToken string) (string, error) {
+	return traced(re, ctx, mod.OperationShareAlbum, albumToken, "", func(ctx context.Context) (string, error) {
+		return re.Waifuvalt.ShareAlbum(ctx, albumToken)
+	})
+}
+
+func (re *tracingApi) RevokeAlbum(ctx context.Context, albumToken string) (*mod.GenericSuccess, error) {
+	return traced(re, ctx, mod.OperationRevokeAlbum, albumToken, "", func(ctx context.Context) (*mod.GenericSuccess, error) {
+		return re.Waifuvalt.RevokeAlbum(ctx, albumToken)
+	})
+}
+
+func (re *tracingApi) DownloadAlbum(ctx context.Context, albumToken string, files []int) ([]byte, error) {
+	return traced(re, ctx, mod.OperationDownloadAlbum, albumToken, "", func(ctx context.Context) ([]byte, error) {
+		return re.Waifuvalt.DownloadAlbum(ctx, albumToken, files)
+	})
+}
+
+func (re *tracingApi) DownloadAlbumStream(ctx context.Context, albumToken string, files []int) (io.ReadCloser, error) {
+	return re.tracedStream(ctx, mod.OperationDownloadAlbumStream, albumToken, func(ctx context.Context) (io.ReadCloser, error) {
+		return StreamAlbum(ctx, re.Waifuvalt, albumToken, files)
+	})
+}
+
+func (re *tracingApi) GetFileSize(ctx context.Context, options mod.GetFileInfo) (int64, error) {
+	return traced(re, ctx, mod.OperationFileSize, "", "", func(ctx context.Context) (int64, error) {
+		return FileSize(ctx, re.Waifuvalt, options)
+	})
+}
+
+// start starts the span of an operation on the file or album token and the bucket token
+func (re *tracingApi) start(ctx context.Context, operation mod.Operation, token, bucket string) (context.Context, mod.Span) {
+	ctx, span := re.tracer.Start(ctx, operation)
+	span.SetAttribute(mod.AttributeOperation, string(operation))
+	if token != "" {
+		span.SetAttribute(mod.AttributeTokenPrefix, tokenPrefix(token))
+	}
+	if bucket != "" {
+		span.SetAttribute(mod.AttributeBucketPrefix, tokenPrefix(bucket))
+	}
+	return ctx, span
+}
+
+// tracedStream runs fn in a span that ends when the returned stream is closed
+func (re *tracingApi) tracedStream(ctx context.Context, operation mod.Operation, token string, fn func(ctx context.Context) (io.ReadCloser, error)) (io.ReadCloser, error) {
+	ctx, span := re.start(ctx, operation, token, "")
+	stream, err := fn(ctx)
+	if err != nil {
+		span.End(err)
+		return nil, err
+	}
+	return &spanStream{ReadCloser: stream, span: span}, nil
+}
+
+// traced runs fn in the span of an operation
+func traced[T any](re *tracingApi, ctx context.Context, operation mod.Operation, token, bucket string, fn func(ctx context.Context) (T, error)) (T, error) {
+	ctx, span := re.start(ctx, operation, token, bucket)
+	value, err := fn(ctx)
+	span.End(err)
+	return value, err
+}
+
+// spanStream ends the span of a streamed operation once it is closed, keeping the Size and ContentType of the stream
+type spanStream struct {
+	io.ReadCloser
+	span mod.Span
+	once sync.Once
+}
+
+func (re *spanStream) Close() error {
+	err := re.ReadCloser.Close()
+	re.once.Do(func() {
+		re.span.End(nil)
+	})
+	return err
+}
+
+// Size is the length of the stream, -1 if it is unknown
+func (re *spanStream) Size() int64 {
+	if sized, ok := re.ReadCloser.(interface{ Size() int64 }); ok {
+		return sized.Size()
+	}
+	return -1
+}
+
+// ContentType is the content type of the stream, empty if it is unknown
+func (re *spanStream) ContentType() string {
+	if typed, ok := re.ReadCloser.(interface{ ContentType() string }); ok {
+		return typed.ContentType()
+	}
+	return ""
+}
+
+// requestTokens returns the file or album token and the bucket token a request is made for
+func requestTokens(operation mod.Operation, r *http.Request) (token, bucket string) {
+	_, restPath, ok := strings.Cut(r.URL.Path, "/rest/")
+	if !ok {
+		return "", ""
+	}
+	segments := strings.Split(restPath, "/")
+	segment := func(i int) string {
+		if i < len(segments) {
+			return segments[i]
+		}
+		return ""
+	}
+	switch operation {
+	case mod.OperationUploadFile:
+		return "", segment(0)
+	case mod.OperationFileInfo, mod.OperationFileInfoFormatted, mod.OperationDeleteFile, mod.OperationModifyFile:
+		return segment(0), ""
+	case mod.OperationDeleteBucket, mod.OperationCreateAlbum:
+		return "", segment(1)
+	case mod.OperationGetBucket:
+		return "", bodyBucketToken(r)
+	case mod.OperationAssociateFiles, mod.OperationDisassociateFiles, mod.OperationGetAlbum, mod.OperationDeleteAlbum:
+		return segment(1), ""
+	case mod.OperationShareAlbum, mod.OperationRevokeAlbum, mod.OperationDownloadAlbum, mod.OperationDownloadAlbumStream:
+		return segment(2), ""
+	}
+	return "", ""
+}
+
+// bodyBucketToken reads the bucket token from a replayable JSON body
+func bodyBucketToken(r *http.Request) string {
+	if r.GetBody == nil {
+		return ""
+	}
+	body, err := r.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer body.Close()
+	var payload struct {
+		BucketToken string `json:"bucket_token"`
+	}
+	json.NewDecoder(body).Decode(&payload)
+	return payload.BucketToken
+}
+
+func tokenPrefix(token string) string {
+	if len(token) > tokenPrefixLength {
+		return token[:tokenPrefixLength]
+	}
+	return token
+}
+
+// NoopTracer is a Tracer that records nothing and sends no traceparent
+type NoopTracer struct{}
+
+func (NoopTracer) Start(ctx context.Context, operation mod.Operation) (context.Context, mod.Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(key string, value any) {}
+func (noopSpan) TraceParent() string                { return "" }
+func (noopSpan) End(err error)                      {}
+
+// SpanRecorder is a Tracer that keeps finished spans in memory, to check tracing without a collector.
+// Spans started with a context of a recorded span become its children
+type SpanRecorder struct {
+	mu    sync.Mutex
+	spans []mod.RecordedSpan
+}
+
+type recordedSpanKey struct{}
+
+type recordedSpan struct {
+	recorder *SpanRecorder
+
+	mu   sync.Mutex
+	span mod.RecordedSpan
+	done bool
+}
+
+// NewSpanRecorder creates an empty span recorder
+func NewSpanRecorder() *SpanRecorder {
+	return &SpanRecorder{}
+}
+
+func (re *SpanRecorder) Start(ctx context.Context, operation mod.Operation) (context.Context, mod.Span) {
+	span := &recordedSpan{recorder: re, span: mod.RecordedSpan{
+		Operation:  operation,
+		TraceID:    randomHex(16),
+		SpanID:     randomHex(8),
+		Attributes: map[string]any{},
+		Start:      time.Now(),
+	}}
+	if parent, ok := ctx.Value(recordedSpanKey{}).(*recordedSpan); ok {
+		span.span.TraceID = parent.span.TraceID
+		span.span.ParentSpanID = parent.span.SpanID
+	}
+	return context.WithValue(ctx, recordedSpanKey{}, span), span
+}
+
+// Spans returns the finished spans in the order they ended
+func (re *SpanRecorder) Spans() []mod.RecordedSpan {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	spans := make([]mod.RecordedSpan, len(re.spans))
+	for i, span := range re.spans {
+		span.Attributes = maps.Clone(span.Attributes)
+		spans[i] = span
+	}
+	return spans
+}
+
+func (re *recordedSpan) SetAttribute(key string, value any) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	re.span.Attributes[key] = value
+}
+
+func (re *recordedSpan) TraceParent() string {
+	return "00-" + re.span.TraceID + "-" + re.span.SpanID + "-01"
+}
+
+func (re *recordedSpan) End(err error) {
+	re.mu.Lock()
+	if re.done {
+		re.mu.Unlock()
+		return
+	}
+	re.done = true
+	re.span.Err = err
+	re.span.End = time.Now()
+	span := re.span
+	re.mu.Unlock()
+
+	re.recorder.mu.Lock()
+	defer re.recorder.mu.Unlock()
+	re.recorder.spans = append(re.recorder.spans, span)
+}
+
+func randomHex(n int) string {
+	id := make([]byte, n)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
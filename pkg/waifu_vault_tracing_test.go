@@ -0,0 +1,160 @@
+package waifuVault
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+func TestTracingMiddleware(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should record a span per request", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		token := fv.addFile(bucket, "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+
+		var traceParents []string
+		recorder := NewSpanRecorder()
+		api := NewWaifuvaltApiWithOpts(http.Client{}, mod.ClientOpts{Middleware: []mod.Middleware{
+			TracingMiddleware(recorder),
+			mod.MiddlewareFunc(func(operation mod.Operation, r *http.Request, next mod.RequestHandler) (*http.Response, error) {
+				traceParents = append(traceParents, r.Header.Get("traceparent"))
+				return next(r)
+			}),
+		}})
+
+		parentCtx, parent := recorder.Start(ctx, "Sync")
+		if _, err := api.GetBucket(parentCtx, bucket); err != nil {
+			t.Fatalf("GetBucket failed: %v", err)
+		}
+		if _, err := api.GetFile(parentCtx, mod.GetFileInfo{Token: token}); err != nil {
+			t.Fatalf("GetFile failed: %v", err)
+		}
+		parent.End(nil)
+
+		spans := recorder.Spans()
+		if len(spans) != 4 {
+			t.Fatalf("Expected 4 spans, got %d", len(spans))
+		}
+		root := spans[3]
+		for i, span := range spans[:3] {
+			if span.TraceID != root.TraceID || span.ParentSpanID != root.SpanID {
+				t.Errorf("Expected span %d to be a child of the root span, got %+v", i, span)
+			}
+			expected := "00-" + span.TraceID + "-" + span.SpanID + "-01"
+			if traceParents[i] != expected {
+				t.Errorf("Expected traceparent %s, got %s", expected, traceParents[i])
+			}
+		}
+
+		getBucket, fileInfo, getFile := spans[0].Attributes, spans[1].Attributes, spans[2].Attributes
+		if getBucket[mod.AttributeBucketPrefix] != tokenPrefix(bucket) || getBucket[mod.AttributeStatus] != http.StatusOK {
+			t.Errorf("Expected the bucket prefix and status, got %v", getBucket)
+		}
+		if fileInfo[mod.AttributeTokenPrefix] != tokenPrefix(token) || fileInfo[mod.AttributeOperation] != string(mod.OperationFileInfo) {
+			t.Errorf("Expected the token prefix, got %v", fileInfo)
+		}
+		if getFile[mod.AttributeBytesReceived] != int64(len("image")) {
+			t.Errorf("Expected 5 bytes received, got %v", getFile[mod.AttributeBytesReceived])
+		}
+	})
+
+	t.Run("should end spans with the request error", func(t *testing.T) {
+		recorder := NewSpanRecorder()
+		failure := errors.New("connection refused")
+		req, _ := http.NewRequest(http.MethodDelete, "https://waifuvault.moe/rest/album/album-token", nil)
+		TracingMiddleware(recorder).Handle(mod.OperationDeleteAlbum, req, func(r *http.Request) (*http.Response, error) {
+			return nil, failure
+		})
+
+		spans := recorder.Spans()
+		if len(spans) != 1 || spans[0].Err != failure || spans[0].ParentSpanID != "" || spans[0].Attributes[mod.AttributeTokenPrefix] != "album-to" {
+			t.Errorf("Expected a failed root span for the album, got %+v", spans)
+		}
+	})
+
+	t.Run("should not send a traceparent by default", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "https://waifuvault.moe/rest/token", nil)
+		TracingMiddleware(nil).Handle(mod.OperationFileInfo, req, func(r *http.Request) (*http.Response, error) {
+			if r.Header.Get("traceparent") != "" {
+				t.Errorf("Expected no traceparent, got %s", r.Header.Get("traceparent"))
+			}
+			return statusResponse(http.StatusOK), nil
+		})
+	})
+}
+
+func TestTracingApi(t *testing.T) {
+	ctx := context.Background()
+
+	newApi := func(recorder *SpanRecorder) mod.Waifuvalt {
+		return NewTracingApi(NewWaifuvaltApiWithOpts(http.Client{}, mod.ClientOpts{Middleware: []mod.Middleware{
+			TracingMiddleware(recorder),
+		}}), recorder)
+	}
+
+	t.Run("should record one span per operation", func(t *testing.T) {
+		fv := newFakeVault(t)
+		token := fv.addFile("", "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		recorder := NewSpanRecorder()
+
+		if _, err := newApi(recorder).GetFile(ctx, mod.GetFileInfo{Token: token}); err != nil {
+			t.Fatalf("GetFile failed: %v", err)
+		}
+		spans := recorder.Spans()
+		if len(spans) != 3 {
+			t.Fatalf("Expected 3 spans, got %d", len(spans))
+		}
+		operation := spans[2]
+		if operation.Operation != mod.OperationGetFile || operation.ParentSpanID != "" || operation.Attributes[mod.AttributeTokenPrefix] != tokenPrefix(token) {
+			t.Errorf("Expected a root span for GetFile, got %+v", operation)
+		}
+		for i, span := range spans[:2] {
+			if span.TraceID != operation.TraceID || span.ParentSpanID != operation.SpanID {
+				t.Errorf("Expected request %d to be a child of the operation, got %+v", i, span)
+			}
+		}
+		if spans[0].Operation != mod.OperationFileInfo || spans[1].Operation != mod.OperationGetFile {
+			t.Errorf("Expected the FileInfo and download requests, got %s and %s", spans[0].Operation, spans[1].Operation)
+		}
+	})
+
+	t.Run("should end the span of a stream when it is closed", func(t *testing.T) {
+		fv := newFakeVault(t)
+		token := fv.addFile("", "08.png", []byte("image"), mod.WaifuResponseOptions{}, "")
+		recorder := NewSpanRecorder()
+
+		stream, err := newApi(recorder).(mod.FileStreamer).GetFileStream(ctx, mod.GetFileInfo{Token: token})
+		if err != nil {
+			t.Fatalf("GetFileStream failed: %v", err)
+		}
+		if sized, ok := stream.(interface{ Size() int64 }); !ok || sized.Size() != int64(len("image")) {
+			t.Errorf("Expected the stream to report its size")
+		}
+		if len(recorder.Spans()) != 1 {
+			t.Errorf("Expected only the FileInfo request to have ended, got %d spans", len(recorder.Spans()))
+		}
+		stream.Close()
+		spans := recorder.Spans()
+		if len(spans) != 3 || spans[2].Operation != mod.OperationGetFileStream || spans[2].ParentSpanID != "" {
+			t.Errorf("Expected the operation to end last, got %+v", spans)
+		}
+	})
+
+	t.Run("should end the span with the error of the operation", func(t *testing.T) {
+		newFakeVault(t)
+		recorder := NewSpanRecorder()
+
+		if _, err := newApi(recorder).FileInfo(ctx, "missing"); err == nil {
+			t.Fatalf("Expected FileInfo to fail")
+		}
+		spans := recorder.Spans()
+		if len(spans) != 2 || spans[1].Err == nil || spans[1].ParentSpanID != "" {
+			t.Errorf("Expected a failed operation span, got %+v", spans)
+		}
+	})
+}
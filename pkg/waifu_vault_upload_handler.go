@@ -0,0 +1,196 @@
+package waifuVault
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+const sniffLength = 512
+
+var errFileTooLarge = errors.New("file is too large")
+
+type uploadHandler struct {
+	client mod.Waifuvalt
+	opts   mod.UploadHandlerOpts
+}
+
+// NewUploadHandler creates an http.Handler that accepts multipart POST requests and streams every file straight into UploadFile.
+// It responds with a mod.UploadHandlerResponse. If any file is rejected or fails, the files already uploaded by the request are deleted
+func NewUploadHandler(client mod.Waifuvalt, opts mod.UploadHandlerOpts) http.Handler {
+	return &uploadHandler{
+		client: client,
+		opts:   opts,
+	}
+}
+
+// handlerError is an error with the status code to respond with
+type handlerError struct {
+	status int
+	err    error
+}
+
+func (re *handlerError) Error() string {
+	return re.err.Error()
+}
+
+func (re *uploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSON(w, http.StatusMethodNotAllowed, mod.UploadHandlerResponse{Error: "method not allowed"})
+		return
+	}
+	if re.opts.Authorize != nil {
+		if err := re.opts.Authorize(r); err != nil {
+			writeJSON(w, http.StatusForbidden, mod.UploadHandlerResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	var uploaded []string
+	files, err := re.uploadAll(r, &uploaded)
+	if err != nil {
+		ctx := context.WithoutCancel(r.Context())
+		for _, token := range uploaded {
+			re.client.DeleteFile(ctx, token)
+		}
+		status := http.StatusBadGateway
+		var handlerErr *handlerError
+		if errors.As(err, &handlerErr) {
+			status = handlerErr.status
+		}
+		writeJSON(w, status, mod.UploadHandlerResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, mod.UploadHandlerResponse{Files: files})
+}
+
+// uploadAll uploads every file in the request, the token of every uploaded file is added to uploaded
+func (re *uploadHandler) uploadAll(r *http.Request, uploaded *[]string) ([]mod.UploadedFile, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, &handlerError{status: http.StatusBadRequest, err: err}
+	}
+	var files []mod.UploadedFile
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, &handlerError{status: http.StatusBadRequest, err: err}
+		}
+		if part.FileName() == "" {
+			part.Close()
+			continue
+		}
+		if re.opts.MaxFiles > 0 && len(files) == re.opts.MaxFiles {
+			return nil, &handlerError{status: http.StatusRequestEntityTooLarge, err: fmt.Errorf("at most %d files can be uploaded", re.opts.MaxFiles)}
+		}
+
+		file, resp, err := re.upload(r, part)
+		if resp != nil {
+			*uploaded = append(*uploaded, resp.Token)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", part.FileName(), err)
+		}
+		files = append(files, file)
+	}
+	if len(files) == 0 {
+		return nil, &handlerError{status: http.StatusBadRequest, err: errors.New("no files were uploaded")}
+	}
+	return files, nil
+}
+
+func (re *uploadHandler) upload(r *http.Request, part *multipart.Part) (mod.UploadedFile, *mod.WaifuResponse[string], error) {
+	file := mod.UploadedFile{Field: part.FormName(), Filename: part.FileName()}
+	content := bufio.NewReaderSize(part, sniffLength)
+	head, err := content.Peek(sniffLength)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return file, nil, &handlerError{status: http.StatusBadRequest, err: err}
+	}
+	file.ContentType = http.DetectContentType(head)
+	if !allowedContentType(re.opts.AllowedTypes, file.ContentType) {
+		return file, nil, &handlerError{status: http.StatusUnsupportedMediaType, err: fmt.Errorf("files of type %s are not allowed", file.ContentType)}
+	}
+
+	options := re.opts.Upload
+	if re.opts.Policy != nil {
+		if err = re.opts.Policy(r, file.Filename, &options); err != nil {
+			return file, nil, &handlerError{status: http.StatusForbidden, err: err}
+		}
+	}
+	limited := &sizeLimitReader{reader: content, limit: re.opts.MaxFileSize}
+	options.Reader = limited
+	options.FileName = file.Filename
+
+	resp, err := re.client.UploadFile(r.Context(), options)
+	if limited.exceeded {
+		return file, resp, &handlerError{status: http.StatusRequestEntityTooLarge, err: errFileTooLarge}
+	}
+	if err != nil {
+		return file, nil, err
+	}
+	file.URL = resp.URL
+	file.Size = limited.read
+	if re.opts.OnUpload != nil {
+		if err = re.opts.OnUpload(r, resp); err != nil {
+			return file, resp, &handlerError{status: http.StatusInternalServerError, err: err}
+		}
+	}
+	return file, resp, nil
+}
+
+// allowedContentType reports if contentType matches one of the allowed types, types ending in /* match every subtype
+func allowedContentType(allowed []string, contentType string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, pattern := range allowed {
+		if prefix, found := strings.CutSuffix(pattern, "/*"); found && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+		if strings.EqualFold(pattern, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// sizeLimitReader fails once more than limit bytes have been read, a limit of 0 is unlimited
+type sizeLimitReader struct {
+	reader   io.Reader
+	limit    int64
+	read     int64
+	exceeded bool
+}
+
+func (re *sizeLimitReader) Read(p []byte) (int, error) {
+	n, err := re.reader.Read(p)
+	re.read += int64(n)
+	if re.limit > 0 && re.read > re.limit {
+		re.exceeded = true
+		return 0, errFileTooLarge
+	}
+	return n, err
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,158 @@
+package waifuVault
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n")
+
+func postFiles(t *testing.T, handler http.Handler, files map[string][]byte, order ...string) (int, mod.UploadHandlerResponse) {
+	body := bytes.Buffer{}
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("comment", "not a file")
+	for _, name := range order {
+		part, _ := writer.CreateFormFile("upload", name)
+		part.Write(files[name])
+	}
+	writer.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	var resp mod.UploadHandlerResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	return w.Code, resp
+}
+
+func TestUploadHandler(t *testing.T) {
+	t.Run("should upload every file", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		var tokens []string
+		handler := NewUploadHandler(NewWaifuvaltApi(http.Client{}), mod.UploadHandlerOpts{
+			Upload: mod.WaifuvaultPutOpts{BucketToken: bucket, Expires: "1d"},
+			OnUpload: func(r *http.Request, resp *mod.WaifuResponse[string]) error {
+				tokens = append(tokens, resp.Token)
+				return nil
+			},
+		})
+
+		files := map[string][]byte{"notes.txt": []byte("some notes"), "08.png": append(pngHeader, "image"...)}
+		status, resp := postFiles(t, handler, files, "notes.txt", "08.png")
+		if status != http.StatusOK {
+			t.Fatalf("Expected %d, got %d: %s", http.StatusOK, status, resp.Error)
+		}
+		if len(resp.Files) != 2 || len(tokens) != 2 {
+			t.Fatalf("Expected 2 files, got %d", len(resp.Files))
+		}
+		for i, file := range resp.Files {
+			uploaded := fv.file(tokens[i])
+			if file.URL != uploaded.response.URL || !bytes.Equal(uploaded.content, files[file.Filename]) {
+				t.Errorf("Expected %s to be uploaded", file.Filename)
+			}
+			if uploaded.response.Bucket != bucket || file.Field != "upload" || file.Size != int64(len(files[file.Filename])) {
+				t.Errorf("Unexpected upload %+v", file)
+			}
+		}
+		if resp.Files[1].ContentType != "image/png" {
+			t.Errorf("Expected image/png, got %s", resp.Files[1].ContentType)
+		}
+	})
+
+	t.Run("should reject large files and delete earlier uploads", func(t *testing.T) {
+		fv := newFakeVault(t)
+		bucket := fv.addBucket()
+		handler := NewUploadHandler(NewWaifuvaltApi(http.Client{}), mod.UploadHandlerOpts{
+			MaxFileSize: 10,
+			Upload:      mod.WaifuvaultPutOpts{BucketToken: bucket},
+		})
+
+		files := map[string][]byte{"small.txt": []byte("small"), "large.txt": []byte(strings.Repeat("large", 10))}
+		status, resp := postFiles(t, handler, files, "small.txt", "large.txt")
+		if status != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected %d, got %d", http.StatusRequestEntityTooLarge, status)
+		}
+		if !strings.Contains(resp.Error, "large.txt") || len(fv.bucketFiles(bucket)) != 0 {
+			t.Errorf("Expected the uploaded files to be deleted, got %s", resp.Error)
+		}
+	})
+
+	t.Run("should reject types that are not allowed", func(t *testing.T) {
+		newFakeVault(t)
+		handler := NewUploadHandler(NewWaifuvaltApi(http.Client{}), mod.UploadHandlerOpts{AllowedTypes: []string{"image/*"}})
+
+		status, _ := postFiles(t, handler, map[string][]byte{"08.png": pngHeader}, "08.png")
+		if status != http.StatusOK {
+			t.Errorf("Expected images to be allowed, got %d", status)
+		}
+		status, _ = postFiles(t, handler, map[string][]byte{"notes.txt": []byte("notes")}, "notes.txt")
+		if status != http.StatusUnsupportedMediaType {
+			t.Errorf("Expected %d, got %d", http.StatusUnsupportedMediaType, status)
+		}
+	})
+
+	t.Run("should limit the number of files", func(t *testing.T) {
+		newFakeVault(t)
+		handler := NewUploadHandler(NewWaifuvaltApi(http.Client{}), mod.UploadHandlerOpts{MaxFiles: 1})
+
+		files := map[string][]byte{"a.txt": []byte("a"), "b.txt": []byte("b")}
+		status, _ := postFiles(t, handler, files, "a.txt", "b.txt")
+		if status != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected %d, got %d", http.StatusRequestEntityTooLarge, status)
+		}
+	})
+
+	t.Run("should apply the authorisation and policy hooks", func(t *testing.T) {
+		fv := newFakeVault(t)
+		handler := NewUploadHandler(NewWaifuvaltApi(http.Client{}), mod.UploadHandlerOpts{
+			Authorize: func(r *http.Request) error {
+				if r.URL.Query().Get("key") != "secret" {
+					return errors.New("invalid key")
+				}
+				return nil
+			},
+		})
+
+		status, _ := postFiles(t, handler, map[string][]byte{"a.txt": []byte("a")}, "a.txt")
+		if status != http.StatusForbidden || fv.requestCount(http.MethodPut, "/rest") != 0 {
+			t.Errorf("Expected %d, got %d", http.StatusForbidden, status)
+		}
+
+		var token string
+		handler = NewUploadHandler(NewWaifuvaltApi(http.Client{}), mod.UploadHandlerOpts{
+			Policy: func(r *http.Request, filename string, options *mod.WaifuvaultPutOpts) error {
+				options.Password = "pass-" + filename
+				return nil
+			},
+			OnUpload: func(r *http.Request, resp *mod.WaifuResponse[string]) error {
+				token = resp.Token
+				return nil
+			},
+		})
+		postFiles(t, handler, map[string][]byte{"a.txt": []byte("a")}, "a.txt")
+		if fv.file(token).password != "pass-a.txt" {
+			t.Errorf("Expected the policy to set the password")
+		}
+	})
+
+	t.Run("should only accept post requests", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		NewUploadHandler(nil, mod.UploadHandlerOpts{}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/upload", nil))
+		if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != http.MethodPost {
+			t.Errorf("Expected %d, got %d", http.StatusMethodNotAllowed, w.Code)
+		}
+	})
+}
@@ -0,0 +1,125 @@
+package waifuVault
+
+import (
+	"context"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+// maxWatchBackoff is the largest multiple of the interval waited between failed polls
+const maxWatchBackoff = 16
+
+// WatchBucket polls a bucket every interval and emits an event for every file or album that was added, removed or modified.
+// The first snapshot is used as the baseline and produces no events. Failed polls emit a BucketWatchError and
+// back off exponentially. The channel is closed when ctx is cancelled
+func WatchBucket(ctx context.Context, client mod.Waifuvalt, token string, interval time.Duration) <-chan mod.BucketEvent {
+	events := make(chan mod.BucketEvent, 16)
+	go func() {
+		defer close(events)
+		var previous *mod.WaifuBucket
+		failures := 0
+		for {
+			bucket, err := client.GetBucket(ctx, token)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				failures++
+				emitBucketEvent(ctx, events, mod.BucketEvent{Type: mod.BucketWatchError, Err: err})
+			} else {
+				failures = 0
+				if previous != nil {
+					for _, event := range diffBuckets(previous, bucket) {
+						emitBucketEvent(ctx, events, event)
+					}
+				}
+				previous = bucket
+			}
+
+			timer := time.NewTimer(watchDelay(interval, failures))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+	}()
+	return events
+}
+
+func watchDelay(interval time.Duration, failures int) time.Duration {
+	backoff := 1
+	for i := 0; i < failures && backoff < maxWatchBackoff; i++ {
+		backoff *= 2
+	}
+	return interval * time.Duration(backoff)
+}
+
+func diffBuckets(previous, current *mod.WaifuBucket) []mod.BucketEvent {
+	var events []mod.BucketEvent
+
+	previousFiles := make(map[string]mod.WaifuResponse[int], len(previous.Files))
+	for _, file := range previous.Files {
+		previousFiles[file.Token] = file
+	}
+	for _, file := range current.Files {
+		old, found := previousFiles[file.Token]
+		delete(previousFiles, file.Token)
+		if !found {
+			events = append(events, mod.BucketEvent{Type: mod.FileAdded, File: &file})
+		} else if fileChanged(old, file) {
+			events = append(events, mod.BucketEvent{Type: mod.FileModified, File: &file, PreviousFile: &old})
+		}
+	}
+	for _, file := range previous.Files {
+		if _, removed := previousFiles[file.Token]; removed {
+			events = append(events, mod.BucketEvent{Type: mod.FileRemoved, File: &file})
+		}
+	}
+
+	previousAlbums := make(map[string]mod.AlbumStub, len(previous.Albums))
+	for _, album := range previous.Albums {
+		previousAlbums[album.Token] = album
+	}
+	for _, album := range current.Albums {
+		old, found := previousAlbums[album.Token]
+		delete(previousAlbums, album.Token)
+		if !found {
+			events = append(events, mod.BucketEvent{Type: mod.AlbumAdded, Album: &album})
+		} else if albumChanged(old, album) {
+			events = append(events, mod.BucketEvent{Type: mod.AlbumModified, Album: &album, PreviousAlbum: &old})
+		}
+	}
+	for _, album := range previous.Albums {
+		if _, removed := previousAlbums[album.Token]; removed {
+			events = append(events, mod.BucketEvent{Type: mod.AlbumRemoved, Album: &album})
+		}
+	}
+	return events
+}
+
+// fileChanged compares two snapshots of a file, ignoring the retention period as it decreases on every poll
+func fileChanged(previous, current mod.WaifuResponse[int]) bool {
+	previous.RetentionPeriod = 0
+	current.RetentionPeriod = 0
+	return previous != current
+}
+
+func albumChanged(previous, current mod.AlbumStub) bool {
+	if previous.Name != current.Name {
+		return true
+	}
+	if (previous.PublicToken == nil) != (current.PublicToken == nil) {
+		return true
+	}
+	return previous.PublicToken != nil && *previous.PublicToken != *current.PublicToken
+}
+
+func emitBucketEvent(ctx context.Context, events chan<- mod.BucketEvent, event mod.BucketEvent) {
+	select {
+	case events <- event:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,133 @@
+package waifuVault
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+func TestWatchBucket(t *testing.T) {
+	t.Run("should emit events for changes between snapshots", func(t *testing.T) {
+		viewed := WaifuResponseMock1
+		viewed.Views = 3
+		viewed.RetentionPeriod = 1000
+		added := WaifuResponseMock1
+		added.Token = "added-token"
+		album := mod.AlbumStub{Token: MockUUID2, Bucket: WaifuBucketMock1.Token, Name: "album1"}
+
+		snapshots := []mod.WaifuBucket{
+			{Token: WaifuBucketMock1.Token, Files: []mod.WaifuResponse[int]{WaifuResponseMock1}},
+			{Token: WaifuBucketMock1.Token, Files: []mod.WaifuResponse[int]{viewed, added}, Albums: []mod.AlbumStub{album}},
+			{Token: WaifuBucketMock1.Token, Files: []mod.WaifuResponse[int]{added}},
+		}
+		var mu sync.Mutex
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			snapshot := snapshots[min(calls, len(snapshots)-1)]
+			calls++
+			mu.Unlock()
+			json.NewEncoder(w).Encode(snapshot)
+		}))
+		defer server.Close()
+
+		origBaseUrl := baseUrl
+		defer func() { baseUrl = origBaseUrl }()
+		baseUrl = server.URL
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events := WatchBucket(ctx, NewWaifuvaltApi(http.Client{}), WaifuBucketMock1.Token, time.Millisecond)
+
+		expected := []mod.BucketEventType{mod.FileModified, mod.FileAdded, mod.AlbumAdded, mod.FileRemoved, mod.AlbumRemoved}
+		for _, expectedType := range expected {
+			event := <-events
+			if event.Type != expectedType {
+				t.Fatalf("Expected event %d, got %d", expectedType, event.Type)
+			}
+		}
+	})
+
+	t.Run("should not emit events for retention changes", func(t *testing.T) {
+		var mu sync.Mutex
+		retention := 100000
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			file := WaifuResponseMock1
+			file.RetentionPeriod = retention
+			retention--
+			mu.Unlock()
+			json.NewEncoder(w).Encode(mod.WaifuBucket{Token: WaifuBucketMock1.Token, Files: []mod.WaifuResponse[int]{file}})
+		}))
+		defer server.Close()
+
+		origBaseUrl := baseUrl
+		defer func() { baseUrl = origBaseUrl }()
+		baseUrl = server.URL
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		for event := range WatchBucket(ctx, NewWaifuvaltApi(http.Client{}), WaifuBucketMock1.Token, time.Millisecond) {
+			t.Errorf("Expected no events, got %d", event.Type)
+		}
+	})
+
+	t.Run("should emit errors and keep polling", func(t *testing.T) {
+		var mu sync.Mutex
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			calls++
+			call := calls
+			mu.Unlock()
+			if call == 2 {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(WaifuErrorMock)
+				return
+			}
+			files := []mod.WaifuResponse[int]{}
+			if call > 2 {
+				files = append(files, WaifuResponseMock1)
+			}
+			json.NewEncoder(w).Encode(mod.WaifuBucket{Token: WaifuBucketMock1.Token, Files: files})
+		}))
+		defer server.Close()
+
+		origBaseUrl := baseUrl
+		defer func() { baseUrl = origBaseUrl }()
+		baseUrl = server.URL
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events := WatchBucket(ctx, NewWaifuvaltApi(http.Client{}), WaifuBucketMock1.Token, time.Millisecond)
+
+		event := <-events
+		if event.Type != mod.BucketWatchError || event.Err == nil {
+			t.Fatalf("Expected error event, got %d", event.Type)
+		}
+		event = <-events
+		if event.Type != mod.FileAdded {
+			t.Fatalf("Expected file added event, got %d", event.Type)
+		}
+	})
+}
+
+func TestWatchDelay(t *testing.T) {
+	t.Run("should back off exponentially up to a limit", func(t *testing.T) {
+		if d := watchDelay(time.Second, 0); d != time.Second {
+			t.Errorf("Expected 1s, got %s", d)
+		}
+		if d := watchDelay(time.Second, 3); d != 8*time.Second {
+			t.Errorf("Expected 8s, got %s", d)
+		}
+		if d := watchDelay(time.Second, 50); d != 16*time.Second {
+			t.Errorf("Expected 16s, got %s", d)
+		}
+	})
+}
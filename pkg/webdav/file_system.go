@@ -0,0 +1,397 @@
+// Package webdav serves a bucket over WebDAV so it can be mounted as a network drive
+package webdav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	waifuVault "github.com/waifuvault/waifuVault-go-api/pkg"
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+	dav "golang.org/x/net/webdav"
+)
+
+// FileSystem is a webdav.FileSystem over a bucket. Albums are collections and files that are not in an album are in the root.
+// Uploading a file over an existing one replaces it, moving a file between collections associates it with the album.
+// Files and albums can not be renamed, and albums can not contain collections
+type FileSystem struct {
+	client mod.Waifuvalt
+	token  string
+	opts   mod.WebDAVOpts
+	fsys   *waifuVault.BucketFS
+}
+
+var _ dav.FileSystem = (*FileSystem)(nil)
+
+// NewFileSystem creates a webdav.FileSystem over a bucket. ctx is used to list and download files
+func NewFileSystem(ctx context.Context, client mod.Waifuvalt, token string, opts mod.WebDAVOpts) *FileSystem {
+	return &FileSystem{
+		client: client,
+		token:  token,
+		opts:   opts,
+		fsys:   waifuVault.NewBucketFS(ctx, client, token, mod.BucketFSOpts{TTL: opts.TTL, Passwords: opts.Passwords}),
+	}
+}
+
+// NewHandler creates a webdav.Handler serving a bucket with an in-memory lock system
+func NewHandler(ctx context.Context, client mod.Waifuvalt, token string, opts mod.WebDAVOpts) *dav.Handler {
+	return &dav.Handler{
+		FileSystem: NewFileSystem(ctx, client, token, opts),
+		LockSystem: dav.NewMemLS(),
+	}
+}
+
+func (re *FileSystem) Mkdir(ctx context.Context, name string, _ os.FileMode) error {
+	name = fsName(name)
+	if name == "." {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	if path.Dir(name) != "." {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: errors.New("albums can not contain collections")}
+	}
+	if _, err := re.fsys.Stat(name); err == nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	defer re.fsys.Invalidate()
+	_, err := re.client.CreateAlbum(ctx, mod.WaifuAlbumCreateBody{Name: name, BucketToken: re.token})
+	return err
+}
+
+func (re *FileSystem) OpenFile(ctx context.Context, name string, flag int, _ os.FileMode) (dav.File, error) {
+	name = fsName(name)
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) == 0 {
+		file, err := re.fsys.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		return &readFile{File: file}, nil
+	}
+
+	existing, err := re.fsys.Stat(name)
+	switch {
+	case err == nil && existing.IsDir():
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+	case err == nil && flag&os.O_EXCL != 0:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	case err == nil && flag&os.O_TRUNC == 0:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("files can only be replaced")}
+	case err != nil && !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	case err != nil && flag&os.O_CREATE == 0:
+		return nil, err
+	}
+	album, err := re.album(path.Dir(name))
+	if err != nil {
+		return nil, err
+	}
+	return re.upload(ctx, name, album, existing), nil
+}
+
+func (re *FileSystem) RemoveAll(ctx context.Context, name string) error {
+	name = fsName(name)
+	if name == "." {
+		return &fs.PathError{Op: "removeall", Path: name, Err: fs.ErrPermission}
+	}
+	info, err := re.fsys.Stat(name)
+	if err != nil {
+		return err
+	}
+	defer re.fsys.Invalidate()
+	switch sys := info.Sys().(type) {
+	case *mod.WaifuResponse[int]:
+		_, err = re.client.DeleteFile(ctx, sys.Token)
+	case *mod.AlbumStub:
+		_, err = re.client.DeleteAlbum(ctx, sys.Token, true)
+	}
+	return err
+}
+
+// Rename moves a file between the root and albums, the file name can not change
+func (re *FileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	oldName, newName = fsName(oldName), fsName(newName)
+	info, err := re.fsys.Stat(oldName)
+	if err != nil {
+		return err
+	}
+	file, ok := info.Sys().(*mod.WaifuResponse[int])
+	if !ok || path.Base(oldName) != path.Base(newName) {
+		return &fs.PathError{Op: "rename", Path: oldName, Err: fs.ErrPermission}
+	}
+	from, err := re.album(path.Dir(oldName))
+	if err != nil {
+		return err
+	}
+	to, err := re.album(path.Dir(newName))
+	if err != nil {
+		return err
+	}
+	if from == to {
+		return nil
+	}
+
+	defer re.fsys.Invalidate()
+	if from != "" {
+		if _, err = re.client.DisassociateFiles(ctx, from, []string{file.Token}); err != nil {
+			return err
+		}
+	}
+	if to != "" {
+		_, err = re.client.AssociateFiles(ctx, to, []string{file.Token})
+	}
+	return err
+}
+
+func (re *FileSystem) Stat(_ context.Context, name string) (os.FileInfo, error) {
+	info, err := re.fsys.Stat(fsName(name))
+	if err != nil {
+		return nil, err
+	}
+	return &fileInfo{FileInfo: info}, nil
+}
+
+// album returns the token of the album of a directory, or an empty string for the root
+func (re *FileSystem) album(dir string) (string, error) {
+	if dir == "." {
+		return "", nil
+	}
+	info, err := re.fsys.Stat(dir)
+	if err != nil {
+		return "", err
+	}
+	album, ok := info.Sys().(*mod.AlbumStub)
+	if !ok {
+		return "", &fs.PathError{Op: "open", Path: dir, Err: errors.New("not a directory")}
+	}
+	return album.Token, nil
+}
+
+// upload starts uploading a file into the bucket, everything written to the file is streamed into the upload
+func (re *FileSystem) upload(ctx context.Context, name, album string, existing fs.FileInfo) *writeFile {
+	reader, writer := io.Pipe()
+	file := &writeFile{ctx: ctx, name: name, writer: writer, done: make(chan error, 1)}
+	go func() {
+		err := re.store(ctx, name, album, existing, reader)
+		reader.CloseWithError(err)
+		file.done <- err
+	}()
+	return file
+}
+
+// store uploads a file, associates it with its album and deletes the file it replaces.
+// The file it replaces is only deleted once the upload is known to have the size of everything written
+func (re *FileSystem) store(ctx context.Context, name, album string, existing fs.FileInfo, reader io.Reader) error {
+	defer re.fsys.Invalidate()
+	counter := &countingReader{Reader: reader}
+	resp, err := re.client.UploadFile(ctx, mod.WaifuvaultPutOpts{
+		Reader:      counter,
+		FileName:    path.Base(name),
+		Expires:     re.opts.Expires,
+		BucketToken: re.token,
+	})
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		if err = re.checkSize(ctx, resp.URL, counter.read); err != nil {
+			re.client.DeleteFile(ctx, resp.Token)
+			return err
+		}
+	}
+	if album != "" {
+		if _, err = re.client.AssociateFiles(ctx, album, []string{resp.Token}); err != nil {
+			return err
+		}
+	}
+	if existing != nil {
+		_, err = re.client.DeleteFile(ctx, existing.Sys().(*mod.WaifuResponse[int]).Token)
+	}
+	return err
+}
+
+// checkSize returns an error unless an uploaded file has the given size
+func (re *FileSystem) checkSize(ctx context.Context, url string, size int64) error {
+	uploaded, err := waifuVault.FileSize(ctx, re.client, mod.GetFileInfo{Url: url})
+	if err != nil {
+		return fmt.Errorf("the size of the upload is unknown: %w", err)
+	}
+	if uploaded != size {
+		return fmt.Errorf("the upload has %d bytes but %d were written", uploaded, size)
+	}
+	return nil
+}
+
+// countingReader counts the bytes read from an upload
+type countingReader struct {
+	io.Reader
+	read int64
+}
+
+func (re *countingReader) Read(p []byte) (int, error) {
+	n, err := re.Reader.Read(p)
+	re.read += int64(n)
+	return n, err
+}
+
+// fsName converts a WebDAV path to an fs.FS path
+func fsName(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// fileInfo reports the content type from the file extension so listings do not download every file
+type fileInfo struct {
+	fs.FileInfo
+}
+
+func (re *fileInfo) ContentType(context.Context) (string, error) {
+	if contentType := mime.TypeByExtension(path.Ext(re.Name())); contentType != "" {
+		return contentType, nil
+	}
+	return "application/octet-stream", nil
+}
+
+// readFile adapts a file opened from the bucket to a webdav.File
+type readFile struct {
+	fs.File
+}
+
+func (re *readFile) Seek(offset int64, whence int) (int64, error) {
+	seeker, ok := re.File.(io.Seeker)
+	if !ok {
+		return 0, errors.New("is a directory")
+	}
+	return seeker.Seek(offset, whence)
+}
+
+func (re *readFile) Readdir(count int) ([]fs.FileInfo, error) {
+	dir, ok := re.File.(fs.ReadDirFile)
+	if !ok {
+		return nil, errors.New("not a directory")
+	}
+	entries, err := dir.ReadDir(count)
+	infos := make([]fs.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, infoErr := entry.Info()
+		if infoErr != nil {
+			return infos, infoErr
+		}
+		infos = append(infos, &fileInfo{FileInfo: info})
+	}
+	return infos, err
+}
+
+func (re *readFile) Stat() (fs.FileInfo, error) {
+	info, err := re.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &fileInfo{FileInfo: info}, nil
+}
+
+func (re *readFile) Write([]byte) (int, error) {
+	return 0, errors.New("file is opened for reading")
+}
+
+// writeFile is a file being uploaded, the upload completes when it is closed
+type writeFile struct {
+	ctx     context.Context
+	name    string
+	writer  *io.PipeWriter
+	done    chan error
+	written int64
+	modTime time.Time
+	err     error
+}
+
+func (re *writeFile) Write(p []byte) (int, error) {
+	n, err := re.writer.Write(p)
+	re.written += int64(n)
+	if err != nil {
+		re.err = err
+	}
+	return n, err
+}
+
+// ReadFrom copies src into the upload. webdav copies the request body with io.Copy and closes the file even if
+// reading the body failed, so the error is kept for Close to abort the upload instead of storing a truncated file
+func (re *writeFile) ReadFrom(src io.Reader) (int64, error) {
+	n, err := io.Copy(struct{ io.Writer }{re}, src)
+	if err != nil && re.err == nil {
+		re.err = err
+	}
+	return n, err
+}
+
+// Close completes the upload, or aborts it if a write failed or the request was cancelled
+func (re *writeFile) Close() error {
+	if re.err == nil {
+		re.err = re.ctx.Err()
+	}
+	if re.err != nil {
+		re.writer.CloseWithError(re.err)
+		if err := <-re.done; err != nil {
+			return err
+		}
+		return re.err
+	}
+	re.writer.Close()
+	return <-re.done
+}
+
+func (re *writeFile) Stat() (fs.FileInfo, error) {
+	if re.modTime.IsZero() {
+		re.modTime = time.Now()
+	}
+	return &uploadInfo{file: re}, nil
+}
+
+func (re *writeFile) Read([]byte) (int, error) {
+	return 0, errors.New("file is opened for writing")
+}
+
+func (re *writeFile) Seek(int64, int) (int64, error) {
+	return 0, errors.New("file is opened for writing")
+}
+
+func (re *writeFile) Readdir(int) ([]fs.FileInfo, error) {
+	return nil, errors.New("not a directory")
+}
+
+// uploadInfo describes a file that is being uploaded
+type uploadInfo struct {
+	file *writeFile
+}
+
+func (re *uploadInfo) Name() string {
+	return path.Base(re.file.name)
+}
+
+func (re *uploadInfo) Size() int64 {
+	return re.file.written
+}
+
+func (re *uploadInfo) Mode() fs.FileMode {
+	return 0644
+}
+
+func (re *uploadInfo) ModTime() time.Time {
+	return re.file.modTime
+}
+
+func (re *uploadInfo) IsDir() bool {
+	return false
+}
+
+func (re *uploadInfo) Sys() any {
+	return nil
+}
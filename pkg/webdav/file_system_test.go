@@ -0,0 +1,363 @@
+package webdav
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+
+	"github.com/waifuvault/waifuVault-go-api/pkg/mod"
+)
+
+// fakeClient is an in-memory bucket with the operations used by the file system
+type fakeClient struct {
+	mod.Waifuvalt
+
+	mu      sync.Mutex
+	next    int
+	files   map[string]*fakeFile
+	albums  map[string]*mod.AlbumStub
+	deleted []string
+
+	// downloads counts the calls to GetFileStream
+	downloads int
+
+	// truncate drops the last byte of uploads, like a server that stored an incomplete file
+	truncate bool
+}
+
+type fakeFile struct {
+	response mod.WaifuResponse[int]
+	content  []byte
+	album    string
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{files: map[string]*fakeFile{}, albums: map[string]*mod.AlbumStub{}}
+}
+
+func (re *fakeClient) addFile(name, content string) string {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	re.next++
+	token := fmt.Sprintf("file-%d", re.next)
+	re.files[token] = &fakeFile{
+		response: mod.WaifuResponse[int]{
+			Token:  token,
+			URL:    fmt.Sprintf("https://vault.test/f/%d/%s", 1700000000000+re.next, url.PathEscape(name)),
+			Bucket: "bucket",
+			ID:     re.next,
+		},
+		content: []byte(content),
+	}
+	return token
+}
+
+func (re *fakeClient) fileNamed(name string) *fakeFile {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	for _, file := range re.files {
+		if strings.HasSuffix(file.response.URL, "/"+url.PathEscape(name)) {
+			return file
+		}
+	}
+	return nil
+}
+
+func (re *fakeClient) UploadFile(_ context.Context, options mod.WaifuvaultPutOpts) (*mod.WaifuResponse[string], error) {
+	content, err := io.ReadAll(options.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if re.truncate && len(content) > 0 {
+		content = content[:len(content)-1]
+	}
+	token := re.addFile(options.FileName, string(content))
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	return &mod.WaifuResponse[string]{Token: token, URL: re.files[token].response.URL, Bucket: options.BucketToken}, nil
+}
+
+func (re *fakeClient) DeleteFile(_ context.Context, token string) (bool, error) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	delete(re.files, token)
+	re.deleted = append(re.deleted, token)
+	return true, nil
+}
+
+func (re *fakeClient) GetFileStream(_ context.Context, options mod.GetFileInfo) (io.ReadCloser, error) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	re.downloads++
+	for _, file := range re.files {
+		if file.response.URL == options.Url {
+			return &fakeStream{ReadCloser: io.NopCloser(bytes.NewReader(file.content)), size: int64(len(file.content))}, nil
+		}
+	}
+	return nil, fmt.Errorf("file %s not found", options.Url)
+}
+
+func (re *fakeClient) GetFileSize(_ context.Context, options mod.GetFileInfo) (int64, error) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	for _, file := range re.files {
+		if file.response.URL == options.Url {
+			return int64(len(file.content)), nil
+		}
+	}
+	return 0, fmt.Errorf("file %s not found", options.Url)
+}
+
+// fakeStream reports its size like the streams returned by GetFileStream
+type fakeStream struct {
+	io.ReadCloser
+	size int64
+}
+
+func (re *fakeStream) Size() int64 {
+	return re.size
+}
+
+func (re *fakeClient) GetBucket(_ context.Context, token string) (*mod.WaifuBucket, error) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	bucket := &mod.WaifuBucket{Token: token}
+	for _, file := range re.files {
+		bucket.Files = append(bucket.Files, file.response)
+	}
+	for _, album := range re.albums {
+		bucket.Albums = append(bucket.Albums, *album)
+	}
+	return bucket, nil
+}
+
+func (re *fakeClient) CreateAlbum(_ context.Context, body mod.WaifuAlbumCreateBody) (*mod.WaifuAlbum, error) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	re.next++
+	token := fmt.Sprintf("album-%d", re.next)
+	re.albums[token] = &mod.AlbumStub{Token: token, Bucket: body.BucketToken, Name: body.Name}
+	return &mod.WaifuAlbum{Token: token, BucketToken: body.BucketToken, Name: body.Name}, nil
+}
+
+func (re *fakeClient) GetAlbum(_ context.Context, albumToken string) (*mod.WaifuAlbum, error) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	album := &mod.WaifuAlbum{Token: albumToken, Name: re.albums[albumToken].Name}
+	for _, file := range re.files {
+		if file.album == albumToken {
+			album.Files = append(album.Files, file.response)
+		}
+	}
+	return album, nil
+}
+
+func (re *fakeClient) DeleteAlbum(_ context.Context, albumToken string, deleteFiles bool) (*mod.GenericSuccess, error) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	delete(re.albums, albumToken)
+	for token, file := range re.files {
+		if file.album == albumToken && deleteFiles {
+			delete(re.files, token)
+		} else if file.album == albumToken {
+			file.album = ""
+		}
+	}
+	return &mod.GenericSuccess{Success: true}, nil
+}
+
+func (re *fakeClient) AssociateFiles(_ context.Context, albumToken string, files []string) (*mod.WaifuAlbum, error) {
+	return re.setAlbum(albumToken, files)
+}
+
+func (re *fakeClient) DisassociateFiles(_ context.Context, _ string, files []string) (*mod.WaifuAlbum, error) {
+	return re.setAlbum("", files)
+}
+
+func (re *fakeClient) setAlbum(albumToken string, files []string) (*mod.WaifuAlbum, error) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	for _, token := range files {
+		re.files[token].album = albumToken
+	}
+	return &mod.WaifuAlbum{Token: albumToken}, nil
+}
+
+func newDavServer(t *testing.T, client *fakeClient) *httptest.Server {
+	server := httptest.NewServer(NewHandler(context.Background(), client, "bucket", mod.WebDAVOpts{}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func davRequest(t *testing.T, method, url string, body io.Reader, header map[string]string) (int, string) {
+	r, err := http.NewRequest(method, url, body)
+	if err != nil {
+		t.Fatalf("NewRequest failed: %v", err)
+	}
+	for key, value := range header {
+		r.Header.Set(key, value)
+	}
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatalf("%s failed: %v", method, err)
+	}
+	defer resp.Body.Close()
+	content, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(content)
+}
+
+func TestFileSystem(t *testing.T) {
+	t.Run("should upload and download files", func(t *testing.T) {
+		client := newFakeClient()
+		server := newDavServer(t, client)
+
+		status, _ := davRequest(t, http.MethodPut, server.URL+"/notes.txt", strings.NewReader("some notes"), nil)
+		if status != http.StatusCreated {
+			t.Fatalf("Expected %d, got %d", http.StatusCreated, status)
+		}
+		status, body := davRequest(t, http.MethodGet, server.URL+"/notes.txt", nil, nil)
+		if status != http.StatusOK || body != "some notes" {
+			t.Errorf("Unexpected response %d: %s", status, body)
+		}
+	})
+
+	t.Run("should replace existing files", func(t *testing.T) {
+		client := newFakeClient()
+		old := client.addFile("notes.txt", "old")
+		server := newDavServer(t, client)
+
+		davRequest(t, http.MethodPut, server.URL+"/notes.txt", strings.NewReader("new"), nil)
+		_, body := davRequest(t, http.MethodGet, server.URL+"/notes.txt", nil, nil)
+
+		if body != "new" || !slices.Equal(client.deleted, []string{old}) {
+			t.Errorf("Expected the old file to be replaced, got %s and deleted %v", body, client.deleted)
+		}
+		if client.downloads != 1 {
+			t.Errorf("Expected the upload to be checked without downloading it, got %d downloads", client.downloads)
+		}
+	})
+
+	t.Run("should keep the existing file when an upload is aborted", func(t *testing.T) {
+		client := newFakeClient()
+		old := client.addFile("notes.txt", "old")
+		fsys := NewFileSystem(context.Background(), client, "bucket", mod.WebDAVOpts{})
+
+		file, err := fsys.OpenFile(context.Background(), "/notes.txt", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+		if err != nil {
+			t.Fatalf("OpenFile failed: %v", err)
+		}
+		body := io.MultiReader(strings.NewReader("ne"), iotest.ErrReader(io.ErrUnexpectedEOF))
+		if _, err = io.Copy(file, body); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("Expected io.ErrUnexpectedEOF, got %v", err)
+		}
+		if err = file.Close(); err == nil {
+			t.Errorf("Expected Close to fail for an aborted upload")
+		}
+		if len(client.files) != 1 || client.files[old] == nil || len(client.deleted) != 0 {
+			t.Errorf("Expected only the old file to remain, got %d files and deleted %v", len(client.files), client.deleted)
+		}
+	})
+
+	t.Run("should keep the existing file when the upload is incomplete", func(t *testing.T) {
+		client := newFakeClient()
+		client.truncate = true
+		old := client.addFile("notes.txt", "old")
+		server := newDavServer(t, client)
+
+		status, _ := davRequest(t, http.MethodPut, server.URL+"/notes.txt", strings.NewReader("new"), nil)
+		if status == http.StatusCreated {
+			t.Errorf("Expected the upload to fail, got %d", status)
+		}
+		if len(client.files) != 1 || client.files[old] == nil || slices.Contains(client.deleted, old) {
+			t.Errorf("Expected only the old file to remain, got %d files and deleted %v", len(client.files), client.deleted)
+		}
+	})
+
+	t.Run("should create albums and upload into them", func(t *testing.T) {
+		client := newFakeClient()
+		server := newDavServer(t, client)
+
+		status, _ := davRequest(t, "MKCOL", server.URL+"/holiday", nil, nil)
+		if status != http.StatusCreated {
+			t.Fatalf("Expected %d, got %d", http.StatusCreated, status)
+		}
+		davRequest(t, http.MethodPut, server.URL+"/holiday/beach.png", strings.NewReader("image"), nil)
+
+		file := client.fileNamed("beach.png")
+		if file == nil || client.albums[file.album].Name != "holiday" {
+			t.Errorf("Expected the file to be in the album")
+		}
+		status, _ = davRequest(t, http.MethodPut, server.URL+"/missing/beach.png", strings.NewReader("image"), nil)
+		if status != http.StatusConflict {
+			t.Errorf("Expected %d for a missing collection, got %d", http.StatusConflict, status)
+		}
+	})
+
+	t.Run("should move files into albums", func(t *testing.T) {
+		client := newFakeClient()
+		token := client.addFile("beach.png", "image")
+		server := newDavServer(t, client)
+		davRequest(t, "MKCOL", server.URL+"/holiday", nil, nil)
+
+		status, _ := davRequest(t, "MOVE", server.URL+"/beach.png", nil, map[string]string{"Destination": server.URL + "/holiday/beach.png"})
+		if status != http.StatusCreated {
+			t.Fatalf("Expected %d, got %d", http.StatusCreated, status)
+		}
+		if client.albums[client.files[token].album].Name != "holiday" {
+			t.Errorf("Expected the file to be associated with the album")
+		}
+		status, _ = davRequest(t, "MOVE", server.URL+"/holiday/beach.png", nil, map[string]string{"Destination": server.URL + "/holiday/renamed.png"})
+		if status != http.StatusForbidden {
+			t.Errorf("Expected renaming to be forbidden, got %d", status)
+		}
+	})
+
+	t.Run("should delete files and albums", func(t *testing.T) {
+		client := newFakeClient()
+		token := client.addFile("notes.txt", "notes")
+		server := newDavServer(t, client)
+		davRequest(t, "MKCOL", server.URL+"/holiday", nil, nil)
+
+		status, _ := davRequest(t, http.MethodDelete, server.URL+"/notes.txt", nil, nil)
+		if status != http.StatusNoContent || client.files[token] != nil {
+			t.Errorf("Expected the file to be deleted, got %d", status)
+		}
+		davRequest(t, http.MethodDelete, server.URL+"/holiday", nil, nil)
+		if len(client.albums) != 0 {
+			t.Errorf("Expected the album to be deleted")
+		}
+	})
+
+	t.Run("should list albums and files", func(t *testing.T) {
+		client := newFakeClient()
+		client.addFile("notes.txt", "notes")
+		once := client.addFile("once.txt", "once")
+		client.files[once].response.Options.OneTimeDownload = true
+		server := newDavServer(t, client)
+		davRequest(t, "MKCOL", server.URL+"/holiday", nil, nil)
+
+		status, body := davRequest(t, "PROPFIND", server.URL+"/", nil, map[string]string{"Depth": "1"})
+		if status != http.StatusMultiStatus {
+			t.Fatalf("Expected %d, got %d", http.StatusMultiStatus, status)
+		}
+		for _, expected := range []string{"<D:href>/holiday/</D:href>", "<D:href>/notes.txt</D:href>", "text/plain", "<D:getcontentlength>5</D:getcontentlength>"} {
+			if !strings.Contains(body, expected) {
+				t.Errorf("Expected the listing to contain %s, got %s", expected, body)
+			}
+		}
+		if strings.Contains(body, "once.txt") || client.downloads != 0 {
+			t.Errorf("Expected the one time download to be hidden and nothing downloaded, got %d downloads", client.downloads)
+		}
+	})
+}